@@ -0,0 +1,272 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"limiter"
+)
+
+// 默认配置
+const (
+	defaultFailures       = 5
+	defaultCoolDown       = 10 * time.Second
+	defaultHalfOpenProbes = 1
+)
+
+var (
+	// 熔断器打开，拒绝请求
+	ErrOpen = errors.New("breaker: 熔断器已打开")
+	// 熔断器半开，探测请求数已达上限
+	ErrTooManyRequests = errors.New("breaker: 半开状态探测请求过多")
+)
+
+// 熔断器的状态
+type State int
+
+const (
+	StateClosed   State = iota // 关闭，请求正常通过，统计失败
+	StateOpen                  // 打开，拒绝所有请求，冷却时间过后进入半开状态
+	StateHalfOpen              // 半开，允许少量探测请求，全部成功时关闭，任意一个失败时重新打开
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// 配置函数
+type Option func(b *Breaker)
+
+// 设置熔断策略，默认为连续失败5次
+func WithPolicy(p TripPolicy) Option {
+	return func(b *Breaker) {
+		if p != nil {
+			b.policy = p
+		}
+	}
+}
+
+// 设置冷却时间，熔断器打开后经过冷却时间进入半开状态，默认为10秒
+func WithCoolDown(d time.Duration) Option {
+	return func(b *Breaker) {
+		if d > 0 {
+			b.coolDown = d
+		}
+	}
+}
+
+// 设置半开状态允许的探测请求数，探测请求全部成功后关闭熔断器，默认为1
+func WithHalfOpenProbes(n int) Option {
+	return func(b *Breaker) {
+		if n > 0 {
+			b.probes = n
+		}
+	}
+}
+
+// 设置状态变化时的回调，回调在锁外执行，可以在回调中调用熔断器的方法
+func WithOnStateChange(f func(name string, from, to State)) Option {
+	return func(b *Breaker) {
+		b.onStateChange = f
+	}
+}
+
+// 设置时钟，默认使用系统时钟；测试时使用limiter.ManualClock手动推进时间
+func WithClock(clock limiter.Clock) Option {
+	return func(b *Breaker) {
+		if clock != nil {
+			b.clock = clock
+		}
+	}
+}
+
+// 设置判断调用是否成功的函数，用于Execute，默认err为nil时成功
+// 如参数错误、资源不存在等调用方的错误不代表下游异常，不应该计为失败
+func WithIsSuccessful(f func(err error) bool) Option {
+	return func(b *Breaker) {
+		if f != nil {
+			b.isSuccessful = f
+		}
+	}
+}
+
+// Breaker 熔断器
+// 下游异常时快速失败，避免请求堆积拖垮调用方，同时给下游恢复的时间
+type Breaker struct {
+	name          string
+	policy        TripPolicy
+	coolDown      time.Duration
+	probes        int
+	onStateChange func(name string, from, to State)
+	clock         limiter.Clock
+	isSuccessful  func(err error) bool
+
+	mu         sync.Mutex // 所有修改 state、generation、openedAt、inflight、succeeded的操作均在 mu锁保护下进行
+	state      State
+	generation uint64    // 每次状态变化时加1，上一个状态放行的请求结束时不再计入统计
+	openedAt   time.Time // 熔断器打开的时间
+	inflight   int       // 半开状态已放行的探测请求数
+	succeeded  int       // 半开状态成功的探测请求数
+}
+
+// New 创建熔断器
+// name：熔断器的名称，在回调中区分不同的熔断器
+func New(name string, opts ...Option) *Breaker {
+	b := &Breaker{
+		name:         name,
+		policy:       ConsecutiveFailures(defaultFailures),
+		coolDown:     defaultCoolDown,
+		probes:       defaultHalfOpenProbes,
+		clock:        limiter.RealClock{},
+		isSuccessful: func(err error) bool { return err == nil },
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// 熔断器的名称
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// 当前状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	state, change := b.currentState(b.clock.Now())
+	b.mu.Unlock()
+	b.notify(change)
+	return state
+}
+
+// Allow 是否允许执行请求
+// 允许时返回done，请求结束后必须调用done报告结果，多次调用只有第一次生效；
+// 拒绝时返回ErrOpen或ErrTooManyRequests
+func (b *Breaker) Allow() (done func(success bool), err error) {
+	b.mu.Lock()
+	now := b.clock.Now()
+	state, change := b.currentState(now)
+	switch state {
+	case StateOpen:
+		err = ErrOpen
+	case StateHalfOpen:
+		if b.inflight >= b.probes {
+			err = ErrTooManyRequests
+		} else {
+			b.inflight++
+		}
+	}
+	generation := b.generation
+	b.mu.Unlock()
+	b.notify(change)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() {
+			b.done(generation, success)
+		})
+	}, nil
+}
+
+// Execute 熔断器允许时执行f，按f返回的错误统计结果
+// 熔断器拒绝时不执行f，返回ErrOpen或ErrTooManyRequests
+func (b *Breaker) Execute(f func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			done(false)
+			panic(e)
+		}
+	}()
+	err = f()
+	done(b.isSuccessful(err))
+	return err
+}
+
+// 报告请求的结果
+func (b *Breaker) done(generation uint64, success bool) {
+	b.mu.Lock()
+	now := b.clock.Now()
+	state, change := b.currentState(now)
+	if generation != b.generation {
+		// 请求开始之后状态已经变化，结果不再有意义
+		b.mu.Unlock()
+		b.notify(change)
+		return
+	}
+
+	switch state {
+	case StateClosed:
+		b.policy.Record(now, success)
+		if !success && b.policy.ShouldTrip(now) {
+			change = b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		if !success {
+			change = b.setState(StateOpen, now)
+			break
+		}
+		b.succeeded++
+		if b.succeeded >= b.probes {
+			change = b.setState(StateClosed, now)
+		}
+	}
+	b.mu.Unlock()
+	b.notify(change)
+}
+
+// 状态变化，from等于to时表示没有变化
+type transition struct {
+	from, to State
+}
+
+// 计算当前状态，冷却时间已过时进入半开状态，调用方需要持有锁
+func (b *Breaker) currentState(now time.Time) (State, transition) {
+	if b.state == StateOpen && !now.Before(b.openedAt.Add(b.coolDown)) {
+		return StateHalfOpen, b.setState(StateHalfOpen, now)
+	}
+	return b.state, transition{b.state, b.state}
+}
+
+// 切换状态，调用方需要持有锁
+func (b *Breaker) setState(state State, now time.Time) transition {
+	t := transition{b.state, state}
+	if b.state == state {
+		return t
+	}
+	b.state = state
+	b.generation++
+	b.inflight, b.succeeded = 0, 0
+
+	switch state {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		b.policy.Reset()
+	}
+	return t
+}
+
+// 执行状态变化的回调，调用方不能持有锁
+func (b *Breaker) notify(t transition) {
+	if t.from != t.to && b.onStateChange != nil {
+		b.onStateChange(b.name, t.from, t.to)
+	}
+}
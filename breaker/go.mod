@@ -0,0 +1,7 @@
+module breaker
+
+go 1.25.0
+
+require limiter v0.0.0-00010101000000-000000000000
+
+replace limiter => ../limiter
@@ -0,0 +1,161 @@
+package breaker
+
+import (
+	"time"
+)
+
+// 熔断策略，决定什么时候打开熔断器
+// 熔断器只在关闭状态下调用，调用时已持有熔断器的锁
+type TripPolicy interface {
+	// 记录一次请求的结果
+	Record(t time.Time, success bool)
+	// 是否需要打开熔断器
+	ShouldTrip(t time.Time) bool
+	// 重置统计，熔断器关闭时调用
+	Reset()
+}
+
+// 连续失败次数策略
+type consecutiveFailures struct {
+	threshold int
+	failures  int
+}
+
+// 连续失败n次时打开熔断器
+func ConsecutiveFailures(n int) TripPolicy {
+	if n <= 0 {
+		n = 1
+	}
+	return &consecutiveFailures{threshold: n}
+}
+
+func (p *consecutiveFailures) Record(_ time.Time, success bool) {
+	if success {
+		p.failures = 0
+		return
+	}
+	p.failures++
+}
+
+func (p *consecutiveFailures) ShouldTrip(time.Time) bool {
+	return p.failures >= p.threshold
+}
+
+func (p *consecutiveFailures) Reset() {
+	p.failures = 0
+}
+
+// 滑动窗口的配置
+const (
+	// 桶数，窗口被平均分成多个桶，过期时整个桶一起丢弃
+	windowBuckets = 10
+	// 默认窗口大小
+	defaultWindow = 10 * time.Second
+)
+
+// 错误率策略
+// 使用分桶的滑动窗口统计最近window时间内的请求，和limiter中的滑动窗口计数器类似，每个桶只保存计数
+type errorRate struct {
+	threshold   float64
+	minRequests int
+	bucketSize  time.Duration
+	buckets     [windowBuckets]rateBucket
+}
+
+// 滑动窗口中的一个桶
+type rateBucket struct {
+	start    time.Time // 桶的开始时间
+	success  int
+	failures int
+}
+
+// 最近window时间内的请求数不少于minRequests，且错误率不低于threshold时打开熔断器
+// threshold：错误率，取值 (0, 1]；window：窗口大小，为0时使用默认的10秒
+func ErrorRate(threshold float64, minRequests int, window time.Duration) TripPolicy {
+	if window <= 0 {
+		window = defaultWindow
+	}
+	if window < windowBuckets {
+		window = windowBuckets
+	}
+	return &errorRate{
+		threshold:   threshold,
+		minRequests: minRequests,
+		bucketSize:  window / windowBuckets,
+	}
+}
+
+func (p *errorRate) Record(t time.Time, success bool) {
+	b := p.bucket(t)
+	if success {
+		b.success++
+	} else {
+		b.failures++
+	}
+}
+
+func (p *errorRate) ShouldTrip(t time.Time) bool {
+	var total, failures int
+	for i := range p.buckets {
+		b := &p.buckets[i]
+		if p.expired(b, t) {
+			continue
+		}
+		total += b.success + b.failures
+		failures += b.failures
+	}
+	if total == 0 || total < p.minRequests {
+		return false
+	}
+	return float64(failures)/float64(total) >= p.threshold
+}
+
+func (p *errorRate) Reset() {
+	p.buckets = [windowBuckets]rateBucket{}
+}
+
+// 时间t所在的桶，桶已过期时清空后复用
+func (p *errorRate) bucket(t time.Time) *rateBucket {
+	start := t.Truncate(p.bucketSize)
+	b := &p.buckets[start.UnixNano()/int64(p.bucketSize)%windowBuckets]
+	if !b.start.Equal(start) {
+		*b = rateBucket{start: start}
+	}
+	return b
+}
+
+// 桶是否已经滑出窗口
+func (p *errorRate) expired(b *rateBucket, t time.Time) bool {
+	return b.start.IsZero() || t.Sub(b.start) >= p.bucketSize*windowBuckets
+}
+
+// 组合策略
+type anyPolicy struct {
+	policies []TripPolicy
+}
+
+// 任意一个策略需要打开熔断器时就打开
+func Any(policies ...TripPolicy) TripPolicy {
+	return &anyPolicy{policies: policies}
+}
+
+func (p *anyPolicy) Record(t time.Time, success bool) {
+	for _, policy := range p.policies {
+		policy.Record(t, success)
+	}
+}
+
+func (p *anyPolicy) ShouldTrip(t time.Time) bool {
+	for _, policy := range p.policies {
+		if policy.ShouldTrip(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *anyPolicy) Reset() {
+	for _, policy := range p.policies {
+		policy.Reset()
+	}
+}
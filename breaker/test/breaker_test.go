@@ -0,0 +1,284 @@
+package test
+
+import (
+	"breaker"
+	"errors"
+	"limiter"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errDownstream = errors.New("下游错误")
+
+// 手动推进的时钟
+func newClock() *limiter.ManualClock {
+	return limiter.NewManualClock(time.Unix(1700000000, 0))
+}
+
+// 记录状态变化
+type transitions struct {
+	mu     sync.Mutex
+	states []string
+}
+
+func (r *transitions) record(_ string, from, to breaker.State) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states = append(r.states, from.String()+"->"+to.String())
+}
+
+func (r *transitions) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.states...)
+}
+
+func fail() error    { return errDownstream }
+func succeed() error { return nil }
+
+// 测试连续失败策略 - 连续失败达到阈值时打开，中间的成功会清零
+func TestConsecutiveFailures(t *testing.T) {
+	now := newClock()
+	b := breaker.New("test", breaker.WithPolicy(breaker.ConsecutiveFailures(3)), breaker.WithClock(now))
+
+	b.Execute(fail)
+	b.Execute(fail)
+	b.Execute(succeed)
+	b.Execute(fail)
+	b.Execute(fail)
+	if b.State() != breaker.StateClosed {
+		t.Fatalf("预期状态 closed，实际: %s", b.State())
+	}
+
+	b.Execute(fail)
+	if b.State() != breaker.StateOpen {
+		t.Fatalf("预期状态 open，实际: %s", b.State())
+	}
+	called := false
+	err := b.Execute(func() error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, breaker.ErrOpen) || called {
+		t.Errorf("熔断器打开时预期返回ErrOpen且不执行请求，err:%v，called:%v", err, called)
+	}
+}
+
+// 测试冷却时间和半开状态 - 探测成功后关闭，探测失败后重新打开
+func TestHalfOpen(t *testing.T) {
+	now := newClock()
+	rec := &transitions{}
+	b := breaker.New("test",
+		breaker.WithPolicy(breaker.ConsecutiveFailures(1)),
+		breaker.WithCoolDown(5*time.Second),
+		breaker.WithHalfOpenProbes(2),
+		breaker.WithOnStateChange(rec.record),
+		breaker.WithClock(now),
+	)
+
+	b.Execute(fail)
+	now.Advance(5*time.Second - time.Millisecond)
+	if err := b.Execute(succeed); !errors.Is(err, breaker.ErrOpen) {
+		t.Fatalf("冷却时间内预期返回ErrOpen，实际: %v", err)
+	}
+
+	// 冷却时间已过，允许2个探测请求
+	now.Advance(time.Millisecond)
+	done1, err1 := b.Allow()
+	done2, err2 := b.Allow()
+	_, err3 := b.Allow()
+	if err1 != nil || err2 != nil {
+		t.Fatalf("半开状态预期允许2个探测请求，err1:%v，err2:%v", err1, err2)
+	}
+	if !errors.Is(err3, breaker.ErrTooManyRequests) {
+		t.Fatalf("预期第3个探测请求返回ErrTooManyRequests，实际: %v", err3)
+	}
+
+	// 一个探测请求失败，重新打开
+	done1(true)
+	done2(false)
+	if b.State() != breaker.StateOpen {
+		t.Fatalf("探测失败后预期状态 open，实际: %s", b.State())
+	}
+
+	// 再次冷却后，探测请求全部成功，关闭
+	now.Advance(5 * time.Second)
+	b.Execute(succeed)
+	b.Execute(succeed)
+	if b.State() != breaker.StateClosed {
+		t.Fatalf("探测成功后预期状态 closed，实际: %s", b.State())
+	}
+
+	want := []string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+		"open->half-open",
+		"half-open->closed",
+	}
+	got := rec.get()
+	if len(got) != len(want) {
+		t.Fatalf("预期状态变化 %v，实际: %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("预期状态变化 %v，实际: %v", want, got)
+		}
+	}
+}
+
+// 测试过期的结果 - 状态变化之前放行的请求，结束时不影响新的状态
+func TestStaleResult(t *testing.T) {
+	now := newClock()
+	b := breaker.New("test",
+		breaker.WithPolicy(breaker.ConsecutiveFailures(1)),
+		breaker.WithCoolDown(time.Second),
+		breaker.WithClock(now),
+	)
+
+	slow, _ := b.Allow()
+	b.Execute(fail)
+	now.Advance(time.Second)
+	b.Execute(succeed)
+	if b.State() != breaker.StateClosed {
+		t.Fatalf("预期状态 closed，实际: %s", b.State())
+	}
+
+	// 打开之前放行的请求失败，不再计入统计
+	slow(false)
+	slow(false)
+	if b.State() != breaker.StateClosed {
+		t.Errorf("过期的结果不应该打开熔断器，实际状态: %s", b.State())
+	}
+}
+
+// 测试错误率策略 - 请求数不足时不打开，错误率达到阈值时打开
+func TestErrorRate(t *testing.T) {
+	now := newClock()
+	b := breaker.New("test",
+		breaker.WithPolicy(breaker.ErrorRate(0.5, 10, 10*time.Second)),
+		breaker.WithClock(now),
+	)
+
+	// 请求数不足
+	for range 5 {
+		b.Execute(fail)
+	}
+	if b.State() != breaker.StateClosed {
+		t.Fatalf("请求数不足时预期状态 closed，实际: %s", b.State())
+	}
+
+	// 10个请求中5个失败，错误率已达到50%，但只在请求失败时检查
+	for range 5 {
+		b.Execute(succeed)
+	}
+	if b.State() != breaker.StateClosed {
+		t.Fatalf("成功请求不会打开熔断器，实际状态: %s", b.State())
+	}
+	b.Execute(fail)
+	if b.State() != breaker.StateOpen {
+		t.Fatalf("错误率达到阈值时预期状态 open，实际: %s", b.State())
+	}
+}
+
+// 测试错误率策略 - 滑出窗口的请求不再统计
+func TestErrorRateWindow(t *testing.T) {
+	now := newClock()
+	b := breaker.New("test",
+		breaker.WithPolicy(breaker.ErrorRate(0.5, 4, 10*time.Second)),
+		breaker.WithClock(now),
+	)
+
+	for range 3 {
+		b.Execute(fail)
+	}
+	// 之前的失败已经滑出窗口
+	now.Advance(10 * time.Second)
+	for range 3 {
+		b.Execute(succeed)
+	}
+	b.Execute(fail)
+	if b.State() != breaker.StateClosed {
+		t.Fatalf("窗口内错误率为25%%，预期状态 closed，实际: %s", b.State())
+	}
+
+	b.Execute(fail)
+	b.Execute(fail)
+	if b.State() != breaker.StateOpen {
+		t.Fatalf("窗口内错误率为50%%，预期状态 open，实际: %s", b.State())
+	}
+}
+
+// 测试组合策略 - 任意一个策略满足时打开
+func TestAnyPolicy(t *testing.T) {
+	now := newClock()
+	b := breaker.New("test",
+		breaker.WithPolicy(breaker.Any(
+			breaker.ConsecutiveFailures(3),
+			breaker.ErrorRate(0.5, 100, 10*time.Second),
+		)),
+		breaker.WithClock(now),
+	)
+
+	for range 3 {
+		b.Execute(fail)
+	}
+	if b.State() != breaker.StateOpen {
+		t.Fatalf("预期状态 open，实际: %s", b.State())
+	}
+}
+
+// 测试判断调用是否成功的函数 - 调用方的错误不计为失败
+func TestIsSuccessful(t *testing.T) {
+	errBadRequest := errors.New("参数错误")
+	b := breaker.New("test",
+		breaker.WithPolicy(breaker.ConsecutiveFailures(1)),
+		breaker.WithIsSuccessful(func(err error) bool {
+			return err == nil || errors.Is(err, errBadRequest)
+		}),
+	)
+
+	if err := b.Execute(func() error { return errBadRequest }); err != errBadRequest {
+		t.Fatalf("预期返回原始错误，实际: %v", err)
+	}
+	if b.State() != breaker.StateClosed {
+		t.Fatalf("预期状态 closed，实际: %s", b.State())
+	}
+}
+
+// 测试http.RoundTripper - 5xx计为失败，打开后不再发送请求
+func TestTransport(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	b := breaker.New("http", breaker.WithPolicy(breaker.ConsecutiveFailures(2)))
+	client := &http.Client{Transport: breaker.NewTransport(b, nil)}
+
+	for range 2 {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("请求失败，err:%v", err)
+		}
+		resp.Body.Close()
+	}
+
+	_, err := client.Get(srv.URL)
+	if !errors.Is(err, breaker.ErrOpen) {
+		t.Fatalf("预期返回ErrOpen，实际: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 {
+		t.Errorf("预期服务端收到2个请求，实际: %d", requests)
+	}
+}
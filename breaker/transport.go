@@ -0,0 +1,38 @@
+package breaker
+
+import (
+	"net/http"
+)
+
+// Transport 使用熔断器的http.RoundTripper
+// 请求出错或者响应状态码为5xx时计为失败，熔断器拒绝时返回ErrOpen或ErrTooManyRequests，不会发送请求
+type Transport struct {
+	Breaker *Breaker
+	Base    http.RoundTripper // 实际发送请求的RoundTripper，为nil时使用http.DefaultTransport
+}
+
+// NewTransport 创建使用熔断器的http.RoundTripper
+// 所有请求共用一个熔断器，调用多个下游时应为每个下游创建一个Transport
+func NewTransport(b *Breaker, base http.RoundTripper) *Transport {
+	return &Transport{Breaker: b, Base: base}
+}
+
+// RoundTrip 实现http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := t.Breaker.Allow()
+	if err != nil {
+		if req.Body != nil {
+			// RoundTripper需要关闭请求体
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	done(err == nil && resp.StatusCode < http.StatusInternalServerError)
+	return resp, err
+}
@@ -0,0 +1,226 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// 默认配置
+const (
+	defaultInitialLimit     = 20
+	defaultMinLimit         = 1
+	defaultMaxLimit         = 1000
+	defaultBackoffRatio     = 0.9
+	defaultLatencyTolerance = 2.0
+	// 延迟的基线（最小延迟）每隔一段时间重新采样，避免一直使用过时的基线
+	minRTTWindow = 30 * time.Second
+	// 延迟低于该值时的抖动不视为延迟上升
+	latencySlack = time.Millisecond
+)
+
+// 并发数已达上限，且排队等待的调用方过多
+var ErrLimitExceeded = errors.New("limiter: 并发数已达上限")
+
+// 自适应并发限流器
+// 固定的令牌生成速率无法感知后端变慢。自适应并发限流器根据请求的延迟和结果动态调整允许的并发数：
+//   - 延迟正常时加法增大（AI，Additive Increase）：并发数接近上限时，每个成功的请求使上限+1
+//   - 延迟上升或请求失败时乘法减小（MD，Multiplicative Decrease）：上限乘以一个小于1的系数，
+//     延迟上升时按 基线延迟*容忍倍数/实际延迟 的梯度减小，但每次最多减小到 上限*backoffRatio
+type AdaptiveLimiter struct {
+	mu           sync.Mutex // 所有修改 limit、inflight、minRTT、waiters的操作均在 mu锁保护下进行
+	limit        float64    // 当前允许的并发数
+	minLimit     int        // 并发数下限
+	maxLimit     int        // 并发数上限
+	backoffRatio float64    // 乘法减小的系数
+	tolerance    float64    // 延迟超过基线的多少倍时视为延迟上升
+	inflight     int        // 正在执行的请求数
+	minRTT       time.Duration
+	minRTTAt     time.Time       // 基线延迟的采样时间
+	waiters      []chan struct{} // 排队等待的调用方，按到达顺序
+	clock        Clock           // 时钟
+	stats        counters        // 统计信息
+}
+
+// 生成自适应并发限流器
+// 可选配置：WithLimitRange、WithInitialLimit、WithBackoffRatio、WithLatencyTolerance、WithClock
+func NewAdaptive(opts ...Option) *AdaptiveLimiter {
+	o := newOptions(opts...)
+	lim := &AdaptiveLimiter{
+		limit:        float64(o.initialLimit),
+		minLimit:     o.minLimit,
+		maxLimit:     o.maxLimit,
+		backoffRatio: o.backoffRatio,
+		tolerance:    o.latencyTolerance,
+		clock:        o.clock,
+	}
+	lim.limit = lim.clamp(lim.limit)
+	return lim
+}
+
+// 获取一个并发名额，执行完成后必须调用release，success表示请求是否成功
+// 并发数已达上限时排队等待；排队的调用方超过当前上限时返回ErrLimitExceeded；ctx被取消时返回ctx.Err()
+func (lim *AdaptiveLimiter) Acquire(ctx context.Context) (release func(success bool), err error) {
+	release, err = lim.acquire(ctx)
+	lim.stats.record(err == nil)
+	return release, err
+}
+
+// Check 只判断并发数是否已满，已满或有调用方在排队时返回ErrLimitExceeded；不占用名额，也不记录延迟
+// 用于stream等长连接：持续时间取决于客户端而不是服务的处理能力，作为延迟样本会把并发数一直减小到下限，
+// 一直占用名额会挤占普通请求的并发数
+func (lim *AdaptiveLimiter) Check() error {
+	lim.mu.Lock()
+	full := lim.inflight >= lim.currentLimit() || len(lim.waiters) > 0
+	lim.mu.Unlock()
+
+	lim.stats.record(!full)
+	if full {
+		return ErrLimitExceeded
+	}
+	return nil
+}
+
+// 统计信息，Waited为排队等待的次数
+func (lim *AdaptiveLimiter) Stats() Stats {
+	return lim.stats.snapshot()
+}
+
+// 获取一个并发名额
+func (lim *AdaptiveLimiter) acquire(ctx context.Context) (release func(success bool), err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	lim.mu.Lock()
+	if lim.inflight < lim.currentLimit() && len(lim.waiters) == 0 {
+		lim.inflight++
+		lim.mu.Unlock()
+		return lim.releaseFunc(lim.clock.Now()), nil
+	}
+
+	// 排队的调用方过多，直接拒绝
+	if len(lim.waiters) >= lim.currentLimit() {
+		lim.mu.Unlock()
+		return nil, ErrLimitExceeded
+	}
+	ready := make(chan struct{})
+	lim.waiters = append(lim.waiters, ready)
+	lim.mu.Unlock()
+
+	start := lim.clock.Now()
+	select {
+	case <-ready:
+		// 已经由释放名额的调用方转交了名额
+		now := lim.clock.Now()
+		lim.stats.recordWait(now.Sub(start))
+		return lim.releaseFunc(now), nil
+	case <-ctx.Done():
+		lim.stats.recordWait(lim.clock.Now().Sub(start))
+		lim.mu.Lock()
+		defer lim.mu.Unlock()
+		if !lim.removeWaiter(ready) {
+			// 名额已经转交过来，归还名额
+			lim.inflight--
+			lim.wakeWaiters()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// 当前允许的并发数
+func (lim *AdaptiveLimiter) Limit() int {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	return lim.currentLimit()
+}
+
+// 正在执行的请求数
+func (lim *AdaptiveLimiter) Inflight() int {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	return lim.inflight
+}
+
+// 当前允许的并发数，调用方需要持有锁
+func (lim *AdaptiveLimiter) currentLimit() int {
+	return int(lim.limit)
+}
+
+// 生成释放名额的函数，只有第一次调用生效
+func (lim *AdaptiveLimiter) releaseFunc(start time.Time) func(success bool) {
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() {
+			lim.onRelease(lim.clock.Now().Sub(start), success)
+		})
+	}
+}
+
+// 核心代码
+// 请求执行完成，根据延迟和结果调整并发数
+func (lim *AdaptiveLimiter) onRelease(rtt time.Duration, success bool) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	// 释放名额前的并发数，用于判断上限是否被充分使用
+	inflight := lim.inflight
+	lim.inflight--
+
+	switch {
+	case !success:
+		// 请求失败，乘法减小
+		lim.limit = lim.clamp(lim.limit * lim.backoffRatio)
+	case lim.latencyRising(rtt):
+		// 延迟上升，按梯度乘法减小
+		gradient := float64(lim.minRTT) * lim.tolerance / float64(rtt)
+		lim.limit = lim.clamp(lim.limit * math.Max(lim.backoffRatio, gradient))
+	case float64(inflight)*2 >= lim.limit:
+		// 延迟正常且上限被充分使用，加法增大
+		lim.limit = lim.clamp(lim.limit + 1)
+	}
+
+	lim.wakeWaiters()
+}
+
+// 延迟是否上升，同时更新基线延迟，调用方需要持有锁
+func (lim *AdaptiveLimiter) latencyRising(rtt time.Duration) bool {
+	now := lim.clock.Now()
+	if lim.minRTT == 0 || rtt < lim.minRTT || now.Sub(lim.minRTTAt) > minRTTWindow {
+		lim.minRTT = rtt
+		lim.minRTTAt = now
+		return false
+	}
+	if rtt-lim.minRTT < latencySlack {
+		return false
+	}
+	return float64(rtt) > float64(lim.minRTT)*lim.tolerance
+}
+
+// 有空闲名额时，按到达顺序把名额转交给排队的调用方，调用方需要持有锁
+func (lim *AdaptiveLimiter) wakeWaiters() {
+	for len(lim.waiters) > 0 && lim.inflight < lim.currentLimit() {
+		ready := lim.waiters[0]
+		lim.waiters = lim.waiters[1:]
+		lim.inflight++
+		close(ready)
+	}
+}
+
+// 从排队的调用方中移除，返回false表示名额已经转交，调用方需要持有锁
+func (lim *AdaptiveLimiter) removeWaiter(ready chan struct{}) bool {
+	for i, w := range lim.waiters {
+		if w == ready {
+			lim.waiters = append(lim.waiters[:i], lim.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// 把并发数限制在[minLimit, maxLimit]之间
+func (lim *AdaptiveLimiter) clamp(limit float64) float64 {
+	return math.Min(math.Max(limit, float64(lim.minLimit)), float64(lim.maxLimit))
+}
@@ -0,0 +1,242 @@
+package bbr
+
+import (
+	"errors"
+	"limiter"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 默认配置
+const (
+	defaultWindow       = 10 * time.Second
+	defaultBuckets      = 100
+	defaultCPUThreshold = 800
+	// 丢弃请求之后的冷却时间，冷却期间即使CPU已经降下来也继续按并发数限流，避免CPU使用率在阈值附近抖动
+	coolOff = time.Second
+)
+
+// 请求被丢弃
+var ErrLimitExceeded = errors.New("bbr: 服务过载，请求被丢弃")
+
+// 配置函数
+type Option func(l *Limiter)
+
+// 设置统计吞吐量和延迟的窗口大小和桶数，默认10秒、100个桶
+func WithWindow(window time.Duration, buckets int) Option {
+	return func(l *Limiter) {
+		if window > 0 && buckets > 0 && window/time.Duration(buckets) > 0 {
+			l.window, l.buckets = window, buckets
+		}
+	}
+}
+
+// 设置CPU使用率的阈值，千分比，默认800（80%）
+// CPU使用率超过阈值时，才开始按并发数丢弃请求
+func WithCPUThreshold(threshold int64) Option {
+	return func(l *Limiter) {
+		if threshold > 0 {
+			l.cpuThreshold = threshold
+		}
+	}
+}
+
+// 设置获取CPU使用率（千分比）的函数，默认为CPU，测试时可以固定返回值
+func WithCPU(cpu func() int64) Option {
+	return func(l *Limiter) {
+		if cpu != nil {
+			l.cpu = cpu
+		}
+	}
+}
+
+// 设置时钟，测试时可以使用limiter.ManualClock
+func WithClock(c limiter.Clock) Option {
+	return func(l *Limiter) {
+		if c != nil {
+			l.clock = c
+		}
+	}
+}
+
+// Limiter 参考TCP BBR拥塞控制算法的过载保护
+// 服务能承受的并发数 = 最大吞吐量 * 最小延迟（利特尔法则），两者都从最近一个窗口的请求中统计：
+//   - 最大吞吐量：窗口中每个桶完成的请求数的最大值
+//   - 最小延迟：窗口中每个桶请求的平均延迟的最小值
+//
+// CPU使用率超过阈值（或者刚刚丢弃过请求）时，正在处理的请求数超过该并发数的请求被丢弃，
+// 在过载时尽早拒绝，而不是让请求排队直到超时
+type Limiter struct {
+	window       time.Duration
+	buckets      int
+	cpuThreshold int64
+	cpu          func() int64
+	clock        limiter.Clock
+
+	inflight atomic.Int64 // 正在处理的请求数
+	dropAt   atomic.Int64 // 最近一次丢弃请求的时间，UnixNano，0表示没有丢弃过
+
+	mu         sync.Mutex // 所有修改 ring、cache的操作均在 mu锁保护下进行
+	bucketSize time.Duration
+	ring       []bucket
+	cache      cachedStat // 按桶缓存的最大吞吐量和最小延迟
+}
+
+// 窗口中的一个桶
+type bucket struct {
+	start time.Time     // 桶的开始时间
+	pass  int64         // 完成的请求数
+	rt    time.Duration // 完成的请求的总延迟
+}
+
+// 缓存的统计结果，当前桶变化之前不需要重新计算
+type cachedStat struct {
+	start   time.Time // 计算时当前桶的开始时间
+	maxPass int64
+	minRT   time.Duration
+}
+
+// 过载保护的状态
+type Stat struct {
+	CPU         int64         // CPU使用率，千分比
+	InFlight    int64         // 正在处理的请求数
+	MaxInFlight int64         // 允许的并发数
+	MaxPass     int64         // 每个桶最多完成的请求数
+	MinRT       time.Duration // 最小的平均延迟
+}
+
+// 生成过载保护限流器
+// 可选配置：WithWindow、WithCPUThreshold、WithCPU、WithClock
+func New(opts ...Option) *Limiter {
+	l := &Limiter{
+		window:       defaultWindow,
+		buckets:      defaultBuckets,
+		cpuThreshold: defaultCPUThreshold,
+		cpu:          CPU,
+		clock:        limiter.RealClock{},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.bucketSize = l.window / time.Duration(l.buckets)
+	l.ring = make([]bucket, l.buckets)
+	return l
+}
+
+// Allow 是否允许处理请求
+// 允许时返回done，请求处理完成后必须调用done，多次调用只有第一次生效；过载时返回ErrLimitExceeded
+func (l *Limiter) Allow() (done func(), err error) {
+	now := l.clock.Now()
+	if l.shouldDrop(now) {
+		l.dropAt.Store(now.UnixNano())
+		return nil, ErrLimitExceeded
+	}
+
+	l.inflight.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			end := l.clock.Now()
+			l.inflight.Add(-1)
+			l.record(end, end.Sub(now))
+		})
+	}, nil
+}
+
+// Check 只判断是否过载，过载时返回ErrLimitExceeded；不计入正在处理的请求数，也不记录延迟
+// 用于stream等长连接：持续时间取决于客户端而不是服务的处理能力，计入延迟会把最小延迟拉高，
+// 一直计入正在处理的请求数会挤占普通请求的并发数
+func (l *Limiter) Check() error {
+	now := l.clock.Now()
+	if l.shouldDrop(now) {
+		l.dropAt.Store(now.UnixNano())
+		return ErrLimitExceeded
+	}
+	return nil
+}
+
+// 当前状态
+func (l *Limiter) Stat() Stat {
+	maxPass, minRT := l.maxPassMinRT(l.clock.Now())
+	return Stat{
+		CPU:         l.cpu(),
+		InFlight:    l.inflight.Load(),
+		MaxInFlight: l.maxInFlight(maxPass, minRT),
+		MaxPass:     maxPass,
+		MinRT:       minRT,
+	}
+}
+
+// 是否需要丢弃请求
+func (l *Limiter) shouldDrop(now time.Time) bool {
+	if l.cpu() < l.cpuThreshold {
+		dropAt := l.dropAt.Load()
+		if dropAt == 0 || now.Sub(time.Unix(0, dropAt)) > coolOff {
+			return false
+		}
+		// 冷却期间继续按并发数限流
+	}
+
+	// 允许的并发数至少为1，总有请求在处理，吞吐量和延迟能够继续更新
+	return l.inflight.Load() >= l.maxInFlight(l.maxPassMinRT(now))
+}
+
+// 允许的并发数 = 每秒最多完成的请求数 * 最小延迟，向上取整
+func (l *Limiter) maxInFlight(maxPass int64, minRT time.Duration) int64 {
+	perSecond := float64(maxPass) * float64(time.Second) / float64(l.bucketSize)
+	return int64(math.Ceil(perSecond * minRT.Seconds()))
+}
+
+// 记录一个完成的请求
+func (l *Limiter) record(now time.Time, rt time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.bucket(now)
+	b.pass++
+	b.rt += rt
+}
+
+// 最近一个窗口中每个桶最多完成的请求数，以及最小的平均延迟
+// 不统计当前的桶，它还没有结束，完成的请求数偏小；窗口中没有请求时返回1和1ms，只允许少量的并发
+func (l *Limiter) maxPassMinRT(now time.Time) (int64, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := now.Truncate(l.bucketSize)
+	if l.cache.start.Equal(start) {
+		return l.cache.maxPass, l.cache.minRT
+	}
+
+	var maxPass int64
+	var minRT time.Duration
+	for i := range l.ring {
+		b := &l.ring[i]
+		if b.pass == 0 || !b.start.Before(start) || !b.start.After(start.Add(-l.window)) {
+			continue
+		}
+		maxPass = max(maxPass, b.pass)
+		if rt := b.rt / time.Duration(b.pass); minRT == 0 || rt < minRT {
+			minRT = rt
+		}
+	}
+	if maxPass == 0 {
+		maxPass, minRT = 1, time.Millisecond
+	}
+	// 延迟小于1微秒时按1微秒计算，避免并发数为0
+	minRT = max(minRT, time.Microsecond)
+
+	l.cache = cachedStat{start: start, maxPass: maxPass, minRT: minRT}
+	return maxPass, minRT
+}
+
+// 时间now所在的桶，桶已过期时清空后复用，调用方需要持有锁
+func (l *Limiter) bucket(now time.Time) *bucket {
+	start := now.Truncate(l.bucketSize)
+	b := &l.ring[start.UnixNano()/int64(l.bucketSize)%int64(l.buckets)]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	return b
+}
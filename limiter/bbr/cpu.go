@@ -0,0 +1,57 @@
+package bbr
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CPU采样的配置
+const (
+	// 采样间隔
+	cpuSampleInterval = 500 * time.Millisecond
+	// 指数滑动平均的衰减系数，平滑短暂的毛刺
+	cpuDecay = 0.95
+)
+
+var (
+	cpuOnce  sync.Once
+	cpuUsage atomic.Int64 // 平滑后的CPU使用率，千分比
+)
+
+// CPU 当前进程平滑后的CPU使用率，千分比（0~1000）
+// 第一次调用时启动后台采样协程，之后每500ms采样一次，按 usage = usage*0.95 + 本次采样*0.05 平滑
+//
+// 使用率 = 进程消耗的CPU时间 / (经过的时间 * GOMAXPROCS)。Go 1.25开始GOMAXPROCS会读取cgroup的CPU限额，
+// 在容器中得到的也是相对于限额的使用率；不支持的平台上始终为0，只按并发数限流
+func CPU() int64 {
+	cpuOnce.Do(func() {
+		go sampleCPU()
+	})
+	return cpuUsage.Load()
+}
+
+// 后台采样CPU使用率
+func sampleCPU() {
+	ticker := time.NewTicker(cpuSampleInterval)
+	defer ticker.Stop()
+
+	prevCPU, ok := processCPUTime()
+	if !ok {
+		return
+	}
+	prevAt := time.Now()
+	var usage float64
+	for now := range ticker.C {
+		cur, _ := processCPUTime()
+		elapsed := now.Sub(prevAt) * time.Duration(runtime.GOMAXPROCS(0))
+		if elapsed <= 0 {
+			continue
+		}
+		sample := min(float64(cur-prevCPU)/float64(elapsed)*1000, 1000)
+		usage = usage*cpuDecay + sample*(1-cpuDecay)
+		cpuUsage.Store(int64(usage))
+		prevCPU, prevAt = cur, now
+	}
+}
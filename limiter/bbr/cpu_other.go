@@ -0,0 +1,10 @@
+//go:build !unix
+
+package bbr
+
+import "time"
+
+// 不支持的平台，不采样CPU使用率
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
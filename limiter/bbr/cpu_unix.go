@@ -0,0 +1,17 @@
+//go:build unix
+
+package bbr
+
+import (
+	"syscall"
+	"time"
+)
+
+// 进程消耗的CPU时间（用户态+内核态）
+func processCPUTime() (time.Duration, bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, false
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano()), true
+}
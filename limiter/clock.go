@@ -0,0 +1,159 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// 时钟
+// 限流器通过Clock获取当前时间和创建定时器，测试时可以使用ManualClock手动控制时间，不需要真的等待
+type Clock interface {
+	// 当前时间
+	Now() time.Time
+	// 创建在d之后触发的定时器
+	NewTimer(d time.Duration) Timer
+}
+
+// 定时器
+type Timer interface {
+	// 定时器触发时，从该channel中收到触发的时间
+	C() <-chan time.Time
+	// 停止定时器，返回false表示定时器已经触发或已经停止
+	Stop() bool
+}
+
+// 真实时钟，使用time包
+type RealClock struct{}
+
+// 当前时间
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// 创建在d之后触发的定时器
+func (RealClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// 真实定时器
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+// 手动时钟
+// 时间只在调用Advance、Set时变化，到期的定时器随之触发，用于编写确定性的测试
+type ManualClock struct {
+	mu     sync.Mutex // 所有修改 now、timers的操作均在 mu锁保护下进行
+	now    time.Time
+	timers []*manualTimer // 还未触发的定时器
+}
+
+// 生成手动时钟，初始时间为t
+func NewManualClock(t time.Time) *ManualClock {
+	return &ManualClock{now: t}
+}
+
+// 当前时间
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// 创建在d之后触发的定时器，d<=0时立即触发
+func (c *ManualClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &manualTimer{
+		clock:    c,
+		c:        make(chan time.Time, 1),
+		deadline: c.now.Add(d),
+	}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// 时间前进d，触发所有到期的定时器
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(c.now.Add(d))
+}
+
+// 设置当前时间，触发所有到期的定时器
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(t)
+}
+
+// 还未触发的定时器数量
+func (c *ManualClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// 阻塞直到至少有n个还未触发的定时器
+// 测试中用于等待其他goroutine进入等待状态，再推进时间
+func (c *ManualClock) BlockUntil(n int) {
+	for c.Timers() < n {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 设置当前时间，调用方需要持有锁
+func (c *ManualClock) setLocked(t time.Time) {
+	c.now = t
+
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.deadline.After(t) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.c <- t
+	}
+	// 清理引用，避免内存泄漏
+	for i := len(pending); i < len(c.timers); i++ {
+		c.timers[i] = nil
+	}
+	c.timers = pending
+}
+
+// 手动定时器
+type manualTimer struct {
+	clock    *ManualClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *manualTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 分布式令牌桶
+// 令牌桶的状态保存在Store中，多个服务副本使用同一个Store和key时，共享同一份配额
+// 令牌按存储的时钟生成：redisstore.RedisStore使用Redis的时间，副本之间的时钟偏差不影响精度；
+// MemoryStore使用调用方传入的时间
+type DistributedBucket struct {
+	store    Store
+	key      string   // 令牌桶在存储中的key
+	limit    Limit    // 令牌生成速率
+	capacity int      // 桶的容量
+	clock    Clock    // 时钟
+	stats    counters // 统计信息
+}
+
+// 生成分布式令牌桶
+// 可选配置：WithClock
+func NewDistributed(store Store, key string, limit Limit, capacity int, opts ...Option) *DistributedBucket {
+	o := newOptions(opts...)
+	return &DistributedBucket{
+		store:    store,
+		key:      key,
+		limit:    limit,
+		capacity: capacity,
+		clock:    o.clock,
+	}
+}
+
+// 是否允许执行事件
+func (lim *DistributedBucket) Allow() bool {
+	return lim.AllowN(lim.clock.Now(), 1)
+}
+
+// 是否允许在时间t执行n个事件
+// 存储不可用时放行，避免限流器成为单点故障
+func (lim *DistributedBucket) AllowN(t time.Time, n int) bool {
+	_, ok, err := lim.TakeN(context.Background(), t, n)
+	if err != nil {
+		ok = true
+	}
+	lim.stats.record(ok)
+	return ok
+}
+
+// 阻塞等待，直到获取到1个令牌
+func (lim *DistributedBucket) Wait(ctx context.Context) error {
+	return lim.WaitN(ctx, 1)
+}
+
+// 阻塞等待，直到获取到n个令牌
+// 和AllowN不同，存储出错时返回错误
+func (lim *DistributedBucket) WaitN(ctx context.Context, n int) error {
+	if n > lim.capacity && lim.limit != Inf {
+		lim.stats.record(false)
+		return fmt.Errorf("limiter: WaitN(n=%d) 超过了桶的容量 %d", n, lim.capacity)
+	}
+
+	var storeErr error
+	err := waitLoop(ctx, lim.clock, &lim.stats, func(t time.Time) (time.Duration, bool) {
+		wait, ok, err := lim.TakeN(ctx, t, n)
+		if err != nil {
+			storeErr = err
+			return 0, true
+		}
+		return wait, ok
+	})
+	if storeErr != nil {
+		err = storeErr
+	}
+	lim.stats.record(err == nil)
+	return err
+}
+
+// 统计信息
+func (lim *DistributedBucket) Stats() Stats {
+	return lim.stats.snapshot()
+}
+
+// 核心代码
+// 在时间t获取n个令牌，令牌不足时返回还需要等待的时间
+func (lim *DistributedBucket) TakeN(ctx context.Context, t time.Time, n int) (time.Duration, bool, error) {
+	// 不限速直接返回，不访问存储
+	if lim.limit == Inf {
+		return 0, true, nil
+	}
+	return lim.store.TakeN(ctx, lim.key, lim.limit, lim.capacity, t, n)
+}
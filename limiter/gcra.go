@@ -0,0 +1,133 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// GCRA（Generic Cell Rate Algorithm，通用信元速率算法）
+// 整个状态只有一个时间戳：理论到达时间 tat（Theoretical Arrival Time），表示按固定速率放行时，桶恢复为满桶的时间。
+// 每次请求n个令牌，tat向后推移 n*放行间隔；tat超出当前时间太多（超过 burst*放行间隔）时拒绝。
+// 状态只有一个时间戳，适合存储在远程存储（如Redis）中，通过CAS更新。
+type GCRA struct {
+	mu    sync.Mutex // 所有修改 tat的操作均在 mu锁保护下进行
+	limit Limit      // 令牌生成速率，和令牌桶的含义相同
+	burst int        // 桶的容量，和令牌桶的含义相同：满桶时最多一次性放行burst个事件
+	tat   time.Time  // 理论到达时间
+	clock Clock      // 时钟
+	stats counters   // 统计信息
+}
+
+// GCRA的计算结果
+type Result struct {
+	Allowed    bool          // 是否允许
+	Remaining  int           // 剩余可用的令牌数
+	RetryAfter time.Duration // 被拒绝时，多久之后可以重试；允许时为0
+	ResetAfter time.Duration // 多久之后恢复为满桶
+}
+
+// 生成GCRA限流器
+// 可选配置：WithClock
+func NewGCRA(limit Limit, burst int, opts ...Option) *GCRA {
+	o := newOptions(opts...)
+	return &GCRA{
+		limit: limit,
+		burst: burst,
+		clock: o.clock,
+	}
+}
+
+// 是否允许执行事件
+func (lim *GCRA) Allow() bool {
+	return lim.AllowN(lim.clock.Now(), 1)
+}
+
+// 是否允许在时间t执行n个事件
+func (lim *GCRA) AllowN(t time.Time, n int) bool {
+	allowed := lim.RateLimitN(t, n).Allowed
+	lim.stats.record(allowed)
+	return allowed
+}
+
+// 阻塞等待，直到允许执行事件
+func (lim *GCRA) Wait(ctx context.Context) error {
+	return lim.WaitN(ctx, 1)
+}
+
+// 阻塞等待，直到允许执行n个事件
+func (lim *GCRA) WaitN(ctx context.Context, n int) error {
+	if n > lim.burst && lim.limit != Inf {
+		lim.stats.record(false)
+		return fmt.Errorf("limiter: WaitN(n=%d) 超过了桶的容量 %d", n, lim.burst)
+	}
+	err := waitLoop(ctx, lim.clock, &lim.stats, func(t time.Time) (time.Duration, bool) {
+		res := lim.RateLimitN(t, n)
+		return res.RetryAfter, res.Allowed
+	})
+	lim.stats.record(err == nil)
+	return err
+}
+
+// 统计信息
+func (lim *GCRA) Stats() Stats {
+	return lim.stats.snapshot()
+}
+
+// 在时间t申请n个令牌，返回详细的计算结果
+func (lim *GCRA) RateLimitN(t time.Time, n int) Result {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	tat, res := lim.Evaluate(lim.tat, t, n)
+	lim.tat = tat
+	return res
+}
+
+// 核心代码
+// 根据理论到达时间tat，计算在时间t申请n个令牌的结果，返回新的tat
+// 不修改限流器自身的状态，使用远程存储时：读取tat -> Evaluate -> CAS写回新的tat
+func (lim *GCRA) Evaluate(tat time.Time, t time.Time, n int) (time.Time, Result) {
+	// 不限速直接返回
+	if lim.limit == Inf {
+		return tat, Result{Allowed: true, Remaining: lim.burst}
+	}
+
+	// 速率为0，永远不会放行
+	interval := lim.limit.durationFromTokens(1)
+	if interval == InfDuration {
+		return tat, Result{RetryAfter: InfDuration, ResetAfter: InfDuration}
+	}
+
+	// tat早于当前时间，说明桶已经是满的
+	if tat.Before(t) {
+		tat = t
+	}
+	tolerance := time.Duration(lim.burst) * interval
+
+	// 当前剩余的令牌数
+	remaining := func(tat time.Time) int {
+		return int(t.Add(tolerance).Sub(tat) / interval)
+	}
+
+	newTat := tat.Add(time.Duration(n) * interval)
+	allowAt := newTat.Add(-tolerance)
+	if t.Before(allowAt) {
+		retryAfter := allowAt.Sub(t)
+		if n > lim.burst {
+			retryAfter = InfDuration
+		}
+		return tat, Result{
+			Remaining:  remaining(tat),
+			RetryAfter: retryAfter,
+			ResetAfter: tat.Sub(t),
+		}
+	}
+
+	return newTat, Result{
+		Allowed:    true,
+		Remaining:  remaining(newTat),
+		ResetAfter: newTat.Sub(t),
+	}
+}
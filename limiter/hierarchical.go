@@ -0,0 +1,274 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 多层级限流的一个层级
+type Tier struct {
+	Name   string        // 层级名称，请求被拒绝时返回
+	Bucket *TokenBucket  // 所有请求共用一个令牌桶，如全局限流
+	Keyed  *KeyedLimiter // 按key使用不同的令牌桶，如按用户、按租户限流；设置了Bucket时忽略
+}
+
+// 多层级限流的结果
+type Decision struct {
+	Allowed    bool          // 是否允许执行
+	Tier       string        // 拒绝请求的层级，允许执行时为空
+	RetryAfter time.Duration // 被拒绝时，多久之后所有层级都有足够的令牌；n超过任意一个层级的桶容量时为InfDuration
+	States     []TierState   // 做出决定时每个层级的令牌桶的状态，按层级的顺序
+	tier       int           // 拒绝请求的层级的下标
+}
+
+// 做出决定时一个层级的令牌桶的状态，和决定在同一把锁下、按同一个时间计算
+type TierState struct {
+	Limit  Limit   // 令牌生成速率
+	Burst  int     // 桶的容量
+	Tokens float64 // 桶中剩余的令牌数：允许执行时为扣减之后的令牌数，拒绝时为当时的令牌数（没有扣减）
+}
+
+// 多久之后恢复为满桶
+func (s TierState) ResetAfter() time.Duration {
+	if s.Limit == Inf || s.Tokens >= float64(s.Burst) {
+		return 0
+	}
+	return s.Limit.durationFromTokens(float64(s.Burst) - s.Tokens)
+}
+
+// 多层级限流
+// 一个请求需要同时满足多个层级的限流，如 每个用户10个/s、每个租户1000个/s、全局5000个/s
+// 所有层级的令牌桶在同一把锁下检查和扣减：任意一个层级令牌不足时，所有层级的令牌都不会被扣减
+type HierarchicalLimiter struct {
+	tiers []Tier
+	clock Clock
+	stats counters // 统计信息
+}
+
+// 生成多层级限流器，tiers按从内到外的顺序排列，多个层级同时拒绝时返回排在前面的层级
+// 可选配置：WithClock
+func NewHierarchical(tiers []Tier, opts ...Option) *HierarchicalLimiter {
+	o := newOptions(opts...)
+	for _, tier := range tiers {
+		if tier.Bucket == nil && tier.Keyed == nil {
+			panic(fmt.Sprintf("limiter: 层级 %s 未设置令牌桶", tier.Name))
+		}
+	}
+	return &HierarchicalLimiter{
+		tiers: tiers,
+		clock: o.clock,
+	}
+}
+
+// 是否允许执行事件
+// keys：按顺序对应每个层级的key，Bucket层级的key会被忽略
+func (h *HierarchicalLimiter) Allow(keys ...string) Decision {
+	return h.AllowN(h.clock.Now(), 1, keys...)
+}
+
+// 是否允许在时间t执行n个事件
+func (h *HierarchicalLimiter) AllowN(t time.Time, n int, keys ...string) Decision {
+	buckets := h.buckets(t, keys)
+	_, d := h.reserveN(buckets, t, n, 0)
+	h.record(buckets, d)
+	h.stats.record(d.Allowed)
+	return d
+}
+
+// 阻塞等待，直到所有层级都获取到1个令牌
+func (h *HierarchicalLimiter) Wait(ctx context.Context, keys ...string) error {
+	return h.WaitN(ctx, 1, keys...)
+}
+
+// 阻塞等待，直到所有层级都获取到n个令牌
+// ctx被取消时，归还所有层级已经预定的令牌
+func (h *HierarchicalLimiter) WaitN(ctx context.Context, n int, keys ...string) error {
+	err := h.waitN(ctx, n, keys)
+	h.stats.record(err == nil)
+	return err
+}
+
+// 统计信息
+// 每个层级的令牌桶也会记录：允许执行时所有层级都记为允许，拒绝时只有拒绝请求的层级记为拒绝
+func (h *HierarchicalLimiter) Stats() Stats {
+	return h.stats.snapshot()
+}
+
+// 阻塞等待，直到所有层级都获取到n个令牌
+func (h *HierarchicalLimiter) waitN(ctx context.Context, n int, keys []string) error {
+	now := h.clock.Now()
+	buckets := h.buckets(now, keys)
+
+	// 申请的令牌数超过桶的容量，永远无法满足
+	for i, b := range buckets {
+		if burst, limit := b.Burst(), b.Limit(); n > burst && limit != Inf {
+			b.stats.record(false)
+			return fmt.Errorf("limiter: WaitN(n=%d) 超过了层级 %s 的桶容量 %d", n, h.tiers[i].Name, burst)
+		}
+	}
+
+	// 先检查ctx是否已取消
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 根据ctx的截止时间计算最多可以等待多久
+	waitLimit := InfDuration
+	if deadline, ok := ctx.Deadline(); ok {
+		waitLimit = deadline.Sub(now)
+	}
+
+	// 预定令牌，截止时间之前无法生成足够的令牌时，不再等待
+	reservations, d := h.reserveN(buckets, now, n, waitLimit)
+	if !d.Allowed {
+		h.record(buckets, d)
+		return context.DeadlineExceeded
+	}
+
+	// 需要等待到所有层级都允许执行
+	var delay time.Duration
+	for _, r := range reservations {
+		if rd := r.DelayFrom(now); rd > delay {
+			delay = rd
+		}
+	}
+	if delay == 0 {
+		h.record(buckets, d)
+		return nil
+	}
+	timer := h.clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		// 到达预定的执行时间
+		h.stats.recordWait(h.clock.Now().Sub(now))
+		h.record(buckets, d)
+		return nil
+	case <-ctx.Done():
+		// 已取消，按相反的顺序归还所有层级预定的令牌
+		cancelAt := h.clock.Now()
+		for i := len(reservations) - 1; i >= 0; i-- {
+			reservations[i].CancelAt(cancelAt)
+		}
+		h.stats.recordWait(cancelAt.Sub(now))
+		return ctx.Err()
+	}
+}
+
+// 记录每个层级的统计信息
+func (h *HierarchicalLimiter) record(buckets []*TokenBucket, d Decision) {
+	if !d.Allowed {
+		buckets[d.tier].stats.record(false)
+		return
+	}
+	for _, b := range buckets {
+		b.stats.record(true)
+	}
+}
+
+// 每个层级在时间t使用的令牌桶
+func (h *HierarchicalLimiter) buckets(t time.Time, keys []string) []*TokenBucket {
+	buckets := make([]*TokenBucket, len(h.tiers))
+	for i, tier := range h.tiers {
+		if tier.Bucket != nil {
+			buckets[i] = tier.Bucket
+			continue
+		}
+		var key string
+		if i < len(keys) {
+			key = keys[i]
+		}
+		buckets[i] = tier.Keyed.get(key, t)
+	}
+	return buckets
+}
+
+// 核心代码
+// 在所有层级预定n个令牌
+// 先锁住所有的令牌桶，检查每个层级的令牌是否足够，全部满足时才扣减，不会出现部分层级已扣减的中间状态
+// maxFutureReserve：最多允许等待的时间，为0时不允许欠账
+func (h *HierarchicalLimiter) reserveN(buckets []*TokenBucket, t time.Time, n int, maxFutureReserve time.Duration) ([]*Reservation, Decision) {
+	unlock := lockBuckets(buckets)
+	defer unlock()
+
+	// 检查每个层级
+	// 有层级拒绝时继续检查后面的层级：重试时所有层级都要有足够的令牌，RetryAfter取所有层级中最长的等待时间
+	lasts := make([]time.Time, len(buckets))
+	remains := make([]float64, len(buckets))
+	waits := make([]time.Duration, len(buckets))
+	states := make([]TierState, len(buckets))
+	denied := -1
+	var retryAfter time.Duration
+	for i, b := range buckets {
+		states[i] = TierState{Limit: b.limit, Burst: b.capacity, Tokens: float64(b.capacity)}
+		if b.limit == Inf {
+			continue
+		}
+
+		last, tokens := b.advance(t)
+		states[i].Tokens = tokens
+		wait := InfDuration
+		if n <= b.capacity {
+			tokens -= float64(n)
+			wait = 0
+			if tokens < 0 {
+				wait = b.limit.durationFromTokens(-tokens)
+			}
+			lasts[i], remains[i], waits[i] = last, tokens, wait
+		}
+		if (wait == InfDuration || wait > maxFutureReserve) && denied < 0 {
+			denied = i
+		}
+		if wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if denied >= 0 {
+		return nil, Decision{Tier: h.tiers[denied].Name, RetryAfter: retryAfter, States: states, tier: denied}
+	}
+
+	// 所有层级都满足，扣减令牌
+	reservations := make([]*Reservation, len(buckets))
+	for i, b := range buckets {
+		r := &Reservation{
+			ok:        true,
+			lim:       b,
+			tokens:    n,
+			timeToAct: t,
+			limit:     b.limit,
+		}
+		if b.limit != Inf {
+			r.timeToAct = lasts[i].Add(waits[i])
+			b.tokens = remains[i]
+			b.last = lasts[i]
+			b.lastEvent = r.timeToAct
+			states[i].Tokens = remains[i]
+		}
+		reservations[i] = r
+	}
+	return reservations, Decision{Allowed: true, States: states}
+}
+
+// 按编号从小到大锁住所有的令牌桶，返回解锁函数
+// 所有同时锁多个桶的地方都按相同的顺序加锁，避免死锁；同一个桶只锁一次，避免重复加锁
+func lockBuckets(buckets []*TokenBucket) (unlock func()) {
+	sorted := make([]*TokenBucket, len(buckets))
+	copy(sorted, buckets)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].id < sorted[j].id })
+
+	locked := sorted[:0]
+	for _, b := range sorted {
+		if len(locked) > 0 && locked[len(locked)-1] == b {
+			continue
+		}
+		b.mu.Lock()
+		locked = append(locked, b)
+	}
+	return func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			locked[i].mu.Unlock()
+		}
+	}
+}
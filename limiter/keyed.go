@@ -0,0 +1,228 @@
+package limiter
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 按key限流
+// 按用户、IP、API Key等维度限流时，每个key使用一个独立的令牌桶，所有桶使用相同的速率和容量
+// 桶在第一次使用时创建；满桶且空闲超过idleTTL的桶会被清理，清理满桶不会丢失状态（重新创建的桶也是满桶）
+//
+// 设置了key的数量上限时，所有分片的key总数不超过上限，只会淘汰满桶：淘汰未满的桶会重置它的配额，
+// 客户端可以用大量新key把自己被限流的桶挤出去。新key所在的分片没有可以淘汰的满桶时，新的key共用一个溢出桶
+type KeyedLimiter struct {
+	limit    Limit // 每个桶的令牌生成速率
+	burst    int   // 每个桶的容量
+	idleTTL  time.Duration
+	maxKeys  int          // 所有分片最多保存多少个key的桶，0表示不限制
+	keys     atomic.Int64 // 所有分片保存的key的数量
+	seed     maphash.Seed
+	shards   []*keyedShard
+	overflow *TokenBucket // 溢出桶，key的数量达到上限且没有可以淘汰的满桶时，新的key共用
+	clock    Clock        // 时钟，创建的令牌桶使用相同的时钟
+	stats    counters     // 统计信息，所有桶的统计累加到这里，桶被清理后不会丢失
+}
+
+// 分片，每个分片使用独立的锁，减少高并发时的锁竞争
+type keyedShard struct {
+	mu        sync.Mutex // 所有修改 buckets、lastSweep的操作均在 mu锁保护下进行
+	buckets   map[string]*keyedEntry
+	lastSweep time.Time // 上次清理的时间
+}
+
+// 分片中的一个桶
+type keyedEntry struct {
+	bucket   *TokenBucket
+	lastSeen time.Time // 最近一次使用的时间
+}
+
+// 生成按key限流的限流器
+// 可选配置：WithIdleTTL、WithMaxKeys、WithShards、WithClock
+func NewKeyed(limit Limit, burst int, opts ...Option) *KeyedLimiter {
+	o := newOptions(opts...)
+	lim := &KeyedLimiter{
+		limit:   limit,
+		burst:   burst,
+		idleTTL: o.idleTTL,
+		maxKeys: o.maxKeys,
+		seed:    maphash.MakeSeed(),
+		shards:  make([]*keyedShard, o.shards),
+		clock:   o.clock,
+	}
+	lim.overflow = New(limit, burst, WithClock(o.clock))
+	lim.overflow.stats.parent = &lim.stats
+
+	for i := range lim.shards {
+		lim.shards[i] = &keyedShard{
+			buckets: make(map[string]*keyedEntry),
+		}
+	}
+	return lim
+}
+
+// 获取key对应的令牌桶，不存在时创建
+func (lim *KeyedLimiter) Get(key string) *TokenBucket {
+	return lim.get(key, lim.clock.Now())
+}
+
+// key是否允许执行事件
+func (lim *KeyedLimiter) Allow(key string) bool {
+	return lim.AllowN(key, lim.clock.Now(), 1)
+}
+
+// key是否允许在时间t执行n个事件
+func (lim *KeyedLimiter) AllowN(key string, t time.Time, n int) bool {
+	return lim.get(key, t).AllowN(t, n)
+}
+
+// 为key预定在时间t执行n个事件
+func (lim *KeyedLimiter) ReserveN(key string, t time.Time, n int) *Reservation {
+	return lim.get(key, t).ReserveN(t, n)
+}
+
+// 阻塞等待，直到key获取到1个令牌
+func (lim *KeyedLimiter) Wait(ctx context.Context, key string) error {
+	return lim.WaitN(ctx, key, 1)
+}
+
+// 阻塞等待，直到key获取到n个令牌
+func (lim *KeyedLimiter) WaitN(ctx context.Context, key string, n int) error {
+	return lim.Get(key).WaitN(ctx, n)
+}
+
+// 当前保存的key的数量，不包括溢出桶
+func (lim *KeyedLimiter) Len() int {
+	total := 0
+	for _, shard := range lim.shards {
+		shard.mu.Lock()
+		total += len(shard.buckets)
+		shard.mu.Unlock()
+	}
+	return total
+}
+
+// 所有key的统计信息之和，包括已经被清理的桶
+func (lim *KeyedLimiter) Stats() Stats {
+	return lim.stats.snapshot()
+}
+
+// 遍历当前保存的所有key和对应的令牌桶，f返回false时停止遍历
+// 遍历时持有分片的锁，f中不能调用该限流器的方法
+func (lim *KeyedLimiter) Range(f func(key string, bucket *TokenBucket) bool) {
+	for _, shard := range lim.shards {
+		shard.mu.Lock()
+		for key, e := range shard.buckets {
+			if !f(key, e.bucket) {
+				shard.mu.Unlock()
+				return
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// 清理所有满桶且空闲超过idleTTL的桶
+func (lim *KeyedLimiter) Cleanup() {
+	now := lim.clock.Now()
+	for _, shard := range lim.shards {
+		shard.mu.Lock()
+		lim.sweep(shard, now)
+		shard.mu.Unlock()
+	}
+}
+
+// 核心代码
+// 在时间t获取key对应的令牌桶
+func (lim *KeyedLimiter) get(key string, t time.Time) *TokenBucket {
+	shard := lim.shards[maphash.String(lim.seed, key)%uint64(len(lim.shards))]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	// 惰性清理：距离上次清理超过idleTTL时，顺便清理一次分片
+	if t.Sub(shard.lastSweep) >= lim.idleTTL {
+		lim.sweep(shard, t)
+	}
+
+	if e, ok := shard.buckets[key]; ok {
+		if t.After(e.lastSeen) {
+			e.lastSeen = t
+		}
+		return e.bucket
+	}
+
+	// 达到key的数量上限，先清理分片中空闲的桶，仍然达到上限时淘汰分片中最久未使用的满桶，新的key使用它的名额
+	// 没有满桶时不淘汰，新的key使用溢出桶，已有key的配额不会被重置
+	if !lim.reserveKey() {
+		lim.sweep(shard, t)
+		if !lim.reserveKey() && !lim.evictOldestFull(shard, t) {
+			return lim.overflow
+		}
+	}
+
+	bucket := New(lim.limit, lim.burst, WithClock(lim.clock))
+	bucket.stats.parent = &lim.stats
+	e := &keyedEntry{
+		bucket:   bucket,
+		lastSeen: t,
+	}
+	shard.buckets[key] = e
+	return e.bucket
+}
+
+// 清理分片中满桶且空闲超过idleTTL的桶，调用方需要持有分片的锁
+func (lim *KeyedLimiter) sweep(shard *keyedShard, t time.Time) {
+	shard.lastSweep = t
+	for key, e := range shard.buckets {
+		if t.Sub(e.lastSeen) < lim.idleTTL {
+			continue
+		}
+		if e.bucket.tokensAt(t) >= float64(e.bucket.Burst()) {
+			delete(shard.buckets, key)
+			lim.keys.Add(-1)
+		}
+	}
+}
+
+// 为新的key占用一个名额，达到key的数量上限时返回false
+func (lim *KeyedLimiter) reserveKey() bool {
+	if lim.maxKeys <= 0 {
+		lim.keys.Add(1)
+		return true
+	}
+	for {
+		n := lim.keys.Load()
+		if n >= int64(lim.maxKeys) {
+			return false
+		}
+		if lim.keys.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// 淘汰分片中最久未使用的满桶，淘汰的桶的名额留给新的key，没有满桶时返回false，调用方需要持有分片的锁
+func (lim *KeyedLimiter) evictOldestFull(shard *keyedShard, t time.Time) bool {
+	var (
+		oldestKey string
+		oldest    *keyedEntry
+	)
+	for key, e := range shard.buckets {
+		if e.bucket.tokensAt(t) < float64(e.bucket.Burst()) {
+			continue
+		}
+		if oldest == nil || e.lastSeen.Before(oldest.lastSeen) {
+			oldestKey = key
+			oldest = e
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+	delete(shard.buckets, oldestKey)
+	return true
+}
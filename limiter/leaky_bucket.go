@@ -0,0 +1,168 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// 排队的调用方已满
+var ErrQueueFull = errors.New("limiter: 排队的调用方已满")
+
+// 漏桶
+// 调用方在桶中排队，按固定的时间间隔依次放行，突发流量会被平滑为恒定的速率
+type LeakyBucket struct {
+	mu        sync.Mutex    // 所有修改 next的操作均在 mu锁保护下进行
+	interval  time.Duration // 相邻两个调用方被放行的时间间隔，由令牌生成速率计算
+	queueSize int           // 最多允许排队的调用方数
+	next      time.Time     // 下一个调用方可以被放行的时间
+	clock     Clock         // 时钟
+	stats     counters      // 统计信息
+}
+
+// 生成漏桶
+// limit：放行速率，如 Every(100*time.Millisecond) 表示每100毫秒放行一个调用方
+// queueSize：最多允许排队的调用方数，为0时不排队
+// 可选配置：WithClock
+func NewLeakyBucket(limit Limit, queueSize int, opts ...Option) *LeakyBucket {
+	o := newOptions(opts...)
+	var interval time.Duration
+	if limit != Inf {
+		interval = limit.durationFromTokens(1)
+	}
+	return &LeakyBucket{
+		interval:  interval,
+		queueSize: queueSize,
+		clock:     o.clock,
+	}
+}
+
+// 排队等待放行，返回被放行的时间
+// 排队的调用方已满时返回ErrQueueFull；ctx被取消或者在ctx的截止时间之前无法被放行时，返回错误
+func (lim *LeakyBucket) Take(ctx context.Context) (time.Time, error) {
+	slot, err := lim.take(ctx)
+	lim.stats.record(err == nil)
+	return slot, err
+}
+
+// 统计信息
+func (lim *LeakyBucket) Stats() Stats {
+	return lim.stats.snapshot()
+}
+
+// 排队等待放行
+func (lim *LeakyBucket) take(ctx context.Context) (time.Time, error) {
+	// 先检查ctx是否已取消
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	now := lim.clock.Now()
+	slot, err := lim.reserve(now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return slot, nil
+	}
+
+	// 截止时间之前无法被放行，不再排队
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(slot) {
+		lim.cancel(slot)
+		return time.Time{}, context.DeadlineExceeded
+	}
+
+	timer := lim.clock.NewTimer(delay)
+	defer timer.Stop()
+	defer func() {
+		lim.stats.recordWait(lim.clock.Now().Sub(now))
+	}()
+	select {
+	case <-timer.C():
+		return slot, nil
+	case <-ctx.Done():
+		// 已取消，让出排队的位置
+		lim.cancel(slot)
+		return time.Time{}, ctx.Err()
+	}
+}
+
+// 是否允许执行事件
+func (lim *LeakyBucket) Allow() bool {
+	return lim.AllowN(lim.clock.Now(), 1)
+}
+
+// 是否允许在时间t执行n个事件，不排队
+// 允许时占用n个放行间隔
+func (lim *LeakyBucket) AllowN(t time.Time, n int) bool {
+	ok := lim.allowN(t, n)
+	lim.stats.record(ok)
+	return ok
+}
+
+// 阻塞等待，直到被放行
+func (lim *LeakyBucket) Wait(ctx context.Context) error {
+	_, err := lim.Take(ctx)
+	return err
+}
+
+// 不排队，尝试在时间t放行n个事件
+func (lim *LeakyBucket) allowN(t time.Time, n int) bool {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	if lim.interval == 0 {
+		return true
+	}
+	if lim.interval == InfDuration || lim.next.After(t) {
+		return false
+	}
+	lim.next = t.Add(time.Duration(n) * lim.interval)
+	return true
+}
+
+// 核心代码
+// 在时间t排队，返回被放行的时间
+func (lim *LeakyBucket) reserve(t time.Time) (time.Time, error) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	// 不限速直接放行
+	if lim.interval == 0 {
+		return t, nil
+	}
+	// 速率为0，永远不会放行
+	if lim.interval == InfDuration {
+		return time.Time{}, ErrQueueFull
+	}
+
+	// 桶是空的，立即放行
+	if !lim.next.After(t) {
+		lim.next = t.Add(lim.interval)
+		return t, nil
+	}
+
+	// 正在排队的调用方数：已分配但还未到放行时间的位置
+	queued := int((lim.next.Sub(t)+lim.interval-1)/lim.interval) - 1
+	if queued+1 > lim.queueSize {
+		return time.Time{}, ErrQueueFull
+	}
+
+	slot := lim.next
+	lim.next = slot.Add(lim.interval)
+	return slot, nil
+}
+
+// 取消排队，让出放行的位置
+// 只有排在最后的调用方可以让出位置，中间的位置会空出来，不会影响其他调用方的放行时间
+func (lim *LeakyBucket) cancel(slot time.Time) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	if slot.Add(lim.interval).Equal(lim.next) {
+		lim.next = slot
+	}
+}
@@ -0,0 +1,69 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// 限流器接口
+// 令牌桶、滑动窗口等限流算法都实现了该接口，调用方可以按场景替换限流算法
+type Limiter interface {
+	// 是否允许执行事件
+	Allow() bool
+	// 是否允许在时间t执行n个事件
+	AllowN(t time.Time, n int) bool
+	// 阻塞等待，直到允许执行事件
+	Wait(ctx context.Context) error
+}
+
+var (
+	_ Limiter = (*TokenBucket)(nil)
+	_ Limiter = (*SlidingWindowLog)(nil)
+	_ Limiter = (*SlidingWindowCounter)(nil)
+	_ Limiter = (*LeakyBucket)(nil)
+	_ Limiter = (*GCRA)(nil)
+	_ Limiter = (*DistributedBucket)(nil)
+	_ Limiter = (*PriorityLimiter)(nil)
+)
+
+// 循环等待，直到try获取成功
+// try：尝试在时间t获取，失败时返回还需要等待的时间
+// stats：记录阻塞等待的次数和时间
+func waitLoop(ctx context.Context, clock Clock, stats *counters, try func(t time.Time) (time.Duration, bool)) error {
+	var waitStart time.Time
+	defer func() {
+		if !waitStart.IsZero() {
+			stats.recordWait(clock.Now().Sub(waitStart))
+		}
+	}()
+
+	for {
+		// 先检查ctx是否已取消
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		now := clock.Now()
+		wait, ok := try(now)
+		if ok {
+			return nil
+		}
+
+		// 截止时间之前无法获取，不再等待
+		if deadline, has := ctx.Deadline(); has && deadline.Sub(now) < wait {
+			return context.DeadlineExceeded
+		}
+
+		if waitStart.IsZero() {
+			waitStart = now
+		}
+		timer := clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+			// 重新尝试获取
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
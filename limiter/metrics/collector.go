@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"limiter"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 超过key数量上限的key合并到该标签值
+const OtherKey = "__other__"
+
+// 限流器的Prometheus采集器
+// 每次采集时读取限流器的Stats()，不需要在限流的路径上额外记录指标
+//
+// 导出的指标（namespace为空时没有前缀）：
+//   - limiter_requests_total{limiter, result}：允许/拒绝的次数，result为allowed或denied
+//   - limiter_waits_total{limiter}：Wait中需要阻塞等待的次数
+//   - limiter_wait_seconds_total{limiter}：Wait中阻塞等待的总时间
+//   - limiter_keys{limiter}：按key限流当前保存的key的数量
+//   - limiter_key_requests_total{limiter, key, result}：按key限流每个key允许/拒绝的次数
+type Collector struct {
+	mu       sync.Mutex // 所有修改 limiters、keyed的操作均在 mu锁保护下进行
+	limiters []namedLimiter
+	keyed    []*keyedLimiter
+
+	requests    *prometheus.Desc
+	waits       *prometheus.Desc
+	waitSeconds *prometheus.Desc
+	keys        *prometheus.Desc
+	keyRequests *prometheus.Desc
+}
+
+// 注册到采集器的限流器
+type namedLimiter struct {
+	name string
+	lim  limiter.StatsProvider
+}
+
+// 注册到采集器的按key限流的限流器
+type keyedLimiter struct {
+	name    string
+	lim     *limiter.KeyedLimiter
+	maxKeys int                      // 最多导出多少个key的指标
+	tracked map[string]limiter.Stats // 已经导出指标的key，以及上次采集时的统计信息
+	total   limiter.Stats            // 上次采集时限流器汇总的统计信息
+	other   limiter.Stats            // 合并到__other__的计数，只累加增量，保证单调递增
+}
+
+// 生成采集器
+// namespace：指标名的前缀，如服务名
+func New(namespace string) *Collector {
+	name := func(n string) string {
+		return prometheus.BuildFQName(namespace, "limiter", n)
+	}
+	return &Collector{
+		requests: prometheus.NewDesc(name("requests_total"),
+			"Number of requests allowed or denied by the limiter.", []string{"limiter", "result"}, nil),
+		waits: prometheus.NewDesc(name("waits_total"),
+			"Number of Wait calls that blocked.", []string{"limiter"}, nil),
+		waitSeconds: prometheus.NewDesc(name("wait_seconds_total"),
+			"Total time spent blocked in Wait.", []string{"limiter"}, nil),
+		keys: prometheus.NewDesc(name("keys"),
+			"Number of keys currently held by the keyed limiter.", []string{"limiter"}, nil),
+		keyRequests: prometheus.NewDesc(name("key_requests_total"),
+			"Number of requests allowed or denied per key.", []string{"limiter", "key", "result"}, nil),
+	}
+}
+
+// 添加限流器，name作为limiter标签的值
+func (c *Collector) Add(name string, lim limiter.StatsProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limiters = append(c.limiters, namedLimiter{name: name, lim: lim})
+}
+
+// 添加按key限流的限流器，除了汇总的指标，还导出每个key的指标
+// maxKeys：最多导出多少个key，避免key过多时指标的基数过大。
+// 先出现的key一直导出，直到被限流器清理；超出的key合并到key="__other__"。
+// __other__每次采集时累加 汇总的增量 - 已导出的key的增量，已导出的key被清理时不会回到__other__，保证counter单调递增
+func (c *Collector) AddKeyed(name string, lim *limiter.KeyedLimiter, maxKeys int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limiters = append(c.limiters, namedLimiter{name: name, lim: lim})
+	c.keyed = append(c.keyed, &keyedLimiter{
+		name:    name,
+		lim:     lim,
+		maxKeys: maxKeys,
+		tracked: make(map[string]limiter.Stats),
+	})
+}
+
+// 实现prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.requests
+	ch <- c.waits
+	ch <- c.waitSeconds
+	ch <- c.keys
+	ch <- c.keyRequests
+}
+
+// 实现prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, l := range c.limiters {
+		stats := l.lim.Stats()
+		ch <- prometheus.MustNewConstMetric(c.requests, prometheus.CounterValue, float64(stats.Allowed), l.name, "allowed")
+		ch <- prometheus.MustNewConstMetric(c.requests, prometheus.CounterValue, float64(stats.Denied), l.name, "denied")
+		ch <- prometheus.MustNewConstMetric(c.waits, prometheus.CounterValue, float64(stats.Waited), l.name)
+		ch <- prometheus.MustNewConstMetric(c.waitSeconds, prometheus.CounterValue, stats.WaitTime.Seconds(), l.name)
+	}
+	for _, k := range c.keyed {
+		c.collectKeyed(ch, k)
+	}
+}
+
+// 采集按key限流的指标
+func (c *Collector) collectKeyed(ch chan<- prometheus.Metric, k *keyedLimiter) {
+	var (
+		count   int
+		added   int
+		present = make(map[string]limiter.Stats, len(k.tracked))
+	)
+	k.lim.Range(func(key string, bucket *limiter.TokenBucket) bool {
+		count++
+		if _, ok := k.tracked[key]; ok {
+			present[key] = bucket.Stats()
+		} else if len(k.tracked)+added < k.maxKeys {
+			added++
+			present[key] = bucket.Stats()
+		}
+		return true
+	})
+	ch <- prometheus.MustNewConstMetric(c.keys, prometheus.GaugeValue, float64(count), k.name)
+
+	// 本次采集的增量：汇总 - 已导出的key
+	total := k.lim.Stats()
+	allowed, denied := total.Allowed-k.total.Allowed, total.Denied-k.total.Denied
+	for key, stats := range present {
+		prev := k.tracked[key]
+		if stats.Allowed < prev.Allowed || stats.Denied < prev.Denied {
+			// 桶被清理后重新创建，计数从0开始
+			prev = limiter.Stats{}
+		}
+		allowed -= min(allowed, stats.Allowed-prev.Allowed)
+		denied -= min(denied, stats.Denied-prev.Denied)
+		ch <- prometheus.MustNewConstMetric(c.keyRequests, prometheus.CounterValue, float64(stats.Allowed), k.name, key, "allowed")
+		ch <- prometheus.MustNewConstMetric(c.keyRequests, prometheus.CounterValue, float64(stats.Denied), k.name, key, "denied")
+	}
+	k.other.Allowed += allowed
+	k.other.Denied += denied
+	k.total = total
+	// 已经被限流器清理的key不再导出
+	k.tracked = present
+
+	if k.other.Allowed > 0 || k.other.Denied > 0 {
+		ch <- prometheus.MustNewConstMetric(c.keyRequests, prometheus.CounterValue, float64(k.other.Allowed), k.name, OtherKey, "allowed")
+		ch <- prometheus.MustNewConstMetric(c.keyRequests, prometheus.CounterValue, float64(k.other.Denied), k.name, OtherKey, "denied")
+	}
+}
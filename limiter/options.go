@@ -0,0 +1,129 @@
+package limiter
+
+import "time"
+
+// 默认配置
+const (
+	defaultShards  = 32
+	defaultIdleTTL = 10 * time.Minute
+)
+
+// 配置函数
+type Option func(o *options)
+
+// 限流器的配置，各个限流器只使用和自己相关的配置
+type options struct {
+	clock Clock // 时钟
+
+	shards  int           // 分片数，KeyedLimiter按key的哈希值分片加锁
+	idleTTL time.Duration // 桶空闲多久之后被清理
+	maxKeys int           // 最多保存多少个key的桶，0表示不限制
+
+	maxQueue int // 优先级限流器最多排队的调用方数，0表示不限制
+
+	initialLimit     int     // 自适应并发限流器的初始并发数
+	minLimit         int     // 自适应并发限流器的并发数下限
+	maxLimit         int     // 自适应并发限流器的并发数上限
+	backoffRatio     float64 // 自适应并发限流器乘法减小的系数
+	latencyTolerance float64 // 延迟超过基线的多少倍时视为延迟上升
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		clock:   RealClock{},
+		shards:  defaultShards,
+		idleTTL: defaultIdleTTL,
+
+		initialLimit:     defaultInitialLimit,
+		minLimit:         defaultMinLimit,
+		maxLimit:         defaultMaxLimit,
+		backoffRatio:     defaultBackoffRatio,
+		latencyTolerance: defaultLatencyTolerance,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// 设置时钟，测试时可以使用ManualClock
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		if c != nil {
+			o.clock = c
+		}
+	}
+}
+
+// 设置分片数
+func WithShards(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.shards = n
+		}
+	}
+}
+
+// 设置空闲多久之后清理桶
+func WithIdleTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		if ttl > 0 {
+			o.idleTTL = ttl
+		}
+	}
+}
+
+// 设置最多保存多少个key的桶，所有分片的key总数不超过n
+func WithMaxKeys(n int) Option {
+	return func(o *options) {
+		if n >= 0 {
+			o.maxKeys = n
+		}
+	}
+}
+
+// 设置优先级限流器最多排队的调用方数，超过时先丢弃优先级最低的调用方
+func WithMaxQueue(n int) Option {
+	return func(o *options) {
+		if n >= 0 {
+			o.maxQueue = n
+		}
+	}
+}
+
+// 设置自适应并发限流器的初始并发数
+func WithInitialLimit(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.initialLimit = n
+		}
+	}
+}
+
+// 设置自适应并发限流器的并发数范围
+func WithLimitRange(min, max int) Option {
+	return func(o *options) {
+		if min > 0 && max >= min {
+			o.minLimit = min
+			o.maxLimit = max
+		}
+	}
+}
+
+// 设置自适应并发限流器乘法减小的系数，取值范围(0, 1)
+func WithBackoffRatio(ratio float64) Option {
+	return func(o *options) {
+		if ratio > 0 && ratio < 1 {
+			o.backoffRatio = ratio
+		}
+	}
+}
+
+// 设置延迟超过基线的多少倍时视为延迟上升，需要大于1
+func WithLatencyTolerance(tolerance float64) Option {
+	return func(o *options) {
+		if tolerance > 1 {
+			o.latencyTolerance = tolerance
+		}
+	}
+}
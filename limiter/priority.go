@@ -0,0 +1,285 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 请求的优先级，值越小优先级越高
+type Priority int
+
+const (
+	PriorityCritical  Priority = iota // 关键请求，如支付、登录
+	PriorityDefault                   // 普通请求
+	PrioritySheddable                 // 可以丢弃的请求，如预取、离线任务
+
+	numPriorities = int(PrioritySheddable) + 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityCritical:
+		return "critical"
+	case PriorityDefault:
+		return "default"
+	case PrioritySheddable:
+		return "sheddable"
+	default:
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+}
+
+// 排队的调用方过多，被丢弃
+var ErrShed = errors.New("limiter: 排队的调用方过多，请求被丢弃")
+
+// ctx中保存优先级的key
+type priorityKey struct{}
+
+// 返回携带优先级的ctx，PriorityLimiter.Wait按ctx中的优先级排队
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// ctx中的优先级，没有设置时为PriorityDefault
+func PriorityFromContext(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return clampPriority(p)
+	}
+	return PriorityDefault
+}
+
+// 超出范围的优先级按最近的优先级处理
+func clampPriority(p Priority) Priority {
+	return min(max(p, PriorityCritical), PrioritySheddable)
+}
+
+// 优先级限流器
+// 令牌桶的Wait中多个调用方同时等待时，谁先拿到令牌没有保证，大量低优先级的请求会让高优先级的请求一直等待。
+// 优先级限流器让等待的调用方排队：令牌先给优先级最高的调用方，同一优先级按到达顺序；
+// 排队的调用方超过上限时，先丢弃优先级最低、最后到达的调用方
+//
+// 只有队首的调用方等待令牌生成，拿到令牌后唤醒下一个队首，其他调用方等待被唤醒
+type PriorityLimiter struct {
+	bucket   *TokenBucket
+	clock    Clock
+	maxQueue int      // 最多排队的调用方数，0表示不限制
+	stats    counters // 统计信息
+
+	mu     sync.Mutex               // 所有修改 queues、size的操作均在 mu锁保护下进行
+	queues [numPriorities][]*waiter // 每个优先级一个队列，按到达顺序
+	size   int                      // 排队的调用方数
+}
+
+// 排队的调用方
+type waiter struct {
+	priority Priority
+	wake     chan struct{} // 成为队首或者被丢弃时唤醒，缓冲为1
+	shed     bool          // 被丢弃
+}
+
+// 生成优先级限流器
+// 可选配置：WithMaxQueue、WithClock
+func NewPriority(limit Limit, burst int, opts ...Option) *PriorityLimiter {
+	o := newOptions(opts...)
+	return &PriorityLimiter{
+		bucket:   New(limit, burst, WithClock(o.clock)),
+		clock:    o.clock,
+		maxQueue: o.maxQueue,
+	}
+}
+
+// 是否允许执行事件
+func (lim *PriorityLimiter) Allow() bool {
+	return lim.AllowN(lim.clock.Now(), 1)
+}
+
+// 是否允许在时间t执行n个事件
+// 有调用方在排队时不允许，不能插队
+func (lim *PriorityLimiter) AllowN(t time.Time, n int) bool {
+	lim.mu.Lock()
+	ok := lim.size == 0
+	if ok {
+		_, ok = lim.bucket.tryN(t, n)
+	}
+	lim.mu.Unlock()
+
+	lim.stats.record(ok)
+	return ok
+}
+
+// 阻塞等待，直到获取到1个令牌，优先级从ctx中获取（WithPriority）
+func (lim *PriorityLimiter) Wait(ctx context.Context) error {
+	return lim.WaitN(ctx, 1)
+}
+
+// 阻塞等待，直到获取到n个令牌，优先级从ctx中获取（WithPriority）
+// 被丢弃时返回ErrShed；ctx被取消或者在ctx的截止时间之前无法获取到足够的令牌时，返回错误
+func (lim *PriorityLimiter) WaitN(ctx context.Context, n int) error {
+	err := lim.waitN(ctx, PriorityFromContext(ctx), n)
+	lim.stats.record(err == nil)
+	return err
+}
+
+// 排队的调用方数
+func (lim *PriorityLimiter) QueueLen() int {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	return lim.size
+}
+
+// 统计信息，Waited为排队等待的次数
+func (lim *PriorityLimiter) Stats() Stats {
+	return lim.stats.snapshot()
+}
+
+// 阻塞等待，直到获取到n个令牌
+func (lim *PriorityLimiter) waitN(ctx context.Context, p Priority, n int) error {
+	if n > lim.bucket.Burst() && lim.bucket.Limit() != Inf {
+		return fmt.Errorf("limiter: WaitN(n=%d) 超过了桶的容量 %d", n, lim.bucket.Burst())
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	lim.mu.Lock()
+	start := lim.clock.Now()
+	// 没有人排队时直接获取
+	if lim.size == 0 {
+		if _, ok := lim.bucket.tryN(start, n); ok {
+			lim.mu.Unlock()
+			return nil
+		}
+	}
+
+	w := &waiter{priority: p, wake: make(chan struct{}, 1)}
+	if !lim.enqueueLocked(w) {
+		lim.mu.Unlock()
+		return ErrShed
+	}
+	defer func() {
+		lim.stats.recordWait(lim.clock.Now().Sub(start))
+	}()
+
+	for {
+		if w.shed {
+			lim.mu.Unlock()
+			return ErrShed
+		}
+
+		var timer Timer
+		if lim.headLocked() == w {
+			now := lim.clock.Now()
+			delay, ok := lim.bucket.tryN(now, n)
+			if ok {
+				lim.removeLocked(w)
+				lim.wakeHeadLocked()
+				lim.mu.Unlock()
+				return nil
+			}
+			// 截止时间之前无法获取，不再等待
+			if deadline, has := ctx.Deadline(); has && deadline.Sub(now) < delay {
+				lim.removeLocked(w)
+				lim.wakeHeadLocked()
+				lim.mu.Unlock()
+				return context.DeadlineExceeded
+			}
+			timer = lim.clock.NewTimer(delay)
+		}
+		lim.mu.Unlock()
+
+		var timerC <-chan time.Time
+		if timer != nil {
+			timerC = timer.C()
+		}
+		select {
+		case <-timerC:
+		case <-w.wake:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		lim.mu.Lock()
+		if err := ctx.Err(); err != nil && !w.shed {
+			lim.removeLocked(w)
+			lim.wakeHeadLocked()
+			lim.mu.Unlock()
+			return err
+		}
+	}
+}
+
+// 加入队列，队列已满时丢弃优先级最低、最后到达的调用方
+// 新的调用方就是应该丢弃的调用方时返回false
+func (lim *PriorityLimiter) enqueueLocked(w *waiter) bool {
+	if lim.maxQueue > 0 && lim.size >= lim.maxQueue {
+		victim := lim.lowestLocked()
+		if victim.priority <= w.priority {
+			// 同一优先级先到达的调用方优先
+			return false
+		}
+		lim.removeLocked(victim)
+		victim.shed = true
+		notify(victim.wake)
+	}
+
+	oldHead := lim.headLocked()
+	lim.queues[w.priority] = append(lim.queues[w.priority], w)
+	lim.size++
+	if oldHead != nil && lim.headLocked() != oldHead {
+		// 新的调用方优先级更高，成为队首；原来的队首醒来后发现自己不是队首，继续等待
+		notify(oldHead.wake)
+	}
+	return true
+}
+
+// 队首：优先级最高、最先到达的调用方
+func (lim *PriorityLimiter) headLocked() *waiter {
+	for _, q := range lim.queues {
+		if len(q) > 0 {
+			return q[0]
+		}
+	}
+	return nil
+}
+
+// 优先级最低、最后到达的调用方
+func (lim *PriorityLimiter) lowestLocked() *waiter {
+	for i := numPriorities - 1; i >= 0; i-- {
+		if q := lim.queues[i]; len(q) > 0 {
+			return q[len(q)-1]
+		}
+	}
+	return nil
+}
+
+// 从队列中移除
+func (lim *PriorityLimiter) removeLocked(w *waiter) {
+	q := lim.queues[w.priority]
+	for i, other := range q {
+		if other == w {
+			lim.queues[w.priority] = append(q[:i], q[i+1:]...)
+			lim.size--
+			return
+		}
+	}
+}
+
+// 唤醒队首，让它开始等待令牌
+func (lim *PriorityLimiter) wakeHeadLocked() {
+	if head := lim.headLocked(); head != nil {
+		notify(head.wake)
+	}
+}
+
+// 非阻塞地发送通知，已经有未处理的通知时忽略
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
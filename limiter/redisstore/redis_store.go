@@ -0,0 +1,100 @@
+package redisstore
+
+import (
+	"context"
+	"limiter"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 令牌桶脚本：生成令牌、获取令牌在Redis中原子执行，一次往返完成，不需要重试
+// 时间使用Redis的TIME，副本之间的时钟偏差不影响令牌的生成
+// KEYS[1]：key，hash中保存 tokens（令牌数）、last（上次生成令牌的时间，微秒）
+// ARGV[1]：令牌生成速率（每秒）
+// ARGV[2]：桶的容量
+// ARGV[3]：获取的令牌数
+// ARGV[4]：过期时间（毫秒），0表示不过期
+// 返回值：{1, 0} 获取成功；{0, 需要等待的时间（微秒）}，-1表示永远无法获取
+var takeScript = redis.NewScript(`
+-- TIME之后有写操作，Redis 5之前需要按命令复制脚本
+if redis.replicate_commands then
+	redis.replicate_commands()
+end
+
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+-- 第一次使用时，填满桶
+local tokens = capacity
+local last = now
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+if state[1] and state[2] then
+	tokens = tonumber(state[1])
+	last = tonumber(state[2])
+end
+
+-- 预生成令牌
+if now < last then
+	now = last
+end
+tokens = math.min(capacity, tokens + (now - last) * rate / 1000000)
+
+local ok = 0
+local wait = 0
+if tokens >= n then
+	tokens = tokens - n
+	ok = 1
+elseif n > capacity or rate <= 0 then
+	wait = -1
+else
+	wait = math.ceil((n - tokens) / rate * 1000000)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', tostring(now))
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return {ok, wait}
+`)
+
+// Redis存储
+// 兼容Redis协议的存储都可以使用，如Redis、Redis Cluster、miniredis
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string        // key的前缀
+	ttl    time.Duration // key的过期时间，桶长时间不使用时自动删除，0表示不过期
+}
+
+// 生成Redis存储
+// ttl建议不小于桶从空到满需要的时间，过期后桶恢复为满桶
+func New(client redis.UniversalClient, prefix string, ttl time.Duration) *RedisStore {
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+// 原子地从key对应的令牌桶中获取n个令牌
+// 令牌桶的计算在Lua脚本中完成，按Redis的时间生成令牌，忽略调用方传入的t
+func (s *RedisStore) TakeN(ctx context.Context, key string, limit limiter.Limit, capacity int, t time.Time, n int) (time.Duration, bool, error) {
+	rate := strconv.FormatFloat(float64(limit), 'g', -1, 64)
+	res, err := takeScript.Run(ctx, s.client, []string{s.prefix + key}, rate, capacity, n, s.ttl.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	if res[0] == 1 {
+		return 0, true, nil
+	}
+	if res[1] < 0 {
+		return limiter.InfDuration, false, nil
+	}
+	return time.Duration(res[1]) * time.Microsecond, false, nil
+}
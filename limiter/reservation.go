@@ -0,0 +1,91 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+// 无限长的等待时间，预定失败时Delay()返回该值
+const InfDuration = time.Duration(math.MaxInt64)
+
+// 令牌预定信息
+// 令牌桶允许欠账：令牌不足时先预定，调用方等待Delay()之后再执行事件；不再需要时可以Cancel()归还令牌
+type Reservation struct {
+	ok        bool
+	lim       *TokenBucket
+	tokens    int       // 预定的令牌数
+	timeToAct time.Time // 允许执行事件的时间
+	limit     Limit     // 预定时的令牌生成速率
+}
+
+// 是否预定成功
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// 当前时间距离允许执行事件还需要等待多久
+func (r *Reservation) Delay() time.Duration {
+	return r.DelayFrom(r.lim.clock.Now())
+}
+
+// 时间t距离允许执行事件还需要等待多久
+// 预定失败时返回InfDuration
+func (r *Reservation) DelayFrom(t time.Time) time.Duration {
+	if !r.ok {
+		return InfDuration
+	}
+	delay := r.timeToAct.Sub(t)
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// 取消预定，尽可能归还令牌
+func (r *Reservation) Cancel() {
+	r.CancelAt(r.lim.clock.Now())
+}
+
+// 在时间t取消预定
+// 已经到达执行时间的预定不再归还令牌；
+// 该预定之后还有其他预定时，只归还不影响后续预定的那部分令牌
+func (r *Reservation) CancelAt(t time.Time) {
+	if !r.ok {
+		return
+	}
+
+	r.lim.mu.Lock()
+	defer r.lim.mu.Unlock()
+
+	if r.lim.limit == Inf || r.tokens == 0 || r.timeToAct.Before(t) {
+		return
+	}
+
+	// 需要归还的令牌数 = 预定的令牌数 - 之后的预定已经占用的令牌数
+	restoreTokens := float64(r.tokens) - r.limit.tokensFromDuration(r.lim.lastEvent.Sub(r.timeToAct))
+	if restoreTokens <= 0 {
+		return
+	}
+
+	// 预生成令牌后归还
+	t, tokens := r.lim.advance(t)
+	tokens += restoreTokens
+	if burst := float64(r.lim.capacity); tokens > burst {
+		tokens = burst
+	}
+
+	// 更新令牌数
+	r.lim.last = t
+	r.lim.tokens = tokens
+
+	// 该预定是最后一个预定时，回退lastEvent
+	if r.timeToAct.Equal(r.lim.lastEvent) {
+		prevEvent := r.timeToAct.Add(r.limit.durationFromTokens(float64(-r.tokens)))
+		if !prevEvent.Before(t) {
+			r.lim.lastEvent = prevEvent
+		}
+	}
+
+	// 避免重复归还
+	r.tokens = 0
+}
@@ -0,0 +1,119 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 限流算法
+const (
+	TokenBucket          = "token_bucket"
+	GCRA                 = "gcra"
+	LeakyBucket          = "leaky_bucket"
+	SlidingWindowLog     = "sliding_window_log"
+	SlidingWindowCounter = "sliding_window_counter"
+)
+
+// 默认配置
+const (
+	defaultPeriod = time.Second
+)
+
+// 规则文件
+type Config struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// 限流规则
+type Rule struct {
+	Name      string        `yaml:"name"`       // 规则名称，不能重复，热加载时按名称判断规则是否变化
+	Match     Match         `yaml:"match"`      // 匹配条件
+	Algorithm string        `yaml:"algorithm"`  // 限流算法，默认为token_bucket
+	Limit     float64       `yaml:"limit"`      // 每个周期允许的事件数
+	Period    time.Duration `yaml:"period"`     // 周期，默认为1秒；滑动窗口算法中为窗口大小
+	Burst     int           `yaml:"burst"`      // 桶的容量，默认等于limit向上取整；漏桶中为排队的调用方数
+	PerClient bool          `yaml:"per_client"` // 每个客户端使用独立的限流器，只支持token_bucket
+}
+
+// 匹配条件，所有条件都满足时才匹配，空的条件匹配任意请求
+type Match struct {
+	Route    string            `yaml:"route"`     // 路由，支持path.Match的通配符，如 /api/*
+	Method   string            `yaml:"method"`    // 请求方法，不区分大小写
+	Headers  map[string]string `yaml:"headers"`   // 请求头，值必须相等
+	ClientID string            `yaml:"client_id"` // 客户端ID
+}
+
+// 读取规则文件
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// 解析规则，填充默认值并校验
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("rules: 解析规则失败: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// 填充默认值并校验
+func (cfg *Config) validate() error {
+	names := make(map[string]bool, len(cfg.Rules))
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("rules: 第%d条规则未设置名称", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rules: 规则名称 %s 重复", r.Name)
+		}
+		names[r.Name] = true
+
+		if r.Algorithm == "" {
+			r.Algorithm = TokenBucket
+		}
+		if r.Period <= 0 {
+			r.Period = defaultPeriod
+		}
+		if r.Burst <= 0 {
+			r.Burst = int(math.Max(1, math.Ceil(r.Limit)))
+		}
+
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("rules: 规则 %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// 校验规则
+func (r *Rule) validate() error {
+	switch r.Algorithm {
+	case TokenBucket, GCRA, LeakyBucket, SlidingWindowLog, SlidingWindowCounter:
+	default:
+		return fmt.Errorf("不支持的限流算法 %s", r.Algorithm)
+	}
+	if r.Limit <= 0 {
+		return errors.New("limit必须大于0")
+	}
+	if (r.Algorithm == SlidingWindowLog || r.Algorithm == SlidingWindowCounter) && r.Limit != math.Trunc(r.Limit) {
+		return errors.New("滑动窗口算法的limit必须是整数")
+	}
+	if r.PerClient && r.Algorithm != TokenBucket {
+		return errors.New("per_client只支持token_bucket")
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package rules
+
+import (
+	"limiter"
+	"maps"
+	"net/textproto"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 待限流的请求
+type Request struct {
+	Route    string
+	Method   string
+	Header   map[string][]string // http.Header、gRPC的metadata.MD都可以直接使用
+	ClientID string
+}
+
+// 限流结果
+type Decision struct {
+	Allowed bool
+	Rule    string // 匹配的规则名称，没有匹配的规则时为空
+}
+
+// 规则引擎
+// 按顺序匹配规则，使用第一个匹配的规则限流；没有匹配的规则时允许执行
+// 规则更新时整体替换，正在执行的Allow使用旧的规则或新的规则，不会看到更新了一半的规则
+type Engine struct {
+	mu      sync.Mutex // 串行执行Update
+	current atomic.Pointer[ruleSet]
+	opts    []limiter.Option // 创建限流器时使用的配置
+}
+
+// 一组规则
+type ruleSet struct {
+	cfg   Config
+	rules []*compiledRule
+}
+
+// 规则和对应的限流器
+type compiledRule struct {
+	Rule
+	lim   limiter.Limiter       // 所有请求共用的限流器
+	keyed *limiter.KeyedLimiter // 每个客户端使用独立的令牌桶
+}
+
+// 生成规则引擎
+// opts：创建限流器时使用的配置，如WithClock
+func New(cfg *Config, opts ...limiter.Option) (*Engine, error) {
+	e := &Engine{opts: opts}
+	if err := e.Update(cfg); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// 读取规则文件，生成规则引擎
+func NewFromFile(path string, opts ...limiter.Option) (*Engine, error) {
+	cfg, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg, opts...)
+}
+
+// 替换为新的规则
+// 名称和限流参数都没有变化的规则继续使用原来的限流器，桶中的令牌、窗口中的计数不会丢失；
+// 新的规则校验失败时返回错误，继续使用原来的规则
+func (e *Engine) Update(cfg *Config) error {
+	next := Config{Rules: make([]Rule, len(cfg.Rules))}
+	for i, r := range cfg.Rules {
+		r.Match.Headers = maps.Clone(r.Match.Headers)
+		next.Rules[i] = r
+	}
+	if err := next.validate(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	old := make(map[string]*compiledRule)
+	if cur := e.current.Load(); cur != nil {
+		for _, r := range cur.rules {
+			old[r.Name] = r
+		}
+	}
+
+	rs := &ruleSet{cfg: next}
+	for _, r := range next.Rules {
+		cr := &compiledRule{Rule: r}
+		if prev, ok := old[r.Name]; ok && prev.sameLimiter(r) {
+			cr.lim, cr.keyed = prev.lim, prev.keyed
+		} else {
+			cr.lim, cr.keyed = newLimiter(r, e.opts)
+		}
+		rs.rules = append(rs.rules, cr)
+	}
+	e.current.Store(rs)
+	return nil
+}
+
+// 当前使用的规则
+func (e *Engine) Config() Config {
+	cfg := e.current.Load().cfg
+	rules := make([]Rule, len(cfg.Rules))
+	for i, r := range cfg.Rules {
+		r.Match.Headers = maps.Clone(r.Match.Headers)
+		rules[i] = r
+	}
+	return Config{Rules: rules}
+}
+
+// 请求是否允许执行
+func (e *Engine) Allow(req Request) Decision {
+	for _, r := range e.current.Load().rules {
+		if !r.Match.matches(req) {
+			continue
+		}
+		if r.keyed != nil {
+			return Decision{Allowed: r.keyed.Allow(req.ClientID), Rule: r.Name}
+		}
+		return Decision{Allowed: r.lim.Allow(), Rule: r.Name}
+	}
+	return Decision{Allowed: true}
+}
+
+// 限流参数是否相同，相同时可以继续使用原来的限流器
+func (r *compiledRule) sameLimiter(other Rule) bool {
+	return r.Algorithm == other.Algorithm &&
+		r.Limit == other.Limit &&
+		r.Period == other.Period &&
+		r.Burst == other.Burst &&
+		r.PerClient == other.PerClient
+}
+
+// 按规则创建限流器
+func newLimiter(r Rule, opts []limiter.Option) (limiter.Limiter, *limiter.KeyedLimiter) {
+	rate := limiter.Limit(r.Limit / r.Period.Seconds())
+	if r.PerClient {
+		return nil, limiter.NewKeyed(rate, r.Burst, opts...)
+	}
+
+	switch r.Algorithm {
+	case GCRA:
+		return limiter.NewGCRA(rate, r.Burst, opts...), nil
+	case LeakyBucket:
+		return limiter.NewLeakyBucket(rate, r.Burst, opts...), nil
+	case SlidingWindowLog:
+		return limiter.NewSlidingWindowLog(int(r.Limit), r.Period, opts...), nil
+	case SlidingWindowCounter:
+		return limiter.NewSlidingWindowCounter(int(r.Limit), r.Period, opts...), nil
+	default:
+		return limiter.New(rate, r.Burst, opts...), nil
+	}
+}
+
+// 请求是否满足匹配条件
+func (m Match) matches(req Request) bool {
+	if m.Route != "" {
+		if ok, _ := path.Match(m.Route, req.Route); !ok {
+			return false
+		}
+	}
+	if m.Method != "" && !strings.EqualFold(m.Method, req.Method) {
+		return false
+	}
+	if m.ClientID != "" && m.ClientID != req.ClientID {
+		return false
+	}
+	for name, value := range m.Headers {
+		if headerValue(req.Header, name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// 获取请求头的值
+// http.Header的key是规范格式（如 X-Tenant），gRPC metadata的key是小写，两种格式都尝试
+func headerValue(header map[string][]string, name string) string {
+	for _, key := range []string{name, textproto.CanonicalMIMEHeaderKey(name), strings.ToLower(name)} {
+		if values := header[key]; len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
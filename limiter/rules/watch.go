@@ -0,0 +1,69 @@
+package rules
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 监听规则文件，文件变化时重新加载规则，ctx被取消时停止监听
+// onReload：每次重新加载后调用，err为nil表示加载成功；加载失败时继续使用原来的规则
+//
+// 监听的是文件所在的目录而不是文件本身：编辑器通常先写临时文件再重命名，直接监听文件在文件被替换后就收不到事件了。
+// k8s ConfigMap挂载的文件是指向 ..data/<文件名> 的符号链接，更新时把 ..data 重命名为指向新目录的符号链接，
+// 事件的文件名是 ..data 而不是规则文件；因此目录中有任何事件时都重新解析符号链接，指向的文件变化时也重新加载
+func (e *Engine) WatchFile(ctx context.Context, path string, onReload func(err error)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+	// 符号链接指向的文件，不是符号链接时为文件本身
+	realPath, _ := filepath.EvalSymlinks(path)
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				current, _ := filepath.EvalSymlinks(path)
+				changed := filepath.Clean(event.Name) == path && event.Has(fsnotify.Write|fsnotify.Create)
+				if !changed && (current == "" || current == realPath) {
+					continue
+				}
+				realPath = current
+				err := e.reload(path)
+				if onReload != nil {
+					onReload(err)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				if onReload != nil {
+					onReload(err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// 重新读取规则文件
+func (e *Engine) reload(path string) error {
+	cfg, err := LoadFile(path)
+	if err != nil {
+		return err
+	}
+	return e.Update(cfg)
+}
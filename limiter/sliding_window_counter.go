@@ -0,0 +1,145 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// 滑动窗口计数器
+// 只记录当前窗口和上一个窗口的事件数，按上一个窗口和滑动窗口重叠的比例估算滑动窗口内的事件数：
+// 估算值 = 上一个窗口的事件数 * (1 - 当前窗口已经过的时间/窗口大小) + 当前窗口的事件数
+// 内存占用固定，但结果是近似值
+type SlidingWindowCounter struct {
+	mu        sync.Mutex    // 所有修改 start、curCount、prevCount的操作均在 mu锁保护下进行
+	limit     int           // 窗口内允许的最大事件数
+	window    time.Duration // 窗口大小
+	start     time.Time     // 当前窗口的开始时间
+	curCount  int           // 当前窗口的事件数
+	prevCount int           // 上一个窗口的事件数
+	clock     Clock         // 时钟
+	stats     counters      // 统计信息
+}
+
+// 生成滑动窗口计数器限流器
+// limit：窗口内允许的最大事件数；window：窗口大小，如1分钟，必须大于0
+// 可选配置：WithClock
+func NewSlidingWindowCounter(limit int, window time.Duration, opts ...Option) *SlidingWindowCounter {
+	if window <= 0 {
+		panic(fmt.Sprintf("limiter: 窗口大小必须大于0，实际: %v", window))
+	}
+	o := newOptions(opts...)
+	return &SlidingWindowCounter{
+		limit:  limit,
+		window: window,
+		clock:  o.clock,
+	}
+}
+
+// 是否允许执行事件
+func (lim *SlidingWindowCounter) Allow() bool {
+	return lim.AllowN(lim.clock.Now(), 1)
+}
+
+// 是否允许在时间t执行n个事件
+func (lim *SlidingWindowCounter) AllowN(t time.Time, n int) bool {
+	_, ok := lim.reserveN(t, n)
+	lim.stats.record(ok)
+	return ok
+}
+
+// 阻塞等待，直到允许执行事件
+func (lim *SlidingWindowCounter) Wait(ctx context.Context) error {
+	return lim.WaitN(ctx, 1)
+}
+
+// 阻塞等待，直到允许执行n个事件
+func (lim *SlidingWindowCounter) WaitN(ctx context.Context, n int) error {
+	if n > lim.limit {
+		lim.stats.record(false)
+		return fmt.Errorf("limiter: WaitN(n=%d) 超过了窗口的限制 %d", n, lim.limit)
+	}
+	err := waitLoop(ctx, lim.clock, &lim.stats, func(t time.Time) (time.Duration, bool) {
+		return lim.reserveN(t, n)
+	})
+	lim.stats.record(err == nil)
+	return err
+}
+
+// 统计信息
+func (lim *SlidingWindowCounter) Stats() Stats {
+	return lim.stats.snapshot()
+}
+
+// 核心代码
+// 尝试在时间t记录n个事件，超过限制时返回还需要等待的时间
+func (lim *SlidingWindowCounter) reserveN(t time.Time, n int) (time.Duration, bool) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	t = lim.advance(t)
+
+	elapsed := t.Sub(lim.start)
+	if lim.estimate(elapsed)+float64(n) <= float64(lim.limit) {
+		lim.curCount += n
+		return 0, true
+	}
+
+	if n > lim.limit {
+		return InfDuration, false
+	}
+
+	// 当前窗口内还有空间，等待上一个窗口的权重下降
+	free := float64(lim.limit - lim.curCount - n)
+	if free >= 0 && lim.prevCount > 0 {
+		return lim.weightedDelay(lim.prevCount, free) - elapsed, false
+	}
+
+	// 当前窗口已满，等到下一个窗口，当前窗口的事件数成为上一个窗口的事件数
+	return lim.window - elapsed + lim.weightedDelay(lim.curCount, float64(lim.limit-n)), false
+}
+
+// 切换到时间t所在的窗口
+// 返回值：t早于当前窗口的开始时间时，按当前窗口的开始时间计算
+func (lim *SlidingWindowCounter) advance(t time.Time) time.Time {
+	// 第一次启动时，以t作为窗口的开始时间
+	if lim.start.IsZero() {
+		lim.start = t
+		return t
+	}
+	if t.Before(lim.start) {
+		return lim.start
+	}
+
+	windows := t.Sub(lim.start) / lim.window
+	switch {
+	case windows == 0:
+		// 仍在当前窗口
+	case windows == 1:
+		lim.prevCount = lim.curCount
+		lim.curCount = 0
+	default:
+		// 已经跨过了多个窗口，之前的事件全部滑出
+		lim.prevCount = 0
+		lim.curCount = 0
+	}
+	lim.start = lim.start.Add(windows * lim.window)
+	return t
+}
+
+// 估算当前窗口已经过elapsed时，滑动窗口内的事件数
+func (lim *SlidingWindowCounter) estimate(elapsed time.Duration) float64 {
+	weight := 1 - float64(elapsed)/float64(lim.window)
+	return float64(lim.prevCount)*weight + float64(lim.curCount)
+}
+
+// 上一个窗口有prev个事件时，当前窗口需要经过多久，上一个窗口的权重才能降到free以内
+func (lim *SlidingWindowCounter) weightedDelay(prev int, free float64) time.Duration {
+	if prev == 0 || free >= float64(prev) {
+		return 0
+	}
+	ratio := 1 - free/float64(prev)
+	return time.Duration(math.Ceil(ratio * float64(lim.window)))
+}
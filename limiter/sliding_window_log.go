@@ -0,0 +1,125 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 滑动窗口日志
+// 记录窗口内每一次事件的时间，任意一个长度为window的时间段内，最多允许limit个事件
+// 精确，但内存占用和窗口内的事件数成正比
+type SlidingWindowLog struct {
+	mu     sync.Mutex    // 所有修改 events、count的操作均在 mu锁保护下进行
+	limit  int           // 窗口内允许的最大事件数
+	window time.Duration // 窗口大小
+	events []windowEvent // 窗口内的事件，按时间升序
+	count  int           // 窗口内的事件总数
+	clock  Clock         // 时钟
+	stats  counters      // 统计信息
+}
+
+// 窗口内的一次事件
+type windowEvent struct {
+	t time.Time // 事件发生的时间
+	n int       // 事件数
+}
+
+// 生成滑动窗口日志限流器
+// limit：窗口内允许的最大事件数；window：窗口大小，如1分钟，必须大于0
+// 可选配置：WithClock
+func NewSlidingWindowLog(limit int, window time.Duration, opts ...Option) *SlidingWindowLog {
+	if window <= 0 {
+		panic(fmt.Sprintf("limiter: 窗口大小必须大于0，实际: %v", window))
+	}
+	o := newOptions(opts...)
+	return &SlidingWindowLog{
+		limit:  limit,
+		window: window,
+		clock:  o.clock,
+	}
+}
+
+// 是否允许执行事件
+func (lim *SlidingWindowLog) Allow() bool {
+	return lim.AllowN(lim.clock.Now(), 1)
+}
+
+// 是否允许在时间t执行n个事件
+func (lim *SlidingWindowLog) AllowN(t time.Time, n int) bool {
+	_, ok := lim.reserveN(t, n)
+	lim.stats.record(ok)
+	return ok
+}
+
+// 阻塞等待，直到允许执行事件
+func (lim *SlidingWindowLog) Wait(ctx context.Context) error {
+	return lim.WaitN(ctx, 1)
+}
+
+// 阻塞等待，直到允许执行n个事件
+func (lim *SlidingWindowLog) WaitN(ctx context.Context, n int) error {
+	if n > lim.limit {
+		lim.stats.record(false)
+		return fmt.Errorf("limiter: WaitN(n=%d) 超过了窗口的限制 %d", n, lim.limit)
+	}
+	err := waitLoop(ctx, lim.clock, &lim.stats, func(t time.Time) (time.Duration, bool) {
+		return lim.reserveN(t, n)
+	})
+	lim.stats.record(err == nil)
+	return err
+}
+
+// 统计信息
+func (lim *SlidingWindowLog) Stats() Stats {
+	return lim.stats.snapshot()
+}
+
+// 核心代码
+// 尝试在时间t记录n个事件，超过限制时返回还需要等待的时间
+func (lim *SlidingWindowLog) reserveN(t time.Time, n int) (time.Duration, bool) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	// 保证事件按时间升序，t早于最后一个事件时，按最后一个事件的时间计算
+	if last := len(lim.events) - 1; last >= 0 && t.Before(lim.events[last].t) {
+		t = lim.events[last].t
+	}
+
+	// 移除已经滑出窗口的事件
+	lim.evict(t)
+
+	if lim.count+n <= lim.limit {
+		lim.events = append(lim.events, windowEvent{t: t, n: n})
+		lim.count += n
+		return 0, true
+	}
+
+	if n > lim.limit {
+		return InfDuration, false
+	}
+
+	// 等到最早的若干个事件滑出窗口，腾出足够的空间
+	need := lim.count + n - lim.limit
+	for _, e := range lim.events {
+		need -= e.n
+		if need <= 0 {
+			return e.t.Add(lim.window).Sub(t), false
+		}
+	}
+	return InfDuration, false
+}
+
+// 移除时间t时已经滑出窗口的事件
+func (lim *SlidingWindowLog) evict(t time.Time) {
+	boundary := t.Add(-lim.window)
+	i := 0
+	for ; i < len(lim.events); i++ {
+		if lim.events[i].t.After(boundary) {
+			break
+		}
+		lim.count -= lim.events[i].n
+	}
+	lim.events = lim.events[i:]
+}
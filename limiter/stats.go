@@ -0,0 +1,73 @@
+package limiter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 限流器的统计信息
+type Stats struct {
+	Allowed  uint64        // 允许执行的次数
+	Denied   uint64        // 拒绝执行的次数，包括Wait超时、被取消
+	Waited   uint64        // Wait中需要阻塞等待的次数
+	WaitTime time.Duration // Wait中阻塞等待的总时间
+}
+
+// 提供统计信息的限流器
+type StatsProvider interface {
+	Stats() Stats
+}
+
+var (
+	_ StatsProvider = (*TokenBucket)(nil)
+	_ StatsProvider = (*SlidingWindowLog)(nil)
+	_ StatsProvider = (*SlidingWindowCounter)(nil)
+	_ StatsProvider = (*LeakyBucket)(nil)
+	_ StatsProvider = (*GCRA)(nil)
+	_ StatsProvider = (*DistributedBucket)(nil)
+	_ StatsProvider = (*KeyedLimiter)(nil)
+	_ StatsProvider = (*HierarchicalLimiter)(nil)
+	_ StatsProvider = (*AdaptiveLimiter)(nil)
+	_ StatsProvider = (*PriorityLimiter)(nil)
+)
+
+// 统计计数器，使用原子操作，不需要加锁
+type counters struct {
+	allowed  atomic.Uint64
+	denied   atomic.Uint64
+	waited   atomic.Uint64
+	waitTime atomic.Int64
+	parent   *counters // 同时累加到上一级，如按key限流中每个桶的计数累加到限流器
+}
+
+// 记录一次是否允许执行
+func (c *counters) record(ok bool) {
+	for ; c != nil; c = c.parent {
+		if ok {
+			c.allowed.Add(1)
+		} else {
+			c.denied.Add(1)
+		}
+	}
+}
+
+// 记录一次阻塞等待
+func (c *counters) recordWait(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	for ; c != nil; c = c.parent {
+		c.waited.Add(1)
+		c.waitTime.Add(int64(d))
+	}
+}
+
+// 当前的统计信息
+func (c *counters) snapshot() Stats {
+	return Stats{
+		Allowed:  c.allowed.Load(),
+		Denied:   c.denied.Load(),
+		Waited:   c.waited.Load(),
+		WaitTime: time.Duration(c.waitTime.Load()),
+	}
+}
@@ -0,0 +1,83 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 令牌桶的状态
+type BucketState struct {
+	Tokens float64   // 桶中令牌的数量
+	Last   time.Time // 上次生成令牌的时间，零值表示桶还未使用过
+}
+
+// 令牌桶状态的存储
+// 多个服务副本使用同一个存储（如Redis）时，共享同一份配额
+type Store interface {
+	// 原子地从key对应的令牌桶中获取n个令牌，令牌不足时返回还需要等待的时间
+	// 令牌生成速率为limit，桶的容量为capacity，key不存在时为满桶
+	// t为调用方的时间；使用自己时钟的存储（如Redis）忽略t，避免副本之间的时钟偏差影响令牌的生成
+	TakeN(ctx context.Context, key string, limit Limit, capacity int, t time.Time, n int) (time.Duration, bool, error)
+}
+
+// 内存存储
+// 只能在单个进程内共享，主要用于单机部署和测试
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]BucketState
+}
+
+// 生成内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]BucketState),
+	}
+}
+
+// 原子地获取n个令牌，令牌按t生成
+func (s *MemoryStore) TakeN(ctx context.Context, key string, limit Limit, capacity int, t time.Time, n int) (time.Duration, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, wait, ok := takeN(s.buckets[key], limit, capacity, t, n)
+	s.buckets[key] = state
+	return wait, ok, nil
+}
+
+// 根据令牌桶的状态计算获取n个令牌后的状态
+func takeN(state BucketState, limit Limit, capacity int, t time.Time, n int) (BucketState, time.Duration, bool) {
+	// 不限速直接返回
+	if limit == Inf {
+		return state, 0, true
+	}
+
+	// 第一次使用时，填满桶
+	if state.Last.IsZero() {
+		state = BucketState{Tokens: float64(capacity), Last: t}
+	}
+
+	// 预生成令牌
+	if t.Before(state.Last) {
+		t = state.Last
+	}
+	tokens := state.Tokens + limit.tokensFromDuration(t.Sub(state.Last))
+	if tokens > float64(capacity) {
+		tokens = float64(capacity)
+	}
+
+	if remain := tokens - float64(n); remain >= 0 {
+		return BucketState{Tokens: remain, Last: t}, 0, true
+	}
+
+	// 令牌不足，只更新生成的令牌数
+	wait := limit.durationFromTokens(float64(n) - tokens)
+	if n > capacity {
+		wait = InfDuration
+	}
+	return BucketState{Tokens: tokens, Last: t}, wait, false
+}
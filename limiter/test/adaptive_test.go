@@ -0,0 +1,139 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"limiter"
+	"testing"
+	"time"
+)
+
+// 测试自适应并发限流器 - 延迟正常时加法增大
+func TestAdaptiveLimiterIncrease(t *testing.T) {
+	lim := limiter.NewAdaptive(limiter.WithInitialLimit(2), limiter.WithLimitRange(1, 10))
+
+	for i := 0; i < 5; i++ {
+		// 并发数达到上限，然后全部成功
+		limit := lim.Limit()
+		releases := make([]func(bool), 0, limit)
+		for j := 0; j < limit; j++ {
+			release, err := lim.Acquire(context.Background())
+			if err != nil {
+				t.Fatalf("Acquire失败，err:%v", err)
+			}
+			releases = append(releases, release)
+		}
+		for _, release := range releases {
+			release(true)
+		}
+	}
+
+	if limit := lim.Limit(); limit != 10 {
+		t.Errorf("预期并发数增大到上限10，实际: %d", limit)
+	}
+}
+
+// 测试自适应并发限流器 - 请求失败时乘法减小
+func TestAdaptiveLimiterDecreaseOnFailure(t *testing.T) {
+	lim := limiter.NewAdaptive(limiter.WithInitialLimit(10), limiter.WithBackoffRatio(0.5))
+
+	release, err := lim.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire失败，err:%v", err)
+	}
+	release(false)
+	if limit := lim.Limit(); limit != 5 {
+		t.Errorf("预期并发数减小到5，实际: %d", limit)
+	}
+
+	// 重复调用release不会重复调整
+	release(false)
+	if limit := lim.Limit(); limit != 5 {
+		t.Errorf("重复release后预期并发数仍为5，实际: %d", limit)
+	}
+}
+
+// 测试自适应并发限流器 - 延迟上升时乘法减小
+func TestAdaptiveLimiterDecreaseOnLatency(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewAdaptive(limiter.WithInitialLimit(10), limiter.WithLimitRange(1, 10), limiter.WithClock(clock))
+
+	// 建立延迟基线
+	release, _ := lim.Acquire(context.Background())
+	clock.Advance(2 * time.Millisecond)
+	release(true)
+
+	// 延迟上升
+	release, _ = lim.Acquire(context.Background())
+	clock.Advance(20 * time.Millisecond)
+	release(true)
+
+	if limit := lim.Limit(); limit >= 10 {
+		t.Errorf("延迟上升后预期并发数减小，实际: %d", limit)
+	}
+}
+
+// 测试自适应并发限流器 - 并发数已满时排队等待
+func TestAdaptiveLimiterQueue(t *testing.T) {
+	lim := limiter.NewAdaptive(limiter.WithInitialLimit(1), limiter.WithLimitRange(1, 1))
+
+	release, err := lim.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire失败，err:%v", err)
+	}
+
+	// 排队等待，超时返回
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := lim.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("预期错误 %v，实际得到 %v", context.DeadlineExceeded, err)
+	}
+
+	// 排队等待，名额释放后获取到名额
+	acquired := make(chan error, 1)
+	go func() {
+		r, err := lim.Acquire(context.Background())
+		if err == nil {
+			r(true)
+		}
+		acquired <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// 排队的调用方超过上限
+	if _, err := lim.Acquire(context.Background()); !errors.Is(err, limiter.ErrLimitExceeded) {
+		t.Errorf("预期错误 %v，实际得到 %v", limiter.ErrLimitExceeded, err)
+	}
+
+	release(true)
+	if err := <-acquired; err != nil {
+		t.Errorf("预期名额释放后获取成功，err:%v", err)
+	}
+	if inflight := lim.Inflight(); inflight != 0 {
+		t.Errorf("预期正在执行的请求数为0，实际: %d", inflight)
+	}
+}
+
+// 测试自适应并发限流器 - Check只判断并发数是否已满，不占用名额，不影响并发数
+func TestAdaptiveLimiterCheck(t *testing.T) {
+	lim := limiter.NewAdaptive(limiter.WithInitialLimit(1), limiter.WithLimitRange(1, 1))
+
+	if err := lim.Check(); err != nil {
+		t.Fatalf("并发数未满时 Check err = %v", err)
+	}
+	if n := lim.Inflight(); n != 0 {
+		t.Errorf("Check不应该占用名额，正在执行的请求数: %d", n)
+	}
+
+	release, err := lim.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire失败，err:%v", err)
+	}
+	if err := lim.Check(); !errors.Is(err, limiter.ErrLimitExceeded) {
+		t.Errorf("并发数已满时预期返回ErrLimitExceeded，实际: %v", err)
+	}
+	release(true)
+	if err := lim.Check(); err != nil {
+		t.Errorf("名额释放后 Check err = %v", err)
+	}
+}
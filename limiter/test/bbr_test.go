@@ -0,0 +1,173 @@
+package test
+
+import (
+	"errors"
+	"limiter"
+	"limiter/bbr"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 生成窗口为1秒、每个桶100ms的过载保护限流器，CPU使用率由cpu控制
+func newBBR(clock *limiter.ManualClock, cpu *atomic.Int64) *bbr.Limiter {
+	return bbr.New(
+		bbr.WithWindow(time.Second, 10),
+		bbr.WithCPUThreshold(800),
+		bbr.WithCPU(cpu.Load),
+		bbr.WithClock(clock),
+	)
+}
+
+// 在一个桶中完成n个延迟为rt的请求，然后进入下一个桶
+func completeRequests(t *testing.T, lim *bbr.Limiter, clock *limiter.ManualClock, n int, rt time.Duration) {
+	t.Helper()
+	dones := make([]func(), 0, n)
+	for i := 0; i < n; i++ {
+		done, err := lim.Allow()
+		if err != nil {
+			t.Fatalf("第%d个请求被丢弃，err:%v", i, err)
+		}
+		dones = append(dones, done)
+	}
+	clock.Advance(rt)
+	for _, done := range dones {
+		done()
+	}
+	clock.Advance(100 * time.Millisecond)
+}
+
+// 测试过载保护 - CPU使用率低于阈值时不丢弃请求
+func TestBBRBelowThreshold(t *testing.T) {
+	clock := limiter.NewManualClock(time.Unix(1700000000, 0))
+	var cpu atomic.Int64
+	cpu.Store(500)
+	lim := newBBR(clock, &cpu)
+
+	for i := 0; i < 1000; i++ {
+		if _, err := lim.Allow(); err != nil {
+			t.Fatalf("CPU使用率低于阈值时不应该丢弃请求，第%d个请求 err:%v", i, err)
+		}
+	}
+}
+
+// 测试过载保护 - CPU使用率超过阈值时，按 最大吞吐量*最小延迟 限制并发数
+func TestBBRShedding(t *testing.T) {
+	clock := limiter.NewManualClock(time.Unix(1700000000, 0))
+	var cpu atomic.Int64
+	cpu.Store(500)
+	lim := newBBR(clock, &cpu)
+
+	// 每个桶（100ms）最多完成10个请求，最小延迟100ms：每秒100个请求 * 0.1秒 = 10个并发
+	completeRequests(t, lim, clock, 10, 100*time.Millisecond)
+	completeRequests(t, lim, clock, 5, 200*time.Millisecond)
+
+	stat := lim.Stat()
+	if stat.MaxPass != 10 || stat.MinRT != 100*time.Millisecond || stat.MaxInFlight != 10 {
+		t.Fatalf("预期 MaxPass=10 MinRT=100ms MaxInFlight=10，实际: %+v", stat)
+	}
+
+	cpu.Store(900)
+	for i := 0; i < 10; i++ {
+		if _, err := lim.Allow(); err != nil {
+			t.Fatalf("第%d个请求不应该被丢弃，err:%v", i, err)
+		}
+	}
+	if _, err := lim.Allow(); !errors.Is(err, bbr.ErrLimitExceeded) {
+		t.Fatalf("超过允许的并发数时预期返回ErrLimitExceeded，实际: %v", err)
+	}
+	if got := lim.Stat().InFlight; got != 10 {
+		t.Errorf("预期正在处理的请求数为10，实际: %d", got)
+	}
+}
+
+// 测试过载保护 - 丢弃请求之后的冷却时间内，即使CPU已经降下来也继续按并发数限流
+func TestBBRCoolOff(t *testing.T) {
+	clock := limiter.NewManualClock(time.Unix(1700000000, 0))
+	var cpu atomic.Int64
+	cpu.Store(500)
+	lim := newBBR(clock, &cpu)
+	completeRequests(t, lim, clock, 2, 100*time.Millisecond)
+
+	// 允许2个并发
+	cpu.Store(900)
+	done1, _ := lim.Allow()
+	done2, _ := lim.Allow()
+	if _, err := lim.Allow(); !errors.Is(err, bbr.ErrLimitExceeded) {
+		t.Fatalf("预期返回ErrLimitExceeded，实际: %v", err)
+	}
+
+	cpu.Store(500)
+	clock.Advance(500 * time.Millisecond)
+	if _, err := lim.Allow(); !errors.Is(err, bbr.ErrLimitExceeded) {
+		t.Fatalf("冷却时间内预期返回ErrLimitExceeded，实际: %v", err)
+	}
+
+	// 冷却时间内丢弃请求，冷却时间重新计算
+	clock.Advance(time.Second + time.Millisecond)
+	if _, err := lim.Allow(); err != nil {
+		t.Fatalf("冷却时间过后不应该丢弃请求，err:%v", err)
+	}
+	done1()
+	done2()
+}
+
+// 测试过载保护 - done多次调用只有第一次生效
+func TestBBRDoneOnce(t *testing.T) {
+	clock := limiter.NewManualClock(time.Unix(1700000000, 0))
+	var cpu atomic.Int64
+	lim := newBBR(clock, &cpu)
+
+	done, _ := lim.Allow()
+	lim.Allow()
+	done()
+	done()
+	if got := lim.Stat().InFlight; got != 1 {
+		t.Errorf("预期正在处理的请求数为1，实际: %d", got)
+	}
+}
+
+// 测试过载保护 - 滑出窗口的请求不再统计
+func TestBBRWindow(t *testing.T) {
+	clock := limiter.NewManualClock(time.Unix(1700000000, 0))
+	var cpu atomic.Int64
+	lim := newBBR(clock, &cpu)
+
+	completeRequests(t, lim, clock, 20, 50*time.Millisecond)
+	if got := lim.Stat().MaxPass; got != 20 {
+		t.Fatalf("预期MaxPass为20，实际: %d", got)
+	}
+
+	clock.Advance(time.Second)
+	completeRequests(t, lim, clock, 3, 50*time.Millisecond)
+	if got := lim.Stat().MaxPass; got != 3 {
+		t.Errorf("之前的请求已经滑出窗口，预期MaxPass为3，实际: %d", got)
+	}
+}
+
+// 测试过载保护 - Check只判断是否过载，不计入正在处理的请求数和延迟
+func TestBBRCheck(t *testing.T) {
+	clock := limiter.NewManualClock(time.Unix(1700000000, 0))
+	var cpu atomic.Int64
+	cpu.Store(900)
+	lim := newBBR(clock, &cpu)
+
+	// 没有历史数据时只允许1个并发
+	for i := 0; i < 10; i++ {
+		if err := lim.Check(); err != nil {
+			t.Fatalf("第%d次Check err:%v", i, err)
+		}
+	}
+	if got := lim.Stat().InFlight; got != 0 {
+		t.Errorf("Check不计入正在处理的请求数，实际: %d", got)
+	}
+
+	done, err := lim.Allow()
+	if err != nil {
+		t.Fatalf("预期允许处理请求，err:%v", err)
+	}
+	if err := lim.Check(); !errors.Is(err, bbr.ErrLimitExceeded) {
+		t.Fatalf("过载时预期返回ErrLimitExceeded，实际: %v", err)
+	}
+	done()
+}
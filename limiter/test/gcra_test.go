@@ -0,0 +1,67 @@
+package test
+
+import (
+	"limiter"
+	"testing"
+	"time"
+)
+
+// 测试GCRA - 返回剩余令牌数、重试时间和恢复满桶的时间
+func TestGCRAResult(t *testing.T) {
+	// 每秒生成1个令牌，容量为2
+	lim := limiter.NewGCRA(limiter.Every(time.Second), 2)
+	now := time.Now()
+
+	cases := []struct {
+		at   time.Duration
+		n    int
+		want limiter.Result
+	}{
+		{0, 1, limiter.Result{Allowed: true, Remaining: 1, ResetAfter: time.Second}},
+		{0, 1, limiter.Result{Allowed: true, Remaining: 0, ResetAfter: 2 * time.Second}},
+		{0, 1, limiter.Result{Allowed: false, Remaining: 0, RetryAfter: time.Second, ResetAfter: 2 * time.Second}},
+		{500 * time.Millisecond, 1, limiter.Result{Allowed: false, Remaining: 0, RetryAfter: 500 * time.Millisecond, ResetAfter: 1500 * time.Millisecond}},
+		{time.Second, 1, limiter.Result{Allowed: true, Remaining: 0, ResetAfter: 2 * time.Second}},
+		{5 * time.Second, 2, limiter.Result{Allowed: true, Remaining: 0, ResetAfter: 2 * time.Second}},
+		{5 * time.Second, 3, limiter.Result{Allowed: false, Remaining: 0, RetryAfter: limiter.InfDuration, ResetAfter: 2 * time.Second}},
+	}
+	for i, c := range cases {
+		got := lim.RateLimitN(now.Add(c.at), c.n)
+		if got != c.want {
+			t.Errorf("第%d次请求 预期 %+v，实际 %+v", i, c.want, got)
+		}
+	}
+}
+
+// 测试GCRA - 和令牌桶的Limit、容量含义一致，同一组请求的结果相同
+func TestGCRAMatchesTokenBucket(t *testing.T) {
+	start := time.Now()
+	want := runTrace(limiter.New(3, 3), start)
+	got := runTrace(limiter.NewGCRA(3, 3), start)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第%d个请求(%dms) 令牌桶 %v，GCRA %v", i, windowTrace[i], want[i], got[i])
+		}
+	}
+}
+
+// 测试GCRA - Evaluate不修改状态，可以基于外部存储的tat计算
+func TestGCRAEvaluate(t *testing.T) {
+	lim := limiter.NewGCRA(limiter.Every(time.Second), 1)
+	now := time.Now()
+
+	// 外部存储的状态
+	var tat time.Time
+	tat, res := lim.Evaluate(tat, now, 1)
+	if !res.Allowed {
+		t.Fatal("满桶时预期允许执行")
+	}
+	if _, res = lim.Evaluate(tat, now, 1); res.Allowed {
+		t.Error("基于新的tat计算，预期拒绝")
+	}
+
+	// 限流器自身的状态未被修改
+	if !lim.AllowN(now, 1) {
+		t.Error("Evaluate不应修改限流器的状态")
+	}
+}
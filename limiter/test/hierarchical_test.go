@@ -0,0 +1,226 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"limiter"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 生成 用户、租户、全局 三个层级的限流器
+func newTiers(clock limiter.Clock, user, tenant, global int) (*limiter.HierarchicalLimiter, *limiter.KeyedLimiter, *limiter.KeyedLimiter, *limiter.TokenBucket) {
+	users := limiter.NewKeyed(limiter.Every(time.Second), user, limiter.WithClock(clock))
+	tenants := limiter.NewKeyed(limiter.Every(time.Second), tenant, limiter.WithClock(clock))
+	all := limiter.New(limiter.Every(time.Second), global, limiter.WithClock(clock))
+	lim := limiter.NewHierarchical([]limiter.Tier{
+		{Name: "user", Keyed: users},
+		{Name: "tenant", Keyed: tenants},
+		{Name: "global", Bucket: all},
+	}, limiter.WithClock(clock))
+	return lim, users, tenants, all
+}
+
+// 测试多层级限流 - 内层拒绝时不扣减外层的令牌
+func TestHierarchicalInnerTierDenies(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim, _, tenants, all := newTiers(clock, 1, 10, 10)
+
+	if d := lim.Allow("u1", "t1"); !d.Allowed {
+		t.Fatalf("预期允许执行，被层级 %s 拒绝", d.Tier)
+	}
+	d := lim.Allow("u1", "t1")
+	if d.Allowed || d.Tier != "user" {
+		t.Fatalf("预期被层级 user 拒绝，实际: %+v", d)
+	}
+	if d.RetryAfter != time.Second {
+		t.Errorf("预期 %v 后重试，实际: %v", time.Second, d.RetryAfter)
+	}
+
+	// 被拒绝的请求没有扣减租户和全局的令牌
+	if tokens := tenants.Get("t1").Tokens(); tokens != 9 {
+		t.Errorf("租户剩余令牌数预期为9，实际: %v", tokens)
+	}
+	if tokens := all.Tokens(); tokens != 9 {
+		t.Errorf("全局剩余令牌数预期为9，实际: %v", tokens)
+	}
+}
+
+// 测试多层级限流 - 外层拒绝时不扣减内层的令牌
+func TestHierarchicalOuterTierDenies(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim, users, _, _ := newTiers(clock, 1, 10, 2)
+
+	lim.Allow("u1", "t1")
+	lim.Allow("u2", "t1")
+	if d := lim.Allow("u3", "t1"); d.Allowed || d.Tier != "global" {
+		t.Fatalf("预期被层级 global 拒绝，实际: %+v", d)
+	}
+	if tokens := users.Get("u3").Tokens(); tokens != 1 {
+		t.Errorf("用户剩余令牌数预期为1，实际: %v", tokens)
+	}
+
+	// 全局生成令牌后，u3 可以执行
+	clock.Advance(time.Second)
+	if d := lim.Allow("u3", "t1"); !d.Allowed {
+		t.Errorf("预期允许执行，被层级 %s 拒绝", d.Tier)
+	}
+}
+
+// 测试多层级限流 - Decision返回做出决定时每个层级的令牌桶的状态
+func TestHierarchicalDecisionStates(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim, _, _, _ := newTiers(clock, 2, 10, 10)
+
+	// 允许执行时为扣减之后的令牌数
+	d := lim.Allow("u1", "t1")
+	want := []limiter.TierState{
+		{Limit: limiter.Every(time.Second), Burst: 2, Tokens: 1},
+		{Limit: limiter.Every(time.Second), Burst: 10, Tokens: 9},
+		{Limit: limiter.Every(time.Second), Burst: 10, Tokens: 9},
+	}
+	if !d.Allowed || len(d.States) != len(want) {
+		t.Fatalf("预期允许执行并返回3个层级的状态，实际: %+v", d)
+	}
+	for i, w := range want {
+		if d.States[i] != w {
+			t.Errorf("第%d个层级 预期 %+v，实际 %+v", i, w, d.States[i])
+		}
+	}
+
+	// 拒绝时为当时的令牌数，恢复满桶的时间和RetryAfter按同一个时间计算
+	lim.Allow("u1", "t1")
+	clock.Advance(500 * time.Millisecond)
+	d = lim.Allow("u1", "t1")
+	if d.Allowed || d.Tier != "user" {
+		t.Fatalf("预期被层级 user 拒绝，实际: %+v", d)
+	}
+	if s := d.States[0]; s.Tokens != 0.5 || s.ResetAfter() != 1500*time.Millisecond {
+		t.Errorf("预期剩余0.5个令牌、1.5s后恢复满桶，实际: %+v，%v", s, s.ResetAfter())
+	}
+	if d.RetryAfter != 500*time.Millisecond {
+		t.Errorf("预期 500ms 后重试，实际: %v", d.RetryAfter)
+	}
+}
+
+// 测试多层级限流 - 多个层级同时拒绝时，返回最内层的层级，RetryAfter取所有层级中最长的等待时间
+func TestHierarchicalRetryAfterMax(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	users := limiter.NewKeyed(limiter.Every(time.Second), 1, limiter.WithClock(clock))
+	all := limiter.New(limiter.Every(5*time.Second), 1, limiter.WithClock(clock))
+	lim := limiter.NewHierarchical([]limiter.Tier{
+		{Name: "user", Keyed: users},
+		{Name: "global", Bucket: all},
+	}, limiter.WithClock(clock))
+
+	if d := lim.Allow("u1"); !d.Allowed {
+		t.Fatalf("预期允许执行，被层级 %s 拒绝", d.Tier)
+	}
+	d := lim.Allow("u1")
+	if d.Allowed || d.Tier != "user" {
+		t.Fatalf("预期被层级 user 拒绝，实际: %+v", d)
+	}
+	if d.RetryAfter != 5*time.Second {
+		t.Errorf("预期 %v 后重试，实际: %v", 5*time.Second, d.RetryAfter)
+	}
+
+	// 只等待user层级的时间，仍然会被global拒绝
+	clock.Advance(time.Second)
+	if d := lim.Allow("u1"); d.Allowed || d.Tier != "global" || d.RetryAfter != 4*time.Second {
+		t.Errorf("预期被层级 global 拒绝，4s后重试，实际: %+v", d)
+	}
+	clock.Advance(4 * time.Second)
+	if d := lim.Allow("u1"); !d.Allowed {
+		t.Errorf("预期允许执行，被层级 %s 拒绝", d.Tier)
+	}
+}
+
+// 测试多层级限流 - n超过某个层级的容量
+func TestHierarchicalExceedsCapacity(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim, _, _, _ := newTiers(clock, 5, 3, 10)
+
+	d := lim.AllowN(clock.Now(), 4, "u1", "t1")
+	if d.Allowed || d.Tier != "tenant" || d.RetryAfter != limiter.InfDuration {
+		t.Errorf("预期被层级 tenant 拒绝且无法重试，实际: %+v", d)
+	}
+	if err := lim.WaitN(context.Background(), 4, "u1", "t1"); err == nil {
+		t.Error("n超过桶容量时预期返回错误")
+	}
+}
+
+// 测试多层级限流 - Wait取消时归还所有层级预定的令牌
+func TestHierarchicalWaitCanceled(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim, _, _, all := newTiers(clock, 1, 1, 1)
+	lim.Allow("u1", "t1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- lim.Wait(ctx, "u1", "t1")
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("预期错误 %v，实际得到 %v", context.Canceled, err)
+	}
+
+	// 预定的令牌已归还，1秒后所有层级都有令牌
+	clock.Advance(time.Second)
+	if d := lim.Allow("u1", "t1"); !d.Allowed {
+		t.Errorf("取消后预期归还预定的令牌，被层级 %s 拒绝", d.Tier)
+	}
+	if tokens := all.Tokens(); tokens != 0 {
+		t.Errorf("全局剩余令牌数预期为0，实际: %v", tokens)
+	}
+}
+
+// 测试多层级限流 - Wait等待到所有层级都允许执行
+func TestHierarchicalWait(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim, _, _, _ := newTiers(clock, 1, 1, 1)
+	lim.Allow("u1", "t1")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- lim.Wait(context.Background(), "u2", "t1")
+	}()
+	elapsed, err := advanceUntilDone(clock, 100*time.Millisecond, done)
+	if err != nil {
+		t.Fatalf("Wait失败，err:%v", err)
+	}
+	if elapsed != time.Second {
+		t.Errorf("预期等待 %v，实际等待: %v", time.Second, elapsed)
+	}
+}
+
+// 测试多层级限流 - 并发请求时放行的数量不超过任意层级的容量
+func TestHierarchicalConcurrent(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim, _, _, _ := newTiers(clock, 5, 20, 30)
+
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// 10个用户，分属2个租户
+			user := "u" + strconv.Itoa(i%10)
+			tenant := "t" + strconv.Itoa(i%2)
+			if lim.Allow(user, tenant).Allowed {
+				allowed.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 每个租户5个用户，租户的容量20 < 5*5，全局的容量30 < 2*20
+	if got := allowed.Load(); got != 30 {
+		t.Errorf("预期放行30个请求，实际: %d", got)
+	}
+}
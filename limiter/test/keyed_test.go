@@ -0,0 +1,187 @@
+package test
+
+import (
+	"limiter"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试按key限流 - 每个key使用独立的令牌桶
+func TestKeyedLimiterPerKey(t *testing.T) {
+	lim := limiter.NewKeyed(limiter.Every(time.Second), 1)
+	now := time.Now()
+
+	if !lim.AllowN("user-1", now, 1) {
+		t.Error("user-1 预期允许执行")
+	}
+	if lim.AllowN("user-1", now, 1) {
+		t.Error("user-1 的令牌已用完，预期拒绝")
+	}
+	if !lim.AllowN("user-2", now, 1) {
+		t.Error("user-2 使用独立的令牌桶，预期允许执行")
+	}
+	if lim.Len() != 2 {
+		t.Errorf("预期保存2个key，实际: %d", lim.Len())
+	}
+}
+
+// 测试按key限流 - 清理满桶且空闲的桶
+func TestKeyedLimiterIdleEviction(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewKeyed(limiter.Every(10*time.Millisecond), 1,
+		limiter.WithIdleTTL(50*time.Millisecond), limiter.WithShards(1), limiter.WithClock(clock))
+
+	lim.Allow("idle")
+	lim.Allow("busy")
+	clock.Advance(60 * time.Millisecond)
+
+	// busy 空闲时间超过idleTTL，但在清理之前又被使用，不会被清理
+	lim.Get("busy").ReserveN(clock.Now(), 1)
+	lim.Cleanup()
+
+	if lim.Len() != 1 {
+		t.Errorf("预期只剩1个key，实际: %d", lim.Len())
+	}
+}
+
+// 测试按key限流 - 未满的桶即使空闲也不会被清理
+func TestKeyedLimiterKeepsNonFullBucket(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewKeyed(limiter.Every(time.Hour), 1, limiter.WithIdleTTL(10*time.Millisecond), limiter.WithClock(clock))
+
+	lim.Allow("user")
+	clock.Advance(20 * time.Millisecond)
+	lim.Cleanup()
+
+	if lim.Len() != 1 {
+		t.Errorf("未满的桶预期保留，实际key数: %d", lim.Len())
+	}
+	if lim.Allow("user") {
+		t.Error("桶的状态预期保留，令牌已用完")
+	}
+}
+
+// 测试按key限流 - key的数量上限
+func TestKeyedLimiterMaxKeys(t *testing.T) {
+	lim := limiter.NewKeyed(limiter.Every(time.Second), 2, limiter.WithMaxKeys(2), limiter.WithShards(1))
+	now := time.Now()
+
+	lim.AllowN("a", now, 1)
+	lim.AllowN("b", now.Add(time.Millisecond), 1)
+	lim.AllowN("a", now.Add(2*time.Millisecond), 1)
+
+	// 1.5秒时b已经是满桶，a还没有满；淘汰满桶b，a的配额不会被重置
+	at := now.Add(1500 * time.Millisecond)
+	lim.AllowN("c", at, 1)
+	keys := map[string]bool{}
+	lim.Range(func(key string, bucket *limiter.TokenBucket) bool {
+		keys[key] = true
+		return true
+	})
+	if len(keys) != 2 || !keys["a"] || !keys["c"] {
+		t.Errorf("预期保存a、c，实际: %v", keys)
+	}
+	if lim.AllowN("a", at, 2) {
+		t.Error("a 的桶未满，不应该被淘汰")
+	}
+}
+
+// 测试按key限流 - 没有满桶可以淘汰时，新的key共用溢出桶，不能通过新key重置已有key的配额
+func TestKeyedLimiterMaxKeysOverflow(t *testing.T) {
+	lim := limiter.NewKeyed(limiter.Every(time.Hour), 1, limiter.WithMaxKeys(2), limiter.WithShards(1))
+	now := time.Now()
+
+	lim.AllowN("a", now, 1)
+	lim.AllowN("b", now, 1)
+
+	// 大量新key只能使用溢出桶
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		if lim.AllowN("spray-"+strconv.Itoa(i), now, 1) {
+			allowed++
+		}
+	}
+	if allowed != 1 {
+		t.Errorf("新的key共用溢出桶，预期只允许1次，实际: %d", allowed)
+	}
+	if lim.AllowN("a", now, 1) || lim.AllowN("b", now, 1) {
+		t.Error("已有key的桶不应该被淘汰")
+	}
+	if lim.Len() != 2 {
+		t.Errorf("预期最多保存2个key，实际: %d", lim.Len())
+	}
+}
+
+// 测试按key限流 - 默认分片数时，key的总数也不超过上限
+func TestKeyedLimiterMaxKeysDefaultShards(t *testing.T) {
+	for _, maxKeys := range []int{10, 100} {
+		lim := limiter.NewKeyed(limiter.Every(time.Second), 1, limiter.WithMaxKeys(maxKeys))
+		now := time.Now()
+		for i := 0; i < 1000; i++ {
+			lim.AllowN("key-"+strconv.Itoa(i), now, 1)
+			// 满桶可以被淘汰
+			now = now.Add(time.Second)
+		}
+		if n := lim.Len(); n > maxKeys {
+			t.Errorf("WithMaxKeys(%d) 预期最多保存 %d 个key，实际: %d", maxKeys, maxKeys, n)
+		}
+	}
+}
+
+// 测试按key限流 - key的数量上限是所有分片共享的，key在分片之间分布不均匀时，没有达到上限的key不会使用溢出桶
+func TestKeyedLimiterMaxKeysSkewed(t *testing.T) {
+	// 100个key分布到默认的32个分片，平均每个分片3个多，总有分片多于平均数
+	const maxKeys = 100
+	lim := limiter.NewKeyed(limiter.Every(time.Hour), 1, limiter.WithMaxKeys(maxKeys))
+	now := time.Now()
+
+	for i := 0; i < maxKeys; i++ {
+		if !lim.AllowN("key-"+strconv.Itoa(i), now, 1) {
+			t.Fatalf("第%d个key没有达到上限，预期使用自己的桶，允许执行", i)
+		}
+	}
+	if n := lim.Len(); n != maxKeys {
+		t.Errorf("预期保存 %d 个key，实际: %d", maxKeys, n)
+	}
+
+	// 达到上限后，新的key共用溢出桶
+	if !lim.AllowN("new-0", now, 1) || lim.AllowN("new-1", now, 1) {
+		t.Error("达到上限后，新的key预期共用溢出桶")
+	}
+	if n := lim.Len(); n != maxKeys {
+		t.Errorf("预期最多保存 %d 个key，实际: %d", maxKeys, n)
+	}
+}
+
+// 测试按key限流 - 并发访问
+func TestKeyedLimiterConcurrent(t *testing.T) {
+	lim := limiter.NewKeyed(limiter.Every(time.Hour), 5, limiter.WithMaxKeys(1000))
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := make(map[string]int)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := "key-" + strconv.Itoa(j%10)
+				if lim.AllowN(key, now, 1) {
+					mu.Lock()
+					allowed[key]++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for key, n := range allowed {
+		if n != 5 {
+			t.Errorf("%s 预期放行5个请求，实际: %d", key, n)
+		}
+	}
+}
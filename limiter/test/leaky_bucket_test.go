@@ -0,0 +1,100 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"limiter"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试漏桶 - 突发请求被平滑为固定间隔放行
+func TestLeakyBucketSmoothsBurst(t *testing.T) {
+	interval := 10 * time.Millisecond
+	start := time.Now()
+	clock := limiter.NewManualClock(start)
+	lim := limiter.NewLeakyBucket(limiter.Every(interval), 10, limiter.WithClock(clock))
+
+	// 同时发起5个请求
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var released []time.Time
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			at, err := lim.Take(context.Background())
+			if err != nil {
+				t.Errorf("Take失败，err:%v", err)
+				return
+			}
+			mu.Lock()
+			released = append(released, at)
+			mu.Unlock()
+		}()
+	}
+	// 第一个请求立即放行，其余4个排队等待
+	clock.BlockUntil(4)
+	clock.Advance(4 * interval)
+	wg.Wait()
+
+	// 放行时间依次间隔interval
+	sort.Slice(released, func(i, j int) bool { return released[i].Before(released[j]) })
+	for i, at := range released {
+		if want := start.Add(time.Duration(i) * interval); !at.Equal(want) {
+			t.Errorf("第%d个请求 预期放行时间 +%v，实际 +%v", i, want.Sub(start), at.Sub(start))
+		}
+	}
+}
+
+// 测试漏桶 - 排队的调用方已满
+func TestLeakyBucketQueueFull(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewLeakyBucket(limiter.Every(time.Hour), 1, limiter.WithClock(clock))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 第一个调用方立即放行
+	if _, err := lim.Take(ctx); err != nil {
+		t.Fatalf("预期立即放行，err:%v", err)
+	}
+
+	// 第二个调用方排队
+	done := make(chan error, 1)
+	go func() {
+		_, err := lim.Take(ctx)
+		done <- err
+	}()
+	clock.BlockUntil(1)
+
+	// 第三个调用方超过队列限制
+	if _, err := lim.Take(ctx); !errors.Is(err, limiter.ErrQueueFull) {
+		t.Errorf("预期错误 %v，实际得到 %v", limiter.ErrQueueFull, err)
+	}
+
+	// 取消排队中的调用方
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("预期错误 %v，实际得到 %v", context.Canceled, err)
+	}
+}
+
+// 测试漏桶 - 截止时间之前无法放行时立即返回
+func TestLeakyBucketDeadline(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewLeakyBucket(limiter.Every(time.Hour), 10, limiter.WithClock(clock))
+	lim.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := lim.Take(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("预期错误 %v，实际得到 %v", context.DeadlineExceeded, err)
+	}
+
+	// 第一个调用方的放行间隔还未过去，不排队时仍然拒绝
+	if lim.AllowN(clock.Now(), 1) {
+		t.Error("放行时间未到，预期拒绝")
+	}
+}
@@ -1,93 +1,123 @@
 package test
 
 import (
-	"fmt"
 	"limiter"
-	"sync"
 	"testing"
 	"time"
 )
 
-// 测试limiter
-func TestLimiterNotEqualRates(t *testing.T) {
-	// 生成速率
-	limit := limiter.Every(time.Duration(1 * time.Second))
-
-	// 生成令牌桶
-	tokenBucket := limiter.New(limit, 2)
-
-	fmt.Println("开始发起请求")
-	// 模拟请求
-	var succeedCount, failedCount int
-	requestDuration := time.Duration(500 * time.Millisecond)
-	for i := 0; i < 10; i++ {
-		time.Sleep(requestDuration)
-		token := tokenBucket.Allow()
-		if token {
-			succeedCount++
-			// fmt.Printf("%d获取到令牌，执行成功！ 剩余令牌数：【%f】\n", i, math.Floor(tokenBucket.Tokens()))
-			fmt.Printf("%d获取到令牌，执行成功！\n", i)
+// 按固定间隔发起count次请求，返回每次请求是否获取到令牌
+func requestEvery(clock *limiter.ManualClock, tokenBucket *limiter.TokenBucket, interval time.Duration, count int) []bool {
+	results := make([]bool, 0, count)
+	for i := 0; i < count; i++ {
+		clock.Advance(interval)
+		results = append(results, tokenBucket.Allow())
+	}
+	return results
+}
 
-		} else {
-			failedCount++
-			fmt.Printf("%d未获取到令牌，执行失败.....\n", i)
+// 校验每次请求的结果
+func assertResults(t *testing.T, got, want []bool) {
+	t.Helper()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第%d次请求 预期 %v，实际 %v", i, want[i], got[i])
 		}
 	}
-	fmt.Printf("令牌生成速率： %v/秒\n", limit)
-	fmt.Printf("请求执行速率：%v秒/次\n", requestDuration.Seconds())
-	fmt.Printf("执行成功数: %v\n", succeedCount)
-	fmt.Printf("执行失败数: %v\n", failedCount)
 }
 
-// 测试limiter - 令牌生成速率 = 请求速率
-func TestLimiterEqualRates(t *testing.T) {
-	// 生成速率
-	limit := limiter.Every(time.Duration(1 * time.Second))
+// 测试limiter - 请求速率 大于 令牌生成速率
+// 令牌生成速率：1个/秒，请求速率：0.5秒/次，第一次请求时是满桶
+func TestLimiterNotEqualRates(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	tokenBucket := limiter.New(limiter.Every(time.Second), 2, limiter.WithClock(clock))
 
-	// 生成令牌桶
-	tokenBucket := limiter.New(limit, 2)
+	const T, F = true, false
+	got := requestEvery(clock, tokenBucket, 500*time.Millisecond, 10)
+	assertResults(t, got, []bool{T, T, T, F, T, F, T, F, T, F})
+}
 
-	fmt.Println("开始发起请求")
-	// 模拟请求
-	var succeedCount, failedCount int
-	requestDuration := time.Duration(1 * time.Second)
-	for i := 0; i < 10; i++ {
-		time.Sleep(requestDuration)
-		token := tokenBucket.Allow()
-		if token {
-			succeedCount++
-			// fmt.Printf("%d获取到令牌，执行成功！ 剩余令牌数：【%f】\n", i, math.Floor(tokenBucket.Tokens()))
-			fmt.Printf("%d获取到令牌，执行成功！\n", i)
+// 测试limiter - 令牌生成速率 = 请求速率，所有请求都能执行成功
+func TestLimiterEqualRates(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	tokenBucket := limiter.New(limiter.Every(time.Second), 2, limiter.WithClock(clock))
 
-		} else {
-			failedCount++
-			fmt.Printf("%d未获取到令牌，执行失败.....\n", i)
-		}
+	got := requestEvery(clock, tokenBucket, time.Second, 10)
+	want := make([]bool, 10)
+	for i := range want {
+		want[i] = true
 	}
-	fmt.Printf("令牌生成速率： %v个/秒\n", limit)
-	fmt.Printf("请求执行速率：%v秒/次\n", requestDuration.Seconds())
-	fmt.Printf("执行成功数: %v\n", succeedCount)
-	fmt.Printf("执行失败数: %v\n", failedCount)
+	assertResults(t, got, want)
 }
 
+// 测试limiter - 请求速率 小于 令牌生成速率，令牌不会超过桶的容量
+func TestLimiterBurstCapped(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	tokenBucket := limiter.New(limiter.Every(time.Second), 2, limiter.WithClock(clock))
 
-type testLock struct {
-	mu sync.Mutex
+	// 空闲10秒，桶中最多只有2个令牌
+	clock.Advance(10 * time.Second)
+	const T, F = true, false
+	got := []bool{tokenBucket.Allow(), tokenBucket.Allow(), tokenBucket.Allow()}
+	assertResults(t, got, []bool{T, T, F})
 }
 
-// 测试死锁
-func TestDeadlock(t *testing.T) {
-	tl := &testLock{}
-	tl.mu.Lock()
-	defer tl.mu.Unlock()
-	fmt.Println("TestLock")
-	b(tl)
+// 测试TakeUpTo - 令牌不足时获取桶中所有完整的令牌，一个令牌都没有时返回等待时间，不欠账
+func TestTakeUpTo(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	tokenBucket := limiter.New(limiter.Every(time.Second), 15, limiter.WithClock(clock))
+	now := clock.Now()
 
+	for i, want := range []int{10, 5, 0} {
+		granted, wait := tokenBucket.TakeUpTo(now, 10)
+		if granted != want {
+			t.Errorf("第%d次 预期获取 %d 个令牌，实际 %d", i, want, granted)
+		}
+		if want == 0 && wait != time.Second {
+			t.Errorf("没有令牌时预期等待1s，实际: %v", wait)
+		}
+	}
+	// 没有获取到令牌时不欠账
+	if tokens := tokenBucket.Tokens(); tokens != 0 {
+		t.Errorf("预期剩余0个令牌，实际: %v", tokens)
+	}
+
+	// 1.5秒后只有1个完整的令牌
+	granted, _ := tokenBucket.TakeUpTo(now.Add(1500*time.Millisecond), 10)
+	if granted != 1 {
+		t.Errorf("预期获取1个令牌，实际 %d", granted)
+	}
 }
 
-func b(tl *testLock) {
-	// ! 重复加锁，这里会导致死锁
-	tl.mu.Lock()
-	defer tl.mu.Unlock()
-	fmt.Println("b")
+// 测试limiter - 不限速
+func TestLimiterInf(t *testing.T) {
+	tokenBucket := limiter.New(limiter.Inf, 1)
+	for i := 0; i < 100; i++ {
+		if !tokenBucket.Allow() {
+			t.Fatalf("不限速时第%d次请求预期允许执行", i)
+		}
+	}
+}
+
+func TestBucketParams(t *testing.T) {
+	tests := []struct {
+		limit     float64
+		burst     int
+		wantLimit limiter.Limit
+		wantBurst int
+		wantOK    bool
+	}{
+		{limit: 0, burst: 10, wantOK: false},
+		{limit: -1, burst: 10, wantOK: false},
+		{limit: 10, burst: 20, wantLimit: 10, wantBurst: 20, wantOK: true},
+		{limit: 2.5, burst: 0, wantLimit: 2.5, wantBurst: 3, wantOK: true},
+		{limit: 0.1, burst: 0, wantLimit: 0.1, wantBurst: 1, wantOK: true},
+	}
+	for _, tt := range tests {
+		limit, burst, ok := limiter.BucketParams(tt.limit, tt.burst)
+		if limit != tt.wantLimit || burst != tt.wantBurst || ok != tt.wantOK {
+			t.Errorf("BucketParams(%v, %d) = (%v, %d, %v), want (%v, %d, %v)",
+				tt.limit, tt.burst, limit, burst, ok, tt.wantLimit, tt.wantBurst, tt.wantOK)
+		}
+	}
 }
@@ -0,0 +1,117 @@
+package test
+
+import (
+	"limiter"
+	"limiter/metrics"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// 测试Prometheus采集器
+func TestCollector(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	tokenBucket := limiter.New(limiter.Every(time.Second), 1, limiter.WithClock(clock))
+	tokenBucket.Allow()
+	tokenBucket.Allow()
+
+	c := metrics.New("test")
+	c.Add("global", tokenBucket)
+
+	want := `
+# HELP test_limiter_requests_total Number of requests allowed or denied by the limiter.
+# TYPE test_limiter_requests_total counter
+test_limiter_requests_total{limiter="global",result="allowed"} 1
+test_limiter_requests_total{limiter="global",result="denied"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "test_limiter_requests_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+// 测试Prometheus采集器 - 按key导出的指标不超过上限
+func TestCollectorKeyedCardinality(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewKeyed(limiter.Every(time.Second), 1, limiter.WithClock(clock))
+
+	c := metrics.New("")
+	c.AddKeyed("user", lim, 2)
+
+	// 先出现的2个key单独导出
+	lim.Allow("u0")
+	lim.Allow("u1")
+	testutil.CollectAndCount(c)
+	for i := 2; i < 10; i++ {
+		lim.Allow("u" + strconv.Itoa(i))
+	}
+
+	// 2个key + __other__，每个key有allowed、denied两个指标
+	if n := testutil.CollectAndCount(c, "limiter_key_requests_total"); n != 6 {
+		t.Errorf("预期导出6个key指标，实际: %d", n)
+	}
+	want := `
+# HELP limiter_key_requests_total Number of requests allowed or denied per key.
+# TYPE limiter_key_requests_total counter
+limiter_key_requests_total{key="__other__",limiter="user",result="allowed"} 8
+limiter_key_requests_total{key="__other__",limiter="user",result="denied"} 0
+limiter_key_requests_total{key="u0",limiter="user",result="allowed"} 1
+limiter_key_requests_total{key="u0",limiter="user",result="denied"} 0
+limiter_key_requests_total{key="u1",limiter="user",result="allowed"} 1
+limiter_key_requests_total{key="u1",limiter="user",result="denied"} 0
+# HELP limiter_keys Number of keys currently held by the keyed limiter.
+# TYPE limiter_keys gauge
+limiter_keys{limiter="user"} 10
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "limiter_key_requests_total", "limiter_keys"); err != nil {
+		t.Error(err)
+	}
+}
+
+// 测试Prometheus采集器 - 已导出的key被清理后，__other__不会减小
+func TestCollectorKeyedOtherMonotonic(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewKeyed(limiter.Every(time.Second), 1, limiter.WithClock(clock), limiter.WithIdleTTL(time.Minute))
+
+	c := metrics.New("")
+	c.AddKeyed("user", lim, 1)
+
+	lim.Allow("u0")
+	testutil.CollectAndCount(c)
+	lim.Allow("u1")
+	lim.Allow("u1")
+	want := func(u0Allowed, otherAllowed, otherDenied int) string {
+		s := `
+# HELP limiter_key_requests_total Number of requests allowed or denied per key.
+# TYPE limiter_key_requests_total counter
+limiter_key_requests_total{key="__other__",limiter="user",result="allowed"} ` + strconv.Itoa(otherAllowed) + `
+limiter_key_requests_total{key="__other__",limiter="user",result="denied"} ` + strconv.Itoa(otherDenied) + `
+`
+		if u0Allowed > 0 {
+			s += `limiter_key_requests_total{key="u0",limiter="user",result="allowed"} ` + strconv.Itoa(u0Allowed) + `
+limiter_key_requests_total{key="u0",limiter="user",result="denied"} 0
+`
+		}
+		return s
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want(1, 1, 1)), "limiter_key_requests_total"); err != nil {
+		t.Error(err)
+	}
+
+	// u0、u1被清理，u0的计数不会回到__other__
+	clock.Advance(2 * time.Minute)
+	lim.Cleanup()
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want(0, 1, 1)), "limiter_key_requests_total"); err != nil {
+		t.Error(err)
+	}
+
+	// 空出的位置给新的key，之后的增量计入新的key，__other__不变
+	lim.Allow("u2")
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want(0, 1, 1)+`limiter_key_requests_total{key="u2",limiter="user",result="allowed"} 1
+limiter_key_requests_total{key="u2",limiter="user",result="denied"} 0
+`), "limiter_key_requests_total"); err != nil {
+		t.Error(err)
+	}
+}
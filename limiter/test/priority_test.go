@@ -0,0 +1,160 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"limiter"
+	"testing"
+	"time"
+)
+
+// 排队等待的结果
+type waitResult struct {
+	name string
+	err  error
+}
+
+// 在goroutine中以优先级p等待，等到它进入队列后返回
+func startWaiter(t *testing.T, ctx context.Context, lim *limiter.PriorityLimiter, name string, p limiter.Priority, results chan<- waitResult) {
+	t.Helper()
+	queued := lim.QueueLen()
+	go func() {
+		err := lim.Wait(limiter.WithPriority(ctx, p))
+		results <- waitResult{name: name, err: err}
+	}()
+	for lim.QueueLen() == queued {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 测试优先级限流器 - 令牌先给优先级高的调用方，同一优先级按到达顺序
+func TestPriorityOrder(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewPriority(limiter.Every(time.Second), 1, limiter.WithClock(clock))
+	if !lim.Allow() {
+		t.Fatal("满桶时预期允许执行")
+	}
+
+	results := make(chan waitResult, 4)
+	ctx := context.Background()
+	startWaiter(t, ctx, lim, "sheddable", limiter.PrioritySheddable, results)
+	startWaiter(t, ctx, lim, "default-1", limiter.PriorityDefault, results)
+	startWaiter(t, ctx, lim, "critical", limiter.PriorityCritical, results)
+	startWaiter(t, ctx, lim, "default-2", limiter.PriorityDefault, results)
+
+	// 每秒生成1个令牌，每次只有一个调用方拿到令牌
+	for _, want := range []string{"critical", "default-1", "default-2", "sheddable"} {
+		clock.Advance(time.Second)
+		got := <-results
+		if got.err != nil || got.name != want {
+			t.Fatalf("预期 %s 拿到令牌，实际: %s，err:%v", want, got.name, got.err)
+		}
+	}
+	if n := lim.QueueLen(); n != 0 {
+		t.Errorf("预期队列为空，实际: %d", n)
+	}
+}
+
+// 测试优先级限流器 - 队列已满时先丢弃优先级最低、最后到达的调用方
+func TestPriorityShed(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewPriority(limiter.Every(time.Second), 1, limiter.WithClock(clock), limiter.WithMaxQueue(2))
+	lim.Allow()
+
+	results := make(chan waitResult, 3)
+	ctx := context.Background()
+	startWaiter(t, ctx, lim, "default-1", limiter.PriorityDefault, results)
+	startWaiter(t, ctx, lim, "default-2", limiter.PriorityDefault, results)
+
+	// 优先级不高于队列中最低的调用方，直接丢弃
+	for _, p := range []limiter.Priority{limiter.PrioritySheddable, limiter.PriorityDefault} {
+		if err := lim.Wait(limiter.WithPriority(ctx, p)); !errors.Is(err, limiter.ErrShed) {
+			t.Fatalf("%s 预期返回ErrShed，实际: %v", p, err)
+		}
+	}
+
+	// 优先级更高，丢弃最后到达的default-2
+	go func() {
+		err := lim.Wait(limiter.WithPriority(ctx, limiter.PriorityCritical))
+		results <- waitResult{name: "critical", err: err}
+	}()
+	got := <-results
+	if got.name != "default-2" || !errors.Is(got.err, limiter.ErrShed) {
+		t.Fatalf("预期丢弃default-2，实际: %s，err:%v", got.name, got.err)
+	}
+
+	for _, want := range []string{"critical", "default-1"} {
+		clock.Advance(time.Second)
+		got := <-results
+		if got.err != nil || got.name != want {
+			t.Fatalf("预期 %s 拿到令牌，实际: %s，err:%v", want, got.name, got.err)
+		}
+	}
+}
+
+// 测试优先级限流器 - 有调用方排队时，Allow不能插队
+func TestPriorityAllowNoBarging(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewPriority(limiter.Every(time.Second), 2, limiter.WithClock(clock))
+	lim.Allow()
+
+	// 桶中还有1个令牌，不够排队的调用方使用
+	done := make(chan error, 1)
+	go func() {
+		done <- lim.WaitN(context.Background(), 2)
+	}()
+	for lim.QueueLen() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if lim.Allow() {
+		t.Fatal("有调用方排队时不应该允许插队")
+	}
+
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("WaitN失败，err:%v", err)
+	}
+}
+
+// 测试优先级限流器 - 队首被取消后，下一个调用方继续等待
+func TestPriorityCancel(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewPriority(limiter.Every(time.Second), 1, limiter.WithClock(clock))
+	lim.Allow()
+
+	results := make(chan waitResult, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	startWaiter(t, ctx, lim, "first", limiter.PriorityDefault, results)
+	startWaiter(t, context.Background(), lim, "second", limiter.PriorityDefault, results)
+
+	cancel()
+	got := <-results
+	if got.name != "first" || !errors.Is(got.err, context.Canceled) {
+		t.Fatalf("预期first被取消，实际: %s，err:%v", got.name, got.err)
+	}
+
+	clock.Advance(time.Second)
+	got = <-results
+	if got.name != "second" || got.err != nil {
+		t.Fatalf("预期second拿到令牌，实际: %s，err:%v", got.name, got.err)
+	}
+
+	want := limiter.Stats{Allowed: 2, Denied: 1, Waited: 2, WaitTime: time.Second}
+	if got := lim.Stats(); got != want {
+		t.Errorf("预期统计信息 %+v，实际: %+v", want, got)
+	}
+}
+
+// 测试优先级 - ctx中没有设置时为default，超出范围时取最近的优先级
+func TestPriorityFromContext(t *testing.T) {
+	ctx := context.Background()
+	if p := limiter.PriorityFromContext(ctx); p != limiter.PriorityDefault {
+		t.Errorf("预期 default，实际: %s", p)
+	}
+	if p := limiter.PriorityFromContext(limiter.WithPriority(ctx, 10)); p != limiter.PrioritySheddable {
+		t.Errorf("预期 sheddable，实际: %s", p)
+	}
+	if p := limiter.PriorityFromContext(limiter.WithPriority(ctx, -1)); p != limiter.PriorityCritical {
+		t.Errorf("预期 critical，实际: %s", p)
+	}
+}
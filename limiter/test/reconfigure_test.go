@@ -0,0 +1,77 @@
+package test
+
+import (
+	"limiter"
+	"testing"
+	"time"
+)
+
+// 测试SetLimitAt - 修改速率前按旧速率生成令牌
+func TestSetLimitAt(t *testing.T) {
+	// 每秒生成1个令牌，容量为10
+	tokenBucket := limiter.New(limiter.Every(time.Second), 10)
+	now := time.Now()
+
+	// 取出所有令牌
+	if !tokenBucket.AllowN(now, 10) {
+		t.Fatal("满桶时预期允许执行")
+	}
+
+	// 2秒后把速率提高到每秒10个，此时按旧速率生成了2个令牌
+	tokenBucket.SetLimitAt(now.Add(2*time.Second), 10)
+	if tokens := tokenBucket.Tokens(); tokens != 2 {
+		t.Errorf("预期令牌数为2，实际: %v", tokens)
+	}
+	if limit := tokenBucket.Limit(); limit != 10 {
+		t.Errorf("预期速率为10，实际: %v", limit)
+	}
+
+	// 再过0.5秒，按新速率生成5个令牌，共7个
+	if !tokenBucket.AllowN(now.Add(2500*time.Millisecond), 7) {
+		t.Error("预期新速率生效后允许执行7个事件")
+	}
+}
+
+// 测试SetBurstAt - 容量变小时丢弃多出的令牌
+func TestSetBurstAt(t *testing.T) {
+	tokenBucket := limiter.New(limiter.Every(time.Second), 10)
+	now := time.Now()
+
+	tokenBucket.AllowN(now, 1)
+	tokenBucket.SetBurstAt(now, 3)
+	if tokens := tokenBucket.Tokens(); tokens != 3 {
+		t.Errorf("预期令牌数为3，实际: %v", tokens)
+	}
+	if burst := tokenBucket.Burst(); burst != 3 {
+		t.Errorf("预期容量为3，实际: %v", burst)
+	}
+	if tokenBucket.AllowN(now, 4) {
+		t.Error("n超过新的容量时预期拒绝")
+	}
+
+	// 容量变大时，令牌按新的容量累积
+	tokenBucket.SetBurstAt(now, 5)
+	if !tokenBucket.AllowN(now.Add(2*time.Second), 5) {
+		t.Error("预期按新的容量累积令牌后允许执行5个事件")
+	}
+}
+
+// 测试PutN - 放入令牌，不超过容量
+func TestPutN(t *testing.T) {
+	// 不按时间生成令牌，容量为5
+	tokenBucket := limiter.New(0, 5)
+	now := time.Now()
+
+	if !tokenBucket.AllowN(now, 5) {
+		t.Fatal("满桶时预期允许执行")
+	}
+	tokenBucket.PutN(now, 3)
+	if tokens := tokenBucket.Tokens(); tokens != 3 {
+		t.Errorf("预期令牌数为3，实际: %v", tokens)
+	}
+
+	tokenBucket.PutN(now, 10)
+	if tokens := tokenBucket.Tokens(); tokens != 5 {
+		t.Errorf("预期令牌数不超过容量5，实际: %v", tokens)
+	}
+}
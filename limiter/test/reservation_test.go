@@ -0,0 +1,93 @@
+package test
+
+import (
+	"limiter"
+	"testing"
+	"time"
+)
+
+// 测试ReserveN - 令牌不足时欠账，并返回需要等待的时间
+func TestReserveNDelay(t *testing.T) {
+	// 每秒生成1个令牌，容量为2
+	tokenBucket := limiter.New(limiter.Every(time.Second), 2)
+	now := time.Now()
+
+	// 满桶，立即执行
+	r1 := tokenBucket.ReserveN(now, 2)
+	if !r1.OK() || r1.DelayFrom(now) != 0 {
+		t.Fatalf("预期立即执行，实际 ok=%v delay=%v", r1.OK(), r1.DelayFrom(now))
+	}
+
+	// 桶已空，欠账1个令牌，需要等待1秒
+	r2 := tokenBucket.ReserveN(now, 1)
+	if !r2.OK() || r2.DelayFrom(now) != time.Second {
+		t.Fatalf("预期等待1s，实际 ok=%v delay=%v", r2.OK(), r2.DelayFrom(now))
+	}
+
+	// 再欠账2个令牌，排在r2之后，需要等待3秒
+	r3 := tokenBucket.ReserveN(now, 2)
+	if !r3.OK() || r3.DelayFrom(now) != 3*time.Second {
+		t.Fatalf("预期等待3s，实际 ok=%v delay=%v", r3.OK(), r3.DelayFrom(now))
+	}
+	if tokens := tokenBucket.Tokens(); tokens != -3 {
+		t.Errorf("预期令牌数为-3，实际: %v", tokens)
+	}
+}
+
+// 测试ReserveN - n超过桶容量时预定失败
+func TestReserveNExceedsCapacity(t *testing.T) {
+	tokenBucket := limiter.New(limiter.Every(time.Second), 2)
+	r := tokenBucket.ReserveN(time.Now(), 3)
+	if r.OK() {
+		t.Error("n超过桶容量时预期预定失败")
+	}
+	if r.Delay() != limiter.InfDuration {
+		t.Errorf("预定失败时预期Delay为InfDuration，实际: %v", r.Delay())
+	}
+}
+
+// 测试Cancel - 归还未使用的令牌
+func TestReservationCancel(t *testing.T) {
+	tokenBucket := limiter.New(limiter.Every(time.Second), 2)
+	now := time.Now()
+
+	tokenBucket.ReserveN(now, 2)
+	r := tokenBucket.ReserveN(now, 2)
+	if r.DelayFrom(now) != 2*time.Second {
+		t.Fatalf("预期等待2s，实际: %v", r.DelayFrom(now))
+	}
+
+	// 取消最后一个预定，令牌全部归还
+	r.CancelAt(now)
+	if tokens := tokenBucket.Tokens(); tokens != 0 {
+		t.Errorf("取消后预期令牌数为0，实际: %v", tokens)
+	}
+
+	// 重复取消不会重复归还
+	r.CancelAt(now)
+	if tokens := tokenBucket.Tokens(); tokens != 0 {
+		t.Errorf("重复取消后预期令牌数为0，实际: %v", tokens)
+	}
+
+	// 归还后，新的预定只需要等待1秒
+	r2 := tokenBucket.ReserveN(now, 1)
+	if r2.DelayFrom(now) != time.Second {
+		t.Errorf("预期等待1s，实际: %v", r2.DelayFrom(now))
+	}
+}
+
+// 测试Cancel - 之后还有其他预定时，只归还不影响后续预定的令牌
+func TestReservationCancelWithLaterReservation(t *testing.T) {
+	tokenBucket := limiter.New(limiter.Every(time.Second), 2)
+	now := time.Now()
+
+	tokenBucket.ReserveN(now, 2)
+	r1 := tokenBucket.ReserveN(now, 1) // 1s后执行
+	tokenBucket.ReserveN(now, 1)       // 2s后执行
+
+	// r1之后的预定已经占用了r1的令牌，无法归还
+	r1.CancelAt(now)
+	if tokens := tokenBucket.Tokens(); tokens != -2 {
+		t.Errorf("预期令牌数为-2，实际: %v", tokens)
+	}
+}
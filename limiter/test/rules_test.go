@@ -0,0 +1,224 @@
+package test
+
+import (
+	"context"
+	"limiter"
+	"limiter/rules"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testRules = `
+rules:
+  - name: login
+    match:
+      route: /api/login
+      method: POST
+    limit: 1
+  - name: tenant-acme
+    match:
+      route: /api/*
+      headers:
+        X-Tenant: acme
+    algorithm: sliding_window_log
+    limit: 2
+    period: 1m
+  - name: per-client
+    match:
+      route: /api/*
+    limit: 1
+    per_client: true
+`
+
+// 测试规则文件 - 解析和默认值
+func TestRulesParse(t *testing.T) {
+	cfg, err := rules.Parse([]byte(testRules))
+	if err != nil {
+		t.Fatalf("解析规则失败，err:%v", err)
+	}
+	login := cfg.Rules[0]
+	if login.Algorithm != rules.TokenBucket || login.Period != time.Second || login.Burst != 1 {
+		t.Errorf("预期填充默认值，实际: %+v", login)
+	}
+	if cfg.Rules[1].Period != time.Minute {
+		t.Errorf("预期周期为1分钟，实际: %v", cfg.Rules[1].Period)
+	}
+
+	invalid := []string{
+		"rules: [{limit: 1}]",
+		"rules: [{name: a, limit: 1}, {name: a, limit: 1}]",
+		"rules: [{name: a, limit: 0}]",
+		"rules: [{name: a, limit: 1, algorithm: unknown}]",
+		"rules: [{name: a, limit: 1, algorithm: gcra, per_client: true}]",
+		"rules: [{name: a, limit: 1.5, algorithm: sliding_window_log}]",
+	}
+	for _, data := range invalid {
+		if _, err := rules.Parse([]byte(data)); err == nil {
+			t.Errorf("预期规则校验失败: %s", data)
+		}
+	}
+}
+
+// 测试规则引擎 - 按顺序匹配第一个规则
+func TestRulesMatch(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	cfg, _ := rules.Parse([]byte(testRules))
+	engine, err := rules.New(cfg, limiter.WithClock(clock))
+	if err != nil {
+		t.Fatalf("生成规则引擎失败，err:%v", err)
+	}
+
+	cases := []struct {
+		req     rules.Request
+		rule    string
+		allowed bool
+	}{
+		{rules.Request{Route: "/api/login", Method: "post"}, "login", true},
+		{rules.Request{Route: "/api/login", Method: "POST"}, "login", false},
+		// gRPC metadata的key是小写
+		{rules.Request{Route: "/api/user", Header: map[string][]string{"x-tenant": {"acme"}}}, "tenant-acme", true},
+		{rules.Request{Route: "/api/user", Header: http.Header{"X-Tenant": {"acme"}}}, "tenant-acme", true},
+		{rules.Request{Route: "/api/user", Header: http.Header{"X-Tenant": {"acme"}}}, "tenant-acme", false},
+		{rules.Request{Route: "/api/user", ClientID: "c1"}, "per-client", true},
+		{rules.Request{Route: "/api/user", ClientID: "c1"}, "per-client", false},
+		{rules.Request{Route: "/api/user", ClientID: "c2"}, "per-client", true},
+		{rules.Request{Route: "/health"}, "", true},
+	}
+	for i, c := range cases {
+		d := engine.Allow(c.req)
+		if d.Rule != c.rule || d.Allowed != c.allowed {
+			t.Errorf("第%d个请求 预期 %s/%v，实际 %s/%v", i, c.rule, c.allowed, d.Rule, d.Allowed)
+		}
+	}
+}
+
+// 测试规则引擎 - 更新规则时保留未变化的规则的状态
+func TestRulesUpdatePreservesState(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	cfg, _ := rules.Parse([]byte(testRules))
+	engine, _ := rules.New(cfg, limiter.WithClock(clock))
+
+	login := rules.Request{Route: "/api/login", Method: "POST"}
+	client := rules.Request{Route: "/api/user", ClientID: "c1"}
+	engine.Allow(login)
+	engine.Allow(client)
+
+	// 只修改per-client规则的速率
+	cfg.Rules[2].Limit = 2
+	cfg.Rules[2].Burst = 2
+	if err := engine.Update(cfg); err != nil {
+		t.Fatalf("更新规则失败，err:%v", err)
+	}
+
+	if d := engine.Allow(login); d.Allowed {
+		t.Error("login规则未变化，预期保留令牌已用完的状态")
+	}
+	if d := engine.Allow(client); !d.Allowed {
+		t.Error("per-client规则已变化，预期使用新的限流器")
+	}
+
+	// 校验失败时继续使用原来的规则
+	cfg.Rules[0].Limit = 0
+	if err := engine.Update(cfg); err == nil {
+		t.Error("预期规则校验失败")
+	}
+	if limit := engine.Config().Rules[0].Limit; limit != 1 {
+		t.Errorf("预期继续使用原来的规则，limit: %v", limit)
+	}
+}
+
+// 测试规则引擎 - 监听规则文件，文件变化时重新加载
+func TestRulesWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(testRules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := rules.NewFromFile(path)
+	if err != nil {
+		t.Fatalf("读取规则文件失败，err:%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan error, 10)
+	if err := engine.WatchFile(ctx, path, func(err error) { reloaded <- err }); err != nil {
+		t.Fatalf("监听规则文件失败，err:%v", err)
+	}
+
+	// 先写临时文件再重命名，和ConfigMap的更新方式一致
+	tmp := path + ".tmp"
+	os.WriteFile(tmp, []byte("rules: [{name: all, limit: 100}]"), 0o644)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatalf("重新加载规则失败，err:%v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("超时未收到重新加载的通知")
+	}
+	if d := engine.Allow(rules.Request{Route: "/api/login", Method: "POST"}); d.Rule != "all" {
+		t.Errorf("预期匹配新的规则 all，实际: %s", d.Rule)
+	}
+}
+
+// 测试规则引擎 - 监听k8s ConfigMap挂载的规则文件
+// 规则文件是指向 ..data/rules.yaml 的符号链接，更新时把 ..data 重命名为指向新目录的符号链接
+func TestRulesWatchConfigMap(t *testing.T) {
+	dir := t.TempDir()
+	writeVersion := func(version, rules string) {
+		if err := os.Mkdir(filepath.Join(dir, version), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, version, "rules.yaml"), []byte(rules), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeVersion("..v1", testRules)
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "rules.yaml")
+	if err := os.Symlink(filepath.Join("..data", "rules.yaml"), path); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, err := rules.NewFromFile(path)
+	if err != nil {
+		t.Fatalf("读取规则文件失败，err:%v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan error, 10)
+	if err := engine.WatchFile(ctx, path, func(err error) { reloaded <- err }); err != nil {
+		t.Fatalf("监听规则文件失败，err:%v", err)
+	}
+
+	// 和kubelet更新ConfigMap的方式一致：写入新目录，..data_tmp指向新目录后重命名为..data，再删除旧目录
+	writeVersion("..v2", "rules: [{name: all, limit: 100}]")
+	if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(filepath.Join(dir, "..v1"))
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatalf("重新加载规则失败，err:%v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("超时未收到重新加载的通知")
+	}
+	if d := engine.Allow(rules.Request{Route: "/api/login", Method: "POST"}); d.Rule != "all" {
+		t.Errorf("预期匹配新的规则 all，实际: %s", d.Rule)
+	}
+}
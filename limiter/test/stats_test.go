@@ -0,0 +1,91 @@
+package test
+
+import (
+	"context"
+	"limiter"
+	"testing"
+	"time"
+)
+
+// 测试统计信息 - 令牌桶的Allow和Wait
+func TestTokenBucketStats(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	tokenBucket := limiter.New(limiter.Every(time.Second), 1, limiter.WithClock(clock))
+
+	tokenBucket.Allow()
+	tokenBucket.Allow()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- tokenBucket.Wait(context.Background())
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("Wait失败，err:%v", err)
+	}
+
+	want := limiter.Stats{Allowed: 2, Denied: 1, Waited: 1, WaitTime: time.Second}
+	if got := tokenBucket.Stats(); got != want {
+		t.Errorf("预期统计信息 %+v，实际: %+v", want, got)
+	}
+}
+
+// 测试统计信息 - Wait超时记为拒绝
+func TestWaitDeniedStats(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewSlidingWindowLog(1, time.Hour, limiter.WithClock(clock))
+	lim.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	lim.Wait(ctx)
+
+	want := limiter.Stats{Allowed: 1, Denied: 1}
+	if got := lim.Stats(); got != want {
+		t.Errorf("预期统计信息 %+v，实际: %+v", want, got)
+	}
+}
+
+// 测试统计信息 - 按key限流汇总所有key，包括已被清理的桶
+func TestKeyedLimiterStats(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim := limiter.NewKeyed(limiter.Every(time.Second), 1, limiter.WithIdleTTL(time.Second), limiter.WithClock(clock))
+
+	lim.Allow("a")
+	lim.Allow("a")
+	lim.Allow("b")
+	if got := lim.Get("a").Stats(); got.Allowed != 1 || got.Denied != 1 {
+		t.Errorf("key a 预期允许1次、拒绝1次，实际: %+v", got)
+	}
+
+	// 桶被清理后，汇总的统计信息不变
+	clock.Advance(time.Minute)
+	lim.Cleanup()
+	if n := lim.Len(); n != 0 {
+		t.Fatalf("预期所有桶被清理，实际key数: %d", n)
+	}
+	want := limiter.Stats{Allowed: 2, Denied: 1}
+	if got := lim.Stats(); got != want {
+		t.Errorf("预期统计信息 %+v，实际: %+v", want, got)
+	}
+}
+
+// 测试统计信息 - 多层级限流只记录拒绝请求的层级
+func TestHierarchicalStats(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	lim, users, _, all := newTiers(clock, 1, 10, 10)
+
+	lim.Allow("u1", "t1")
+	lim.Allow("u1", "t1")
+
+	if got := lim.Stats(); got.Allowed != 1 || got.Denied != 1 {
+		t.Errorf("预期允许1次、拒绝1次，实际: %+v", got)
+	}
+	if got := users.Stats(); got.Allowed != 1 || got.Denied != 1 {
+		t.Errorf("层级 user 预期允许1次、拒绝1次，实际: %+v", got)
+	}
+	if got := all.Stats(); got.Allowed != 1 || got.Denied != 0 {
+		t.Errorf("层级 global 预期允许1次、拒绝0次，实际: %+v", got)
+	}
+}
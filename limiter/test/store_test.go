@@ -0,0 +1,140 @@
+package test
+
+import (
+	"context"
+	"limiter"
+	"limiter/redisstore"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// 启动进程内的Redis，返回Redis存储，以及修改Redis时间的函数
+func newRedisStore(t *testing.T) (*redisstore.RedisStore, func(time.Time)) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return redisstore.New(client, "limiter:", time.Minute), mr.SetTime
+}
+
+// 测试分布式令牌桶 - 多个副本共享同一份配额
+func TestDistributedBucketSharedQuota(t *testing.T) {
+	redisStore, setRedisTime := newRedisStore(t)
+	stores := map[string]struct {
+		store   limiter.Store
+		setTime func(time.Time) // 使用自己时钟的存储，修改存储的时间
+	}{
+		"内存存储":    {limiter.NewMemoryStore(), func(time.Time) {}},
+		"Redis存储": {redisStore, setRedisTime},
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			// 两个副本，每秒生成1个令牌，容量为3
+			replicaA := limiter.NewDistributed(s.store, "user", limiter.Every(time.Second), 3)
+			replicaB := limiter.NewDistributed(s.store, "user", limiter.Every(time.Second), 3)
+			now := time.Now()
+			s.setTime(now)
+
+			want := []bool{true, true, true, false}
+			for i, w := range want {
+				// 两个副本交替申请
+				replica := replicaA
+				if i%2 == 1 {
+					replica = replicaB
+				}
+				if got := replica.AllowN(now, 1); got != w {
+					t.Errorf("第%d次请求 预期 %v，实际 %v", i, w, got)
+				}
+			}
+
+			// 1秒后生成了1个令牌，任意一个副本都可以获取
+			s.setTime(now.Add(time.Second))
+			if !replicaB.AllowN(now.Add(time.Second), 1) {
+				t.Error("预期1秒后允许执行")
+			}
+			if replicaA.AllowN(now.Add(time.Second), 1) {
+				t.Error("预期令牌已被另一个副本获取")
+			}
+		})
+	}
+}
+
+// 测试分布式令牌桶 - Redis存储按Redis的时间生成令牌，副本的时钟偏差不影响配额
+func TestDistributedBucketClockSkew(t *testing.T) {
+	store, setRedisTime := newRedisStore(t)
+	now := time.Now()
+	setRedisTime(now)
+
+	// 副本B的时钟比副本A快1小时
+	replicaA := limiter.NewDistributed(store, "skew", limiter.Every(time.Minute), 1)
+	replicaB := limiter.NewDistributed(store, "skew", limiter.Every(time.Minute), 1)
+	if !replicaA.AllowN(now, 1) {
+		t.Fatal("满桶时预期允许执行")
+	}
+	if replicaB.AllowN(now.Add(time.Hour), 1) {
+		t.Error("Redis的时间没有变化，预期不会因为副本的时钟偏差生成令牌")
+	}
+
+	setRedisTime(now.Add(time.Minute))
+	if !replicaA.AllowN(now, 1) {
+		t.Error("Redis的时间过去1分钟后预期允许执行")
+	}
+}
+
+// 测试分布式令牌桶 - 并发申请时不会超发
+func TestDistributedBucketConcurrent(t *testing.T) {
+	store, _ := newRedisStore(t)
+	now := time.Now()
+
+	var allowed, failed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		replica := limiter.NewDistributed(store, "concurrent", limiter.Every(time.Hour), 20)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, ok, err := replica.TakeN(context.Background(), now, 1)
+				if err != nil {
+					atomic.AddInt32(&failed, 1)
+				} else if ok {
+					atomic.AddInt32(&allowed, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 20 {
+		t.Errorf("预期放行20个请求，实际: %d", allowed)
+	}
+	// 一次往返原子地完成，竞争激烈时也不会出错
+	if failed != 0 {
+		t.Errorf("预期没有请求出错，实际: %d", failed)
+	}
+}
+
+// 测试分布式令牌桶 - 返回需要等待的时间
+func TestDistributedBucketTakeN(t *testing.T) {
+	store, setRedisTime := newRedisStore(t)
+	lim := limiter.NewDistributed(store, "wait", limiter.Every(time.Second), 2)
+	now := time.Now()
+	setRedisTime(now)
+
+	if _, ok, err := lim.TakeN(context.Background(), now, 2); err != nil || !ok {
+		t.Fatalf("满桶时预期允许执行，ok=%v err=%v", ok, err)
+	}
+	setRedisTime(now.Add(500 * time.Millisecond))
+	wait, ok, err := lim.TakeN(context.Background(), now.Add(500*time.Millisecond), 1)
+	if err != nil || ok {
+		t.Fatalf("预期令牌不足，ok=%v err=%v", ok, err)
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("预期等待500ms，实际: %v", wait)
+	}
+}
@@ -0,0 +1,102 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"limiter"
+	"testing"
+	"time"
+)
+
+// 测试Wait - 令牌不足时阻塞等待
+func TestWaitBlocksUntilTokens(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	// 每10毫秒生成一个令牌，容量为1
+	tokenBucket := limiter.New(limiter.Every(10*time.Millisecond), 1, limiter.WithClock(clock))
+
+	// 第一个令牌是满桶时的令牌，不需要等待
+	if err := tokenBucket.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait失败，err:%v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- tokenBucket.Wait(context.Background())
+	}()
+
+	// 等待goroutine进入等待状态
+	clock.BlockUntil(1)
+	clock.Advance(9 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("令牌还未生成，预期继续等待")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	if err := <-done; err != nil {
+		t.Errorf("令牌生成后预期获取成功，err:%v", err)
+	}
+}
+
+// 测试WaitN - n超过桶容量
+func TestWaitNExceedsCapacity(t *testing.T) {
+	tokenBucket := limiter.New(limiter.Every(time.Second), 2)
+	if err := tokenBucket.WaitN(context.Background(), 3); err == nil {
+		t.Error("n超过桶容量时预期返回错误")
+	}
+}
+
+// 测试Wait - ctx取消时归还预定的令牌
+func TestWaitContextCanceled(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	tokenBucket := limiter.New(limiter.Every(time.Second), 1, limiter.WithClock(clock))
+	tokenBucket.Allow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- tokenBucket.Wait(ctx)
+	}()
+	clock.BlockUntil(1)
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("预期错误 %v，实际得到 %v", context.Canceled, err)
+	}
+	// 预定的令牌已归还，1秒后可以获取到令牌
+	clock.Advance(time.Second)
+	if !tokenBucket.Allow() {
+		t.Error("取消后预期归还预定的令牌")
+	}
+}
+
+// 测试Wait - 截止时间前无法获取令牌时立即返回
+func TestWaitDeadlineCannotBeMet(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	tokenBucket := limiter.New(limiter.Every(time.Hour), 1, limiter.WithClock(clock))
+	tokenBucket.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := tokenBucket.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("预期错误 %v，实际得到 %v", context.DeadlineExceeded, err)
+	}
+	if timers := clock.Timers(); timers != 0 {
+		t.Errorf("预期不等待，实际创建了%d个定时器", timers)
+	}
+}
+
+// 测试Wait - 速率为0且令牌不足时，没有截止时间也直接返回错误，而不是DeadlineExceeded
+func TestWaitZeroLimit(t *testing.T) {
+	tokenBucket := limiter.New(0, 1)
+	if err := tokenBucket.Wait(context.Background()); err != nil {
+		t.Fatalf("桶中的令牌预期可以使用，err:%v", err)
+	}
+
+	err := tokenBucket.Wait(context.Background())
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("速率为0时预期返回限流器的错误，实际: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package test
+
+import (
+	"context"
+	"limiter"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// 同一组请求的时间点（相对于开始时间的毫秒数）
+var windowTrace = []int{0, 100, 200, 300, 900, 1050, 1150, 1250, 1300, 2100, 2200, 2300, 2400}
+
+// 按请求时间点依次调用AllowN，返回每个请求是否被允许
+func runTrace(lim limiter.Limiter, start time.Time) []bool {
+	results := make([]bool, 0, len(windowTrace))
+	for _, ms := range windowTrace {
+		results = append(results, lim.AllowN(start.Add(time.Duration(ms)*time.Millisecond), 1))
+	}
+	return results
+}
+
+// 测试不同的限流算法在同一组请求上的表现：每秒最多3个请求
+func TestLimitersOnSameTrace(t *testing.T) {
+	const T, F = true, false
+	cases := []struct {
+		name string
+		lim  limiter.Limiter
+		want []bool
+	}{
+		{
+			// 任意1秒内最多3个请求，最精确
+			name: "滑动窗口日志",
+			lim:  limiter.NewSlidingWindowLog(3, time.Second),
+			want: []bool{T, T, T, F, F, T, T, T, F, T, T, T, F},
+		},
+		{
+			// 上一个窗口的请求按比例计入，突发之后的下一个窗口更严格
+			name: "滑动窗口计数器",
+			lim:  limiter.NewSlidingWindowCounter(3, time.Second),
+			want: []bool{T, T, T, F, F, F, F, F, F, T, T, T, F},
+		},
+		{
+			// 令牌持续生成，900ms时已经生成了足够的令牌，第1秒内放行了4个请求
+			name: "令牌桶",
+			lim:  limiter.New(3, 3),
+			want: []bool{T, T, T, F, T, T, T, F, F, T, T, T, F},
+		},
+	}
+
+	start := time.Now()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := runTrace(c.lim, start)
+			for i := range c.want {
+				if got[i] != c.want[i] {
+					t.Errorf("第%d个请求(%dms) 预期 %v，实际 %v", i, windowTrace[i], c.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+// 测试滑动窗口日志 - 任意窗口内都不超过限制
+func TestSlidingWindowLogRolling(t *testing.T) {
+	lim := limiter.NewSlidingWindowLog(2, time.Second)
+	start := time.Now()
+
+	if !lim.AllowN(start, 2) {
+		t.Fatal("预期允许执行2个事件")
+	}
+	// 999ms时第一批事件仍在窗口内
+	if lim.AllowN(start.Add(999*time.Millisecond), 1) {
+		t.Error("预期窗口内的事件数已达上限")
+	}
+	// 1000ms时第一批事件滑出窗口
+	if !lim.AllowN(start.Add(time.Second), 2) {
+		t.Error("预期第一批事件滑出窗口后允许执行")
+	}
+	if lim.AllowN(start.Add(time.Second), 3) {
+		t.Error("n超过限制时预期拒绝")
+	}
+}
+
+// 推进时钟直到done返回，每次有goroutine在等待时前进step，返回经过的时间
+func advanceUntilDone(clock *limiter.ManualClock, step time.Duration, done <-chan error) (time.Duration, error) {
+	start := clock.Now()
+	for {
+		select {
+		case err := <-done:
+			return clock.Now().Sub(start), err
+		default:
+		}
+		if clock.Timers() > 0 {
+			clock.Advance(step)
+			continue
+		}
+		runtime.Gosched()
+	}
+}
+
+// 测试滑动窗口的Wait
+func TestSlidingWindowWait(t *testing.T) {
+	newLimiters := map[string]func(clock limiter.Clock) limiter.Limiter{
+		"滑动窗口日志": func(clock limiter.Clock) limiter.Limiter {
+			return limiter.NewSlidingWindowLog(1, 20*time.Millisecond, limiter.WithClock(clock))
+		},
+		"滑动窗口计数器": func(clock limiter.Clock) limiter.Limiter {
+			return limiter.NewSlidingWindowCounter(1, 20*time.Millisecond, limiter.WithClock(clock))
+		},
+	}
+	for name, newLimiter := range newLimiters {
+		t.Run(name, func(t *testing.T) {
+			clock := limiter.NewManualClock(time.Now())
+			lim := newLimiter(clock)
+
+			done := make(chan error, 1)
+			go func() {
+				for i := 0; i < 2; i++ {
+					if err := lim.Wait(context.Background()); err != nil {
+						done <- err
+						return
+					}
+				}
+				done <- nil
+			}()
+
+			elapsed, err := advanceUntilDone(clock, time.Millisecond, done)
+			if err != nil {
+				t.Fatalf("Wait失败，err:%v", err)
+			}
+			if elapsed < 20*time.Millisecond {
+				t.Errorf("预期至少等待20ms，实际等待: %v", elapsed)
+			}
+		})
+	}
+}
+
+// 测试滑动窗口 - 窗口大小不大于0时，创建时直接panic，而不是在第一次Allow时除以0
+func TestSlidingWindowInvalidWindow(t *testing.T) {
+	constructors := map[string]func(){
+		"counter": func() { limiter.NewSlidingWindowCounter(10, 0) },
+		"log":     func() { limiter.NewSlidingWindowLog(10, -time.Second) },
+	}
+	for name, newLimiter := range constructors {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s 窗口大小不大于0时预期panic", name)
+				}
+			}()
+			newLimiter()
+		}()
+	}
+}
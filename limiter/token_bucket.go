@@ -1,8 +1,11 @@
 package limiter
 
 import (
+	"context"
+	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,38 +24,242 @@ func Every(interval time.Duration) Limit {
 	return 1 / Limit(interval.Seconds())
 }
 
+// 配置中的限流规则转换为令牌桶的参数，limit为每秒生成的令牌数
+// limit不大于0时返回false，表示不限制；burst不大于0时，容量等于每秒生成的令牌数向上取整，至少为1
+func BucketParams(limit float64, burst int) (Limit, int, bool) {
+	if limit <= 0 {
+		return 0, 0, false
+	}
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(limit)))
+	}
+	return Limit(limit), burst, true
+}
+
+// 令牌桶的编号
+var bucketSeq atomic.Uint64
+
 // 令牌桶
 type TokenBucket struct {
-	mu       sync.Mutex // 所有修改 tokens、last、的操作均在 mu锁保护下进行
-	capacity int        // 桶的容量
-	limit    Limit      // 令牌生成速率： n个/s, 可能会有小数，如2秒生成一个：即0.5个/s，
-	tokens   float64    // 当前桶中令牌的数量
-	last     time.Time  // 上次生成令牌的时间
+	mu        sync.Mutex // 所有修改 tokens、last、lastEvent的操作均在 mu锁保护下进行
+	id        uint64     // 编号，同时锁多个桶时按编号从小到大加锁，避免死锁
+	capacity  int        // 桶的容量
+	limit     Limit      // 令牌生成速率： n个/s, 可能会有小数，如2秒生成一个：即0.5个/s，
+	tokens    float64    // 当前桶中令牌的数量，有预定时可能为负数（欠账）
+	last      time.Time  // 上次生成令牌的时间
+	lastEvent time.Time  // 最近一次预定的执行时间（过去或未来）
+	clock     Clock      // 时钟
+	stats     counters   // 统计信息
 }
 
 // 生成令牌桶
-func New(limit Limit, capacity int) *TokenBucket {
+// 可选配置：WithClock
+func New(limit Limit, capacity int, opts ...Option) *TokenBucket {
+	o := newOptions(opts...)
 	b := &TokenBucket{
+		id:       bucketSeq.Add(1),
 		capacity: capacity,
 		limit:    limit,
 		tokens:   float64(capacity),
+		clock:    o.clock,
 	}
 	return b
 }
 
+// 当前的令牌生成速率
+func (lim *TokenBucket) Limit() Limit {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	return lim.limit
+}
+
+// 当前桶的容量
+func (lim *TokenBucket) Burst() int {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	return lim.capacity
+}
+
+// 修改令牌生成速率
+func (lim *TokenBucket) SetLimit(newLimit Limit) {
+	lim.SetLimitAt(lim.clock.Now(), newLimit)
+}
+
+// 在时间t修改令牌生成速率
+// 先按旧的速率生成t之前的令牌，再使用新的速率，桶中已有的令牌不会丢失
+func (lim *TokenBucket) SetLimitAt(t time.Time, newLimit Limit) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	t, tokens := lim.advance(t)
+
+	lim.last = t
+	lim.tokens = tokens
+	lim.limit = newLimit
+}
+
+// 修改桶的容量
+func (lim *TokenBucket) SetBurst(newBurst int) {
+	lim.SetBurstAt(lim.clock.Now(), newBurst)
+}
+
+// 在时间t修改桶的容量
+// 先按旧的容量生成t之前的令牌，再使用新的容量；容量变小时，多出的令牌会被丢弃
+func (lim *TokenBucket) SetBurstAt(t time.Time, newBurst int) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	t, tokens := lim.advance(t)
+	if tokens > float64(newBurst) {
+		tokens = float64(newBurst)
+	}
+
+	lim.last = t
+	lim.tokens = tokens
+	lim.capacity = newBurst
+}
+
 // 是否允许执行事件
 func (lim *TokenBucket) Allow() bool {
-	return lim.AllowN(time.Now(), 1)
+	return lim.AllowN(lim.clock.Now(), 1)
 }
 
 // 是否允许在时间t执行n个事件
 func (lim *TokenBucket) AllowN(t time.Time, n int) bool {
-	return lim.reserveN(t, n)
+	ok := lim.reserveN(t, n, 0).ok
+	lim.stats.record(ok)
+	return ok
+}
+
+// 在时间t向桶中放入n个令牌，桶中的令牌数不超过容量
+// 用于按事件而不是只按时间生成令牌的场景，如重试预算：每个请求放入令牌，每次重试消耗令牌
+func (lim *TokenBucket) PutN(t time.Time, n int) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	t, tokens := lim.advance(t)
+	tokens = math.Min(tokens+float64(n), float64(lim.capacity))
+
+	lim.last = t
+	lim.tokens = tokens
+}
+
+// 预定1个令牌
+func (lim *TokenBucket) Reserve() *Reservation {
+	return lim.ReserveN(lim.clock.Now(), 1)
+}
+
+// 预定在时间t执行n个事件，令牌不足时允许欠账，调用方需要等待Reservation.Delay()之后再执行
+// n超过桶的容量时，预定失败，Reservation.OK()返回false
+func (lim *TokenBucket) ReserveN(t time.Time, n int) *Reservation {
+	return lim.reserveN(t, n, InfDuration)
+}
+
+// 在时间t获取最多n个令牌，返回获取到的令牌数：桶中的令牌不足n个时，获取桶中所有完整的令牌
+// 一个令牌都没有时返回0，以及多久之后可以获取到1个令牌；不允许欠账，没有获取到令牌时不修改桶的状态
+func (lim *TokenBucket) TakeUpTo(t time.Time, n int) (int, time.Duration) {
+	granted, wait := lim.takeUpTo(t, n)
+	lim.stats.record(granted > 0)
+	return granted, wait
+}
+
+func (lim *TokenBucket) takeUpTo(t time.Time, n int) (int, time.Duration) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	// 不限速直接返回
+	if lim.limit == Inf || n <= 0 {
+		return max(n, 0), 0
+	}
+
+	t, tokens := lim.advance(t)
+	granted := min(n, int(math.Floor(tokens)))
+	if granted <= 0 {
+		return 0, lim.limit.durationFromTokens(1 - tokens)
+	}
+
+	lim.tokens = tokens - float64(granted)
+	lim.last = t
+	lim.lastEvent = t
+	return granted, 0
+}
+
+// 阻塞等待，直到获取到1个令牌
+func (lim *TokenBucket) Wait(ctx context.Context) error {
+	return lim.WaitN(ctx, 1)
+}
+
+// 阻塞等待，直到获取到n个令牌
+// ctx被取消或者在ctx的截止时间之前无法获取到足够的令牌时，直接返回错误
+func (lim *TokenBucket) WaitN(ctx context.Context, n int) error {
+	err := lim.waitN(ctx, n)
+	lim.stats.record(err == nil)
+	return err
+}
+
+// 统计信息，Allow、Wait的调用结果，ReserveN不计入
+func (lim *TokenBucket) Stats() Stats {
+	return lim.stats.snapshot()
+}
+
+// 阻塞等待，直到获取到n个令牌
+func (lim *TokenBucket) waitN(ctx context.Context, n int) error {
+	lim.mu.Lock()
+	capacity := lim.capacity
+	limit := lim.limit
+	lim.mu.Unlock()
+
+	// 申请的令牌数超过桶的容量，永远无法满足
+	if n > capacity && limit != Inf {
+		return fmt.Errorf("limiter: WaitN(n=%d) 超过了桶的容量 %d", n, capacity)
+	}
+
+	// 先检查ctx是否已取消
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 根据ctx的截止时间计算最多可以等待多久
+	now := lim.clock.Now()
+	waitLimit := InfDuration
+	if deadline, ok := ctx.Deadline(); ok {
+		waitLimit = deadline.Sub(now)
+	}
+
+	// 预定令牌，截止时间之前无法生成足够的令牌时，不再等待
+	r := lim.reserveN(now, n, waitLimit)
+	if !r.ok {
+		if waitLimit == InfDuration {
+			// 没有截止时间仍然无法预定，如速率为0，永远无法生成足够的令牌
+			return fmt.Errorf("limiter: WaitN(n=%d) 永远无法获取到足够的令牌，速率 %v", n, limit)
+		}
+		return context.DeadlineExceeded
+	}
+
+	delay := r.DelayFrom(now)
+	if delay == 0 {
+		return nil
+	}
+	timer := lim.clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		// 到达预定的执行时间
+		lim.stats.recordWait(lim.clock.Now().Sub(now))
+		return nil
+	case <-ctx.Done():
+		// 已取消，归还预定的令牌
+		cancelAt := lim.clock.Now()
+		r.CancelAt(cancelAt)
+		lim.stats.recordWait(cancelAt.Sub(now))
+		return ctx.Err()
+	}
 }
 
 // 核心代码
 // 预定N个令牌
-func (lim *TokenBucket) reserveN(t time.Time, n int) bool {
+// maxFutureReserve：最多允许等待的时间，为0时不允许欠账
+func (lim *TokenBucket) reserveN(t time.Time, n int, maxFutureReserve time.Duration) *Reservation {
 	lim.mu.Lock()
 	defer lim.mu.Unlock()
 
@@ -60,31 +267,53 @@ func (lim *TokenBucket) reserveN(t time.Time, n int) bool {
 	if lim.limit == Inf {
 		lim.last = t
 		lim.tokens = float64(n)
-		return true
+		return &Reservation{
+			ok:        true,
+			lim:       lim,
+			tokens:    n,
+			timeToAct: t,
+		}
 	}
 
 	// 预生成令牌
-	tokens := lim.advance(t)
+	t, tokens := lim.advance(t)
 	tokens = tokens - float64(n)
+
+	// 令牌不足时，计算需要等待的时间
+	var waitDuration time.Duration
 	if tokens < 0 {
-		return false
+		waitDuration = lim.limit.durationFromTokens(-tokens)
+	}
+
+	ok := n <= lim.capacity && waitDuration != InfDuration && waitDuration <= maxFutureReserve
+	r := &Reservation{
+		ok:    ok,
+		lim:   lim,
+		limit: lim.limit,
+	}
+	if !ok {
+		return r
 	}
 
+	r.tokens = n
+	r.timeToAct = t.Add(waitDuration)
+
 	// 更新令牌数
 	lim.tokens = tokens
 	lim.last = t
+	lim.lastEvent = r.timeToAct
 
-	return true
+	return r
 }
 
 // 生成的token数量
-func (lim *TokenBucket) advance(t time.Time) (newTokens float64) {
+// 返回值newT：t早于上次生成令牌的时间时，按上次的时间计算
+func (lim *TokenBucket) advance(t time.Time) (newT time.Time, newTokens float64) {
 	// 第一次启动时，填满桶
 	if lim.last.IsZero() {
 		lim.last = t
 		lim.tokens = float64(lim.capacity)
-		newTokens = lim.tokens
-		return
+		return t, lim.tokens
 	}
 	lastTime := lim.last
 	if t.Before(lastTime) {
@@ -96,7 +325,48 @@ func (lim *TokenBucket) advance(t time.Time) (newTokens float64) {
 	if newTokens > float64(lim.capacity) {
 		newTokens = float64(lim.capacity)
 	}
-	return
+	return t, newTokens
+}
+
+// 生成指定数量的令牌需要的时间
+func (limit Limit) durationFromTokens(tokens float64) time.Duration {
+	if limit <= 0 {
+		return InfDuration
+	}
+	seconds := tokens / float64(limit)
+	// 向上取整到纳秒，避免醒来时令牌仍差一点点
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
+
+// 指定时间内生成的令牌数
+func (limit Limit) tokensFromDuration(d time.Duration) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return d.Seconds() * float64(limit)
+}
+
+// 尝试在时间t获取n个令牌，不欠账
+// 令牌不足时不修改桶，返回还需要等待的时间
+func (lim *TokenBucket) tryN(t time.Time, n int) (time.Duration, bool) {
+	r := lim.reserveN(t, n, 0)
+	if r.ok {
+		return 0, true
+	}
+
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	_, tokens := lim.advance(t)
+	return lim.limit.durationFromTokens(float64(n) - tokens), false
+}
+
+// 时间t时桶中的令牌数
+func (lim *TokenBucket) tokensAt(t time.Time) float64 {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	_, tokens := lim.advance(t)
+	return tokens
 }
 
 func (lim *TokenBucket) Tokens() float64 {
@@ -0,0 +1,40 @@
+package retry
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// 默认的退避策略：100ms开始，每次翻倍，最长10秒，随机减少最多20%
+var DefaultBackoff = Backoff{
+	Initial:    100 * time.Millisecond,
+	Max:        10 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// 指数退避
+// 第n次重试前等待 Initial * Multiplier^(n-1)，不超过Max；
+// Jitter在此基础上随机减少一部分，避免大量客户端在同一时刻重试，再次把下游打垮
+type Backoff struct {
+	Initial    time.Duration // 第一次重试前的等待时间
+	Max        time.Duration // 最长等待时间，0表示不限制
+	Multiplier float64       // 每次重试等待时间的倍数，小于1时按1计算
+	Jitter     float64       // 随机减少的比例，取值 [0, 1]；1表示在 [0, 等待时间) 中随机（full jitter）
+}
+
+// 第retry次重试（从1开始）前的等待时间
+func (b Backoff) Delay(retry int) time.Duration {
+	if retry < 1 {
+		retry = 1
+	}
+	delay := float64(b.Initial) * math.Pow(math.Max(b.Multiplier, 1), float64(retry-1))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	if jitter := math.Min(math.Max(b.Jitter, 0), 1); jitter > 0 {
+		delay -= delay * jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,59 @@
+package retry
+
+import (
+	"limiter"
+	"math"
+)
+
+// 重试预算的配置函数
+type BudgetOption func(b *Budget)
+
+// 设置重试预算的时钟，测试时可以使用limiter.ManualClock
+func WithBudgetClock(c limiter.Clock) BudgetOption {
+	return func(b *Budget) {
+		if c != nil {
+			b.clock = c
+		}
+	}
+}
+
+// 重试预算
+// 下游故障时每个请求都重试N次，下游的压力会放大N倍。重试预算限制重试的次数不超过请求数的一定比例：
+// 每个请求向令牌桶中放入1个令牌，每次重试消耗 1/ratio 个令牌，令牌不足时不再重试；
+// 令牌桶同时按 minPerSecond 的速率生成令牌，请求很少时也能重试
+type Budget struct {
+	bucket *limiter.TokenBucket
+	cost   int // 每次重试消耗的令牌数
+	clock  limiter.Clock
+}
+
+// NewBudget 创建重试预算
+// ratio：重试次数占请求数的比例，如0.1表示重试不超过请求数的10%
+// minPerSecond：不论请求数多少，每秒至少允许的重试次数
+// maxRetries：最多积攒的重试次数，限制下游恢复前的一波突发重试；初始时积攒满
+func NewBudget(ratio, minPerSecond float64, maxRetries int, opts ...BudgetOption) *Budget {
+	b := &Budget{cost: 1, clock: limiter.RealClock{}}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if ratio > 0 && ratio < 1 {
+		b.cost = int(math.Round(1 / ratio))
+	}
+	b.bucket = limiter.New(limiter.Limit(minPerSecond*float64(b.cost)), max(maxRetries, 1)*b.cost, limiter.WithClock(b.clock))
+	return b
+}
+
+// 记录一个请求，重试不调用
+func (b *Budget) Request() {
+	b.bucket.PutN(b.clock.Now(), 1)
+}
+
+// 是否允许重试，允许时消耗预算
+func (b *Budget) Retry() bool {
+	return b.bucket.AllowN(b.clock.Now(), b.cost)
+}
+
+// 统计信息，Allowed、Denied为允许、拒绝重试的次数
+func (b *Budget) Stats() limiter.Stats {
+	return b.bucket.Stats()
+}
@@ -0,0 +1,18 @@
+module retry
+
+go 1.25.0
+
+require (
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142
+	google.golang.org/grpc v1.67.3
+	google.golang.org/protobuf v1.36.11
+	limiter v0.0.0-00010101000000-000000000000
+)
+
+require (
+	golang.org/x/net v0.28.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+)
+
+replace limiter => ../limiter
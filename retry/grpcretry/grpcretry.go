@@ -0,0 +1,77 @@
+package grpcretry
+
+import (
+	"context"
+	"errors"
+	"retry"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 错误是否可以重试
+// Unavailable（连接失败、服务端过载保护）、ResourceExhausted（被限流）、Aborted（并发冲突）可以重试；
+// DeadlineExceeded不重试，整个调用的超时时间已经用完；其他错误说明请求本身有问题，重试也不会成功
+func Retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// 带重试的unary客户端拦截器
+// r应该使用WithRetryable(Retryable)创建，同一个连接上的所有方法共享重试预算。
+// 错误携带errdetails.RetryInfo时（如服务端限流），RetryDelay作为最短的等待时间
+func UnaryClientInterceptor(r *retry.Retrier) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := r.Do(ctx, func(ctx context.Context) error {
+			return withRetryInfo(invoker(ctx, method, req, reply, cc, opts...))
+		})
+		// 返回服务端原始的错误
+		var re *retryInfoError
+		if errors.As(err, &re) {
+			return re.err
+		}
+		return err
+	}
+}
+
+// 携带RetryInfo的gRPC错误，实现retry的RetryAfter接口
+type retryInfoError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryInfoError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryInfoError) Unwrap() error {
+	return e.err
+}
+
+func (e *retryInfoError) GRPCStatus() *status.Status {
+	return status.Convert(e.err)
+}
+
+func (e *retryInfoError) RetryAfter() time.Duration {
+	return e.delay
+}
+
+// 错误携带RetryInfo时，包装为retryInfoError
+func withRetryInfo(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return &retryInfoError{err: err, delay: info.GetRetryDelay().AsDuration()}
+		}
+	}
+	return err
+}
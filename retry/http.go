@@ -0,0 +1,139 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 读取响应体的上限，重试前读完响应体才能复用连接，超过上限时直接关闭
+const maxDrainBytes = 64 << 10
+
+// 状态码是否可以重试
+// 408、429、502、503、504 说明请求没有被处理或者下游暂时不可用；500可能已经执行了一部分，不重试
+func HTTPStatusRetryable(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// 请求是否可以重试
+// 幂等的方法，或者携带了Idempotency-Key请求头；有请求体时需要能够重新读取（GetBody）
+func HTTPRequestRetryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// 可以重试的状态码
+type statusError struct {
+	code       int
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("retry: 响应状态码 %d", e.code)
+}
+
+func (e *statusError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// Transport 带重试的http.RoundTripper
+// 只重试可以重试的请求（见HTTPRequestRetryable）；网络错误和可以重试的状态码（见HTTPStatusRetryable）会重试，
+// 响应中的Retry-After（秒）会作为最短的等待时间。重试都失败时，返回最后一次的响应或错误
+type Transport struct {
+	Retrier *Retrier
+	Base    http.RoundTripper // 实际发送请求的RoundTripper，为nil时使用http.DefaultTransport
+}
+
+// NewTransport 创建带重试的http.RoundTripper
+// Retrier的WithRetryable对Transport不生效，按状态码和网络错误判断是否重试
+func NewTransport(r *Retrier, base http.RoundTripper) *Transport {
+	return &Transport{Retrier: r, Base: base}
+}
+
+// RoundTrip 实现http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !HTTPRequestRetryable(req) {
+		return base.RoundTrip(req)
+	}
+
+	// 只按网络错误和状态码判断，ctx被取消时不重试
+	r := *t.Retrier
+	r.retryable = func(err error) bool {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	var resp *http.Response
+	first := true
+	err := r.Do(req.Context(), func(ctx context.Context) error {
+		if resp != nil {
+			// 上一次可以重试的响应不再需要，读完响应体后关闭，连接可以复用
+			io.CopyN(io.Discard, resp.Body, maxDrainBytes)
+			resp.Body.Close()
+			resp = nil
+		}
+
+		attempt := req
+		if !first && req.Body != nil && req.Body != http.NoBody {
+			// 第一次的请求体已经被读取，RoundTripper不能修改原来的请求，重试时使用新的请求体
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			attempt = req.Clone(ctx)
+			attempt.Body = body
+		}
+
+		first = false
+		res, err := base.RoundTrip(attempt)
+		if err != nil {
+			return err
+		}
+		resp = res
+		if HTTPStatusRetryable(res.StatusCode) {
+			return &statusError{code: res.StatusCode, retryAfter: parseRetryAfter(res.Header.Get("Retry-After"))}
+		}
+		return nil
+	})
+
+	var se *statusError
+	if err == nil || errors.As(err, &se) {
+		// 最后一次可以重试的响应原样返回给调用方
+		return resp, nil
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return nil, err
+}
+
+// 解析Retry-After响应头，只支持秒数
+func parseRetryAfter(v string) time.Duration {
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,158 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"limiter"
+	"time"
+)
+
+// 默认配置
+const (
+	defaultMaxAttempts = 3
+)
+
+// 配置函数
+type Option func(r *Retrier)
+
+// 设置最多尝试的次数，包括第一次调用，默认为3；1表示不重试
+func WithMaxAttempts(n int) Option {
+	return func(r *Retrier) {
+		if n > 0 {
+			r.maxAttempts = n
+		}
+	}
+}
+
+// 设置退避策略，默认为DefaultBackoff
+func WithBackoff(b Backoff) Option {
+	return func(r *Retrier) {
+		r.backoff = b
+	}
+}
+
+// 设置服务端要求的重试等待时间（如HTTP的Retry-After）的上限，默认为退避策略的Max
+// 服务端要求等待的时间超过上限时不再重试，直接返回错误；小于等于0时忽略
+func WithMaxRetryAfter(d time.Duration) Option {
+	return func(r *Retrier) {
+		if d > 0 {
+			r.maxRetryAfter = d
+		}
+	}
+}
+
+// 设置重试预算，默认不限制重试的比例
+func WithBudget(b *Budget) Option {
+	return func(r *Retrier) {
+		r.budget = b
+	}
+}
+
+// 设置判断错误是否可以重试的函数，默认所有错误都重试
+// gRPC使用grpcretry.Retryable，HTTP使用Transport时按状态码判断
+func WithRetryable(f func(err error) bool) Option {
+	return func(r *Retrier) {
+		if f != nil {
+			r.retryable = f
+		}
+	}
+}
+
+// 设置时钟，测试时可以使用limiter.ManualClock
+func WithClock(c limiter.Clock) Option {
+	return func(r *Retrier) {
+		if c != nil {
+			r.clock = c
+		}
+	}
+}
+
+// 服务端要求的重试等待时间，如HTTP的Retry-After响应头
+// 错误实现了该接口时，等待时间取退避时间和RetryAfter中较大的一个；RetryAfter超过上限时不再重试
+type retryAfter interface {
+	RetryAfter() time.Duration
+}
+
+// Retrier 重试器
+// 按退避策略等待后重试可以重试的错误，重试预算不足时不再重试。可以被多个协程并发使用，
+// 同一个下游的调用应该共用一个Retrier，共享重试预算
+type Retrier struct {
+	maxAttempts   int
+	backoff       Backoff
+	maxRetryAfter time.Duration // 服务端要求的等待时间的上限，0表示使用退避策略的Max
+	budget        *Budget
+	retryable     func(err error) bool
+	clock         limiter.Clock
+}
+
+// New 创建重试器
+// 可选配置：WithMaxAttempts、WithBackoff、WithMaxRetryAfter、WithBudget、WithRetryable、WithClock
+func New(opts ...Option) *Retrier {
+	r := &Retrier{
+		maxAttempts: defaultMaxAttempts,
+		backoff:     DefaultBackoff,
+		retryable:   func(err error) bool { return true },
+		clock:       limiter.RealClock{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Do 调用f，失败时按配置重试，返回最后一次调用的错误
+// 错误不可以重试、达到最多尝试次数、服务端要求等待的时间超过上限、重试预算不足时，返回最后一次调用的错误；
+// 等待重试时ctx被取消，返回ctx.Err()
+func (r *Retrier) Do(ctx context.Context, f func(ctx context.Context) error) error {
+	if r.budget != nil {
+		r.budget.Request()
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = f(ctx)
+		if err == nil || attempt >= r.maxAttempts || !r.retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		// 服务端要求等待的时间过长时直接返回，不消耗重试预算
+		delay := r.backoff.Delay(attempt)
+		var ra retryAfter
+		if errors.As(err, &ra) {
+			wait := ra.RetryAfter()
+			if limit := r.retryAfterLimit(); limit > 0 && wait > limit {
+				return err
+			}
+			delay = max(delay, wait)
+		}
+		if r.budget != nil && !r.budget.Retry() {
+			return err
+		}
+		if err := r.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// 服务端要求的等待时间的上限，0表示不限制
+func (r *Retrier) retryAfterLimit() time.Duration {
+	if r.maxRetryAfter > 0 {
+		return r.maxRetryAfter
+	}
+	return r.backoff.Max
+}
+
+// 等待d，ctx被取消时返回ctx.Err()
+func (r *Retrier) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := r.clock.NewTimer(d)
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	}
+}
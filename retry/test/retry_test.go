@@ -0,0 +1,486 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"limiter"
+	"net/http"
+	"net/http/httptest"
+	"retry"
+	"retry/grpcretry"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var errTemporary = errors.New("临时错误")
+
+// 不等待的退避策略
+var noBackoff = retry.Backoff{}
+
+// 测试指数退避 - 每次翻倍，不超过最大值
+func TestBackoffDelay(t *testing.T) {
+	b := retry.Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if got := b.Delay(i + 1); got != w {
+			t.Errorf("第%d次重试 预期等待 %v，实际: %v", i+1, w, got)
+		}
+	}
+}
+
+// 测试指数退避 - 随机减少的部分不超过Jitter
+func TestBackoffJitter(t *testing.T) {
+	b := retry.Backoff{Initial: time.Second, Multiplier: 2, Jitter: 0.5}
+	for range 1000 {
+		if got := b.Delay(2); got <= time.Second || got > 2*time.Second {
+			t.Fatalf("预期等待时间在 (1s, 2s] 之间，实际: %v", got)
+		}
+	}
+}
+
+// 测试重试 - 可以重试的错误重试到成功为止
+func TestRetrySuccess(t *testing.T) {
+	r := retry.New(retry.WithMaxAttempts(5), retry.WithBackoff(noBackoff))
+
+	calls := 0
+	err := r.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errTemporary
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("预期调用3次后成功，实际调用 %d 次，err:%v", calls, err)
+	}
+}
+
+// 测试重试 - 达到最多尝试次数、错误不可以重试时返回最后一次的错误
+func TestRetryStop(t *testing.T) {
+	errPermanent := errors.New("参数错误")
+	r := retry.New(
+		retry.WithMaxAttempts(3),
+		retry.WithBackoff(noBackoff),
+		retry.WithRetryable(func(err error) bool { return err == errTemporary }),
+	)
+
+	calls := 0
+	err := r.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errTemporary
+	})
+	if err != errTemporary || calls != 3 {
+		t.Errorf("预期调用3次，返回最后一次的错误，实际调用 %d 次，err:%v", calls, err)
+	}
+
+	calls = 0
+	err = r.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errPermanent
+	})
+	if err != errPermanent || calls != 1 {
+		t.Errorf("不可以重试的错误预期只调用1次，实际调用 %d 次，err:%v", calls, err)
+	}
+}
+
+// 测试重试 - 等待重试时ctx被取消
+func TestRetryContextCanceled(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	r := retry.New(retry.WithBackoff(retry.Backoff{Initial: time.Second}), retry.WithClock(clock))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Do(ctx, func(ctx context.Context) error { return errTemporary })
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("预期返回context.Canceled，实际: %v", err)
+	}
+}
+
+// 服务端要求等待一段时间的错误
+type retryAfterError time.Duration
+
+func (e retryAfterError) Error() string { return "限流" }
+
+func (e retryAfterError) RetryAfter() time.Duration { return time.Duration(e) }
+
+// 测试重试 - 服务端要求等待的时间超过上限时不再重试，不等待、不消耗重试预算
+func TestRetryAfterExceedsMax(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	budget := retry.NewBudget(0.5, 0, 1, retry.WithBudgetClock(clock))
+	backoff := retry.Backoff{Initial: 10 * time.Millisecond, Max: 10 * time.Second}
+
+	tests := []struct {
+		name  string
+		opts  []retry.Option
+		wait  time.Duration
+		calls int
+	}{
+		{"默认上限为退避策略的Max", nil, 24 * time.Hour, 1},
+		{"WithMaxRetryAfter", []retry.Option{retry.WithMaxRetryAfter(time.Second)}, 2 * time.Second, 1},
+		{"不超过上限", []retry.Option{retry.WithMaxRetryAfter(time.Minute)}, 30 * time.Second, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]retry.Option{retry.WithMaxAttempts(2), retry.WithBackoff(backoff),
+				retry.WithBudget(budget), retry.WithClock(clock)}, tt.opts...)
+			r := retry.New(opts...)
+
+			calls := 0
+			done := make(chan error, 1)
+			go func() {
+				done <- r.Do(context.Background(), func(ctx context.Context) error {
+					calls++
+					return retryAfterError(tt.wait)
+				})
+			}()
+			if tt.calls > 1 {
+				clock.BlockUntil(1)
+				clock.Advance(tt.wait)
+			}
+
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(time.Second):
+				t.Fatal("预期直接返回，实际仍在等待")
+			}
+			if !errors.Is(err, retryAfterError(tt.wait)) || calls != tt.calls {
+				t.Errorf("预期调用 %d 次并返回原始错误，实际调用 %d 次，err:%v", tt.calls, calls, err)
+			}
+		})
+	}
+
+	// 超过上限的两次没有消耗预算，只有最后一次重试消耗了预算
+	if stats := budget.Stats(); stats.Allowed != 1 || stats.Denied != 0 {
+		t.Errorf("预期只有1次重试消耗预算，实际: %+v", stats)
+	}
+}
+
+// 测试重试预算 - 重试次数不超过请求数的比例
+func TestBudget(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	// 重试不超过请求数的10%，不按时间生成，最多积攒1次重试
+	budget := retry.NewBudget(0.1, 0, 1, retry.WithBudgetClock(clock))
+
+	if !budget.Retry() {
+		t.Fatal("初始时预期允许1次重试")
+	}
+	if budget.Retry() {
+		t.Fatal("预算用完后预期不允许重试")
+	}
+
+	for range 9 {
+		budget.Request()
+	}
+	if budget.Retry() {
+		t.Fatal("9个请求不足以重试1次")
+	}
+	budget.Request()
+	if !budget.Retry() {
+		t.Fatal("10个请求预期允许重试1次")
+	}
+
+	want := limiter.Stats{Allowed: 2, Denied: 2}
+	if got := budget.Stats(); got != want {
+		t.Errorf("预期统计信息 %+v，实际: %+v", want, got)
+	}
+}
+
+// 测试重试预算 - 每秒至少允许的重试次数
+func TestBudgetMinPerSecond(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	budget := retry.NewBudget(0.1, 2, 1, retry.WithBudgetClock(clock))
+	budget.Retry()
+
+	clock.Advance(500 * time.Millisecond)
+	if !budget.Retry() {
+		t.Error("每秒至少允许2次重试，0.5秒后预期允许重试")
+	}
+}
+
+// 测试重试 - 预算不足时不再重试，所有调用共享预算
+func TestRetryBudgetExhausted(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	budget := retry.NewBudget(0.5, 0, 1, retry.WithBudgetClock(clock))
+	r := retry.New(retry.WithMaxAttempts(10), retry.WithBackoff(noBackoff), retry.WithBudget(budget))
+
+	calls := 0
+	fail := func(ctx context.Context) error {
+		calls++
+		return errTemporary
+	}
+
+	// 每次重试消耗2个令牌，初始积攒了1次重试，之后每个请求放入1个令牌
+	for i, want := range []int{2, 1, 2} {
+		calls = 0
+		r.Do(context.Background(), fail)
+		if calls != want {
+			t.Errorf("第%d次请求 预期调用 %d 次，实际: %d", i, want, calls)
+		}
+	}
+}
+
+// 测试HTTP重试 - 可以重试的状态码重试，最后返回成功的响应
+func TestTransportRetry(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		n := len(bodies)
+		mu.Unlock()
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: retry.NewTransport(retry.New(retry.WithBackoff(noBackoff)), nil)}
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("user"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("请求失败，err:%v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Fatalf("预期返回200 ok，实际: %d %s", resp.StatusCode, body)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 3 {
+		t.Fatalf("预期服务端收到3个请求，实际: %d", len(bodies))
+	}
+	for i, b := range bodies {
+		if b != "user" {
+			t.Errorf("第%d个请求的请求体 预期 user，实际: %q", i, b)
+		}
+	}
+}
+
+// 测试HTTP重试 - 不幂等的请求不重试，重试都失败时返回最后一次的响应
+func TestTransportNoRetry(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: retry.NewTransport(retry.New(retry.WithBackoff(noBackoff)), nil)}
+
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("order"))
+	if err != nil {
+		t.Fatalf("请求失败，err:%v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("请求失败，err:%v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("重试都失败时预期返回最后一次的响应，实际状态码: %d", resp.StatusCode)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// POST 1次 + GET 3次
+	if requests != 4 {
+		t.Errorf("预期服务端收到4个请求，实际: %d", requests)
+	}
+}
+
+// 测试HTTP重试 - 按Retry-After等待
+func TestTransportRetryAfter(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+		if n == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	clock := limiter.NewManualClock(time.Now())
+	r := retry.New(retry.WithBackoff(retry.Backoff{Initial: 10 * time.Millisecond}), retry.WithClock(clock))
+	client := &http.Client{Transport: retry.NewTransport(r, nil)}
+
+	done := make(chan *http.Response, 1)
+	go func() {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Errorf("请求失败，err:%v", err)
+		}
+		done <- resp
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	mu.Lock()
+	n := requests
+	mu.Unlock()
+	if n != 1 {
+		t.Fatalf("Retry-After之前不应该重试，服务端收到 %d 个请求", n)
+	}
+
+	clock.Advance(time.Second)
+	resp := <-done
+	if resp == nil {
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("预期返回200，实际: %d", resp.StatusCode)
+	}
+}
+
+// 测试HTTP重试 - Retry-After超过上限时不再重试，返回这次的响应
+func TestTransportRetryAfterExceedsMax(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	clock := limiter.NewManualClock(time.Now())
+	r := retry.New(retry.WithBackoff(retry.DefaultBackoff), retry.WithClock(clock))
+	client := &http.Client{Transport: retry.NewTransport(r, nil)}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("请求失败，err:%v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("预期返回429，实际: %d", resp.StatusCode)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 1 {
+		t.Errorf("预期不重试，服务端收到 %d 个请求", requests)
+	}
+}
+
+// 测试gRPC错误分类
+func TestGRPCRetryable(t *testing.T) {
+	cases := map[codes.Code]bool{
+		codes.Unavailable:       true,
+		codes.ResourceExhausted: true,
+		codes.Aborted:           true,
+		codes.DeadlineExceeded:  false,
+		codes.InvalidArgument:   false,
+		codes.NotFound:          false,
+		codes.Internal:          false,
+	}
+	for code, want := range cases {
+		if got := grpcretry.Retryable(status.Error(code, "")); got != want {
+			t.Errorf("%s 预期 %v，实际: %v", code, want, got)
+		}
+	}
+}
+
+// 测试gRPC重试拦截器
+func TestGRPCInterceptor(t *testing.T) {
+	r := retry.New(retry.WithBackoff(noBackoff), retry.WithRetryable(grpcretry.Retryable))
+	interceptor := grpcretry.UnaryClientInterceptor(r)
+
+	calls := 0
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		if calls == 1 {
+			return status.Error(codes.Unavailable, "")
+		}
+		return status.Error(codes.NotFound, "")
+	}
+	err := interceptor(context.Background(), "/user.UserService/GetUser", nil, nil, nil, invoker)
+	if status.Code(err) != codes.NotFound || calls != 2 {
+		t.Errorf("预期Unavailable重试、NotFound不重试，实际调用 %d 次，err:%v", calls, err)
+	}
+}
+
+// 测试gRPC重试拦截器 - RetryInfo中的RetryDelay作为最短的等待时间
+func TestGRPCInterceptorRetryInfo(t *testing.T) {
+	clock := limiter.NewManualClock(time.Now())
+	r := retry.New(retry.WithBackoff(retry.Backoff{Initial: 10 * time.Millisecond}),
+		retry.WithRetryable(grpcretry.Retryable), retry.WithClock(clock))
+	interceptor := grpcretry.UnaryClientInterceptor(r)
+
+	var mu sync.Mutex
+	calls := 0
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			st, _ := status.New(codes.ResourceExhausted, "限流").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(2 * time.Second)})
+			return st.Err()
+		}
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- interceptor(context.Background(), "/user.UserService/GetUser", nil, nil, nil, invoker)
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	mu.Lock()
+	n := calls
+	mu.Unlock()
+	if n != 1 {
+		t.Fatalf("RetryDelay之前不应该重试，实际调用 %d 次", n)
+	}
+
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("预期重试成功，err:%v", err)
+	}
+}
+
+// 测试gRPC重试拦截器 - 重试都失败时返回服务端原始的错误
+func TestGRPCInterceptorRetryInfoError(t *testing.T) {
+	r := retry.New(retry.WithMaxAttempts(2), retry.WithBackoff(noBackoff), retry.WithRetryable(grpcretry.Retryable))
+	interceptor := grpcretry.UnaryClientInterceptor(r)
+
+	st, _ := status.New(codes.ResourceExhausted, "限流").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(0)})
+	want := st.Err()
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return want
+	}
+	if err := interceptor(context.Background(), "/user.UserService/GetUser", nil, nil, nil, invoker); err != want {
+		t.Errorf("预期返回服务端原始的错误，实际: %v", err)
+	}
+}
@@ -9,9 +9,14 @@ import (
 
 // Config 配置
 type Config struct {
-	Env    string       `mapstructure:"env"`
-	Server ServerConfig `mapstructure:"server"`
-	Auth   AuthConfig   `mapstructure:"auth"`
+	Env       string          `mapstructure:"env"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	// 自适应并发限流
+	AdaptiveConcurrency AdaptiveConcurrencyConfig `mapstructure:"adaptive_concurrency"`
+	// 过载保护
+	LoadShedding LoadSheddingConfig `mapstructure:"load_shedding"`
 }
 
 // ServerConfig 服务器配置
@@ -28,6 +33,44 @@ type AuthConfig struct {
 	SecretToken string `mapstructure:"secret_token"`
 }
 
+// 限流 配置
+type RateLimitConfig struct {
+	Enabled  bool             `mapstructure:"enabled"`
+	Global   RateLimitRule    `mapstructure:"global"`    // 全局限流
+	PerIP    RateLimitRule    `mapstructure:"per_ip"`    // 按客户端IP限流
+	PerToken RateLimitRule    `mapstructure:"per_token"` // 按Authorization token限流
+	Routes   []RouteLimitRule `mapstructure:"routes"`    // 按路由限流
+	MaxKeys  int              `mapstructure:"max_keys"`  // 按IP、按token限流时最多保存多少个key的令牌桶，默认10000
+}
+
+// 限流规则
+type RateLimitRule struct {
+	Limit float64 `mapstructure:"limit"` // 每秒生成的令牌数，0表示不限制
+	Burst int     `mapstructure:"burst"` // 桶的容量
+}
+
+// 路由限流规则
+type RouteLimitRule struct {
+	Path          string `mapstructure:"path"`
+	RateLimitRule `mapstructure:",squash"`
+}
+
+// 自适应并发限流 配置
+type AdaptiveConcurrencyConfig struct {
+	Enabled      bool `mapstructure:"enabled"`
+	InitialLimit int  `mapstructure:"initial_limit"` // 初始并发数
+	MinLimit     int  `mapstructure:"min_limit"`     // 并发数下限
+	MaxLimit     int  `mapstructure:"max_limit"`     // 并发数上限
+}
+
+// 过载保护 配置
+type LoadSheddingConfig struct {
+	Enabled       bool  `mapstructure:"enabled"`
+	CPUThreshold  int64 `mapstructure:"cpu_threshold"`  // CPU使用率阈值，千分比，超过时开始丢弃请求
+	WindowSeconds int   `mapstructure:"window_seconds"` // 统计吞吐量和延迟的窗口大小（秒）
+	Buckets       int   `mapstructure:"buckets"`        // 窗口的桶数
+}
+
 func Load() (*Config, error) {
 	var cfg Config
 
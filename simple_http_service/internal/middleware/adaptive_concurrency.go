@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"simple_http_svc/internal/config"
+	"simple_http_svc/internal/model"
+
+	"limiter"
+)
+
+// 自适应并发限流中间件
+// 根据请求的延迟和状态码动态调整允许的并发数，5xx视为失败；并发数已满时排队，排队过多时返回503
+func AdaptiveConcurrency(cfg *config.Config) Middleware {
+	ac := cfg.AdaptiveConcurrency
+	lim := limiter.NewAdaptive(
+		limiter.WithInitialLimit(ac.InitialLimit),
+		limiter.WithLimitRange(ac.MinLimit, ac.MaxLimit),
+	)
+	return func(next http.Handler) http.Handler {
+		if !ac.Enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			release, err := lim.Acquire(r.Context())
+			if err != nil {
+				http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+
+			// handler panic时也要释放名额，视为失败
+			success := false
+			defer func() {
+				release(success)
+			}()
+
+			// 使用ResponseWriter包装器捕获状态码
+			lrw := &model.Response{ResponseWriter: w, StatusCode: http.StatusOK}
+
+			// 把请求“传递”给下一个handler
+			next.ServeHTTP(lrw, r)
+			success = lrw.StatusCode < http.StatusInternalServerError
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"simple_http_svc/internal/config"
+	"time"
+
+	"limiter/bbr"
+)
+
+// 过载保护中间件
+// CPU使用率超过阈值时，正在处理的请求数超过 最大吞吐量*最小延迟 的请求直接返回503，
+// 过载时尽早拒绝，而不是让请求排队直到http.TimeoutHandler超时
+// opts：额外的bbr配置，在配置文件的配置之后生效，如测试时固定CPU使用率
+func LoadShedding(cfg *config.Config, opts ...bbr.Option) Middleware {
+	ls := cfg.LoadShedding
+	lim := bbr.New(append([]bbr.Option{
+		bbr.WithCPUThreshold(ls.CPUThreshold),
+		bbr.WithWindow(time.Duration(ls.WindowSeconds)*time.Second, ls.Buckets),
+	}, opts...)...)
+	return func(next http.Handler) http.Handler {
+		if !ls.Enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			done, err := lim.Allow()
+			if err != nil {
+				http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			// handler panic时也要减少正在处理的请求数
+			defer done()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"simple_http_svc/internal/config"
+	"strconv"
+	"time"
+
+	"limiter"
+)
+
+// key数量上限的默认值
+const defaultRateLimitMaxKeys = 10000
+
+// 限流层级的名称
+const (
+	tierGlobal   = "global"
+	tierRoute    = "route"
+	tierPerIP    = "per_ip"
+	tierPerToken = "per_token"
+)
+
+// 限流中间件
+// 同时检查全局、按路由、按客户端IP、按token的限流规则，任意一个规则令牌不足时返回429；
+// 所有规则在同一把锁下检查和扣减（limiter.HierarchicalLimiter），被拒绝的请求不会扣减任何规则的令牌
+func RateLimit(cfg *config.Config) Middleware {
+	rl := newRateLimiter(cfg.RateLimit)
+	return func(next http.Handler) http.Handler {
+		if !cfg.RateLimit.Enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := rl.check(r)
+			if c == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// 响应头使用做出决定时每个规则的令牌桶的状态，和是否允许、Retry-After一致
+			d := c.lim.Allow(c.keys(r)...)
+			if !d.Allowed {
+				// 返回拒绝请求的规则
+				for i, tier := range c.tiers {
+					if tier.Name == d.Tier {
+						setRateLimitHeaders(w, d.States[i])
+					}
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			// 返回剩余令牌数最少的规则
+			if s, ok := mostRestrictive(d.States); ok {
+				setRateLimitHeaders(w, s)
+			}
+
+			// 把请求“传递”给下一个handler
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// 限流规则对应的限流器
+type rateLimiter struct {
+	global   *limiter.TokenBucket
+	perIP    *limiter.KeyedLimiter
+	perToken *limiter.KeyedLimiter
+	routes   map[string]*limiter.TokenBucket
+	// 按路由（没有路由规则时为空字符串）、是否携带token预先生成的多层级限流器
+	checks map[string][2]*rateLimitCheck
+}
+
+// 一个请求需要同时满足的限流规则
+type rateLimitCheck struct {
+	lim   *limiter.HierarchicalLimiter
+	tiers []limiter.Tier
+}
+
+func newRateLimiter(cfg config.RateLimitConfig) *rateLimiter {
+	rl := &rateLimiter{
+		routes: make(map[string]*limiter.TokenBucket),
+		checks: make(map[string][2]*rateLimitCheck),
+	}
+
+	// 按IP、按token限流的key来自客户端，需要限制key的数量，避免内存无限增长
+	maxKeys := cfg.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = defaultRateLimitMaxKeys
+	}
+	if limit, burst, ok := limiter.BucketParams(cfg.Global.Limit, cfg.Global.Burst); ok {
+		rl.global = limiter.New(limit, burst)
+	}
+	if limit, burst, ok := limiter.BucketParams(cfg.PerIP.Limit, cfg.PerIP.Burst); ok {
+		rl.perIP = limiter.NewKeyed(limit, burst, limiter.WithMaxKeys(maxKeys))
+	}
+	if limit, burst, ok := limiter.BucketParams(cfg.PerToken.Limit, cfg.PerToken.Burst); ok {
+		rl.perToken = limiter.NewKeyed(limit, burst, limiter.WithMaxKeys(maxKeys))
+	}
+	for _, route := range cfg.Routes {
+		if limit, burst, ok := limiter.BucketParams(route.Limit, route.Burst); ok {
+			rl.routes[route.Path] = limiter.New(limit, burst)
+		}
+	}
+
+	rl.checks[""] = [2]*rateLimitCheck{rl.newCheck(nil, false), rl.newCheck(nil, true)}
+	for path, b := range rl.routes {
+		rl.checks[path] = [2]*rateLimitCheck{rl.newCheck(b, false), rl.newCheck(b, true)}
+	}
+	return rl
+}
+
+// 生成一组限流规则，没有需要检查的规则时返回nil
+// 层级从内到外：按token、按IP、按路由、全局，多个规则同时拒绝时返回排在前面的规则
+func (rl *rateLimiter) newCheck(route *limiter.TokenBucket, withToken bool) *rateLimitCheck {
+	var tiers []limiter.Tier
+	if withToken && rl.perToken != nil {
+		tiers = append(tiers, limiter.Tier{Name: tierPerToken, Keyed: rl.perToken})
+	}
+	if rl.perIP != nil {
+		tiers = append(tiers, limiter.Tier{Name: tierPerIP, Keyed: rl.perIP})
+	}
+	if route != nil {
+		tiers = append(tiers, limiter.Tier{Name: tierRoute, Bucket: route})
+	}
+	if rl.global != nil {
+		tiers = append(tiers, limiter.Tier{Name: tierGlobal, Bucket: rl.global})
+	}
+	if len(tiers) == 0 {
+		return nil
+	}
+	return &rateLimitCheck{lim: limiter.NewHierarchical(tiers), tiers: tiers}
+}
+
+// 请求需要满足的限流规则
+func (rl *rateLimiter) check(r *http.Request) *rateLimitCheck {
+	checks, ok := rl.checks[r.URL.Path]
+	if !ok {
+		checks = rl.checks[""]
+	}
+	if r.Header.Get("Authorization") != "" {
+		return checks[1]
+	}
+	return checks[0]
+}
+
+// 每个层级的key
+func (c *rateLimitCheck) keys(r *http.Request) []string {
+	keys := make([]string, len(c.tiers))
+	for i, tier := range c.tiers {
+		switch tier.Name {
+		case tierPerIP:
+			keys[i] = clientIP(r)
+		case tierPerToken:
+			keys[i] = tokenKey(r.Header.Get("Authorization"))
+		}
+	}
+	return keys
+}
+
+// 按token限流的key
+// token由客户端提供且还没有经过认证，使用摘要作为key：key的长度固定，内存中也不保存原始的token
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
+
+// 客户端IP
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// 剩余令牌数最少的规则
+func mostRestrictive(states []limiter.TierState) (limiter.TierState, bool) {
+	var min limiter.TierState
+	for i, s := range states {
+		if i == 0 || s.Tokens < min.Tokens {
+			min = s
+		}
+	}
+	return min, len(states) > 0
+}
+
+// 设置IETF RateLimit响应头
+// RateLimit-Limit：桶的容量；RateLimit-Remaining：剩余令牌数；RateLimit-Reset：多少秒后恢复为满桶
+func setRateLimitHeaders(w http.ResponseWriter, s limiter.TierState) {
+	remaining := int(math.Floor(s.Tokens))
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(s.Burst))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(s.ResetAfter())))
+}
+
+// 向上取整的秒数
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
@@ -7,3 +7,9 @@ type Response struct {
 	http.ResponseWriter
 	StatusCode int
 }
+
+// WriteHeader 记录状态码
+func (r *Response) WriteHeader(code int) {
+	r.StatusCode = code
+	r.ResponseWriter.WriteHeader(code)
+}
@@ -17,7 +17,13 @@ func NewRouter(cfg *config.Config) http.Handler {
 	mux.HandleFunc("/get-user", user.UserHandler.GetUser)
 
 	// 全局中间件
-	server := middleware.Apply(mux, middleware.RequestLog(), middleware.Recover())
+	server := middleware.Apply(mux,
+		middleware.AdaptiveConcurrency(cfg),
+		middleware.RateLimit(cfg),
+		middleware.LoadShedding(cfg),
+		middleware.RequestLog(),
+		middleware.Recover(),
+	)
 
 	// 设置超时
 	handler := http.TimeoutHandler(server,
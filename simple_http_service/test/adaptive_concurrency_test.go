@@ -0,0 +1,99 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"simple_http_svc/internal/config"
+	"simple_http_svc/internal/middleware"
+	"testing"
+	"time"
+)
+
+// 创建使用自适应并发限流中间件的handler，block不为nil时请求阻塞直到block关闭
+func adaptiveHandler(initial, min, max int, status *int, block <-chan struct{}, started chan<- struct{}) http.Handler {
+	cfg := &config.Config{AdaptiveConcurrency: config.AdaptiveConcurrencyConfig{
+		Enabled:      true,
+		InitialLimit: initial,
+		MinLimit:     min,
+		MaxLimit:     max,
+	}}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/block" {
+			started <- struct{}{}
+			<-block
+		}
+		w.WriteHeader(*status)
+	})
+	return middleware.Apply(h, middleware.AdaptiveConcurrency(cfg))
+}
+
+// 在短超时内发送请求，返回状态码
+func tryRequest(h http.Handler) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+// 占用一个名额，返回释放名额的函数
+func holdRequest(h http.Handler, started <-chan struct{}, block chan struct{}) (release func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/block", nil))
+	}()
+	<-started
+	return func() {
+		close(block)
+		<-done
+	}
+}
+
+func TestAdaptiveConcurrency503(t *testing.T) {
+	status := http.StatusOK
+	block, started := make(chan struct{}), make(chan struct{}, 1)
+	h := adaptiveHandler(1, 1, 1, &status, block, started)
+
+	release := holdRequest(h, started, block)
+	if code := tryRequest(h); code != http.StatusServiceUnavailable {
+		t.Fatalf("并发数已满时 status = %d, want 503", code)
+	}
+	release()
+
+	if code := tryRequest(h); code != http.StatusOK {
+		t.Fatalf("名额释放后 status = %d, want 200", code)
+	}
+}
+
+// 只有5xx减小并发数
+func TestAdaptiveConcurrencyStatusCode(t *testing.T) {
+	tests := []struct {
+		status int
+		reduce bool
+	}{
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusNotFound, false},
+		{http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			status := tt.status
+			block, started := make(chan struct{}), make(chan struct{}, 1)
+			h := adaptiveHandler(2, 1, 2, &status, block, started)
+			tryRequest(h)
+
+			// 并发数为2时可以同时处理两个请求，减小为1之后第二个请求被拒绝
+			status = http.StatusOK
+			release := holdRequest(h, started, block)
+			defer release()
+			code := tryRequest(h)
+			if reduced := code == http.StatusServiceUnavailable; reduced != tt.reduce {
+				t.Errorf("%d 之后第二个并发请求 status = %d, 并发数减小 = %v, want %v", tt.status, code, reduced, tt.reduce)
+			}
+		})
+	}
+}
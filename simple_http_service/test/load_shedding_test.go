@@ -0,0 +1,65 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"simple_http_svc/internal/config"
+	"simple_http_svc/internal/middleware"
+	"testing"
+
+	"limiter/bbr"
+)
+
+// 创建使用过载保护中间件的handler，请求/nested时在处理过程中再发一个请求，记录它的状态码
+func loadSheddingHandler(enabled bool, nested *int) http.Handler {
+	cfg := &config.Config{LoadShedding: config.LoadSheddingConfig{
+		Enabled:       enabled,
+		CPUThreshold:  800,
+		WindowSeconds: 1,
+		Buckets:       10,
+	}}
+	var h http.Handler
+	h = middleware.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/nested" {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			*nested = rec.Code
+		}
+		w.WriteHeader(http.StatusOK)
+	}), middleware.LoadShedding(cfg, bbr.WithCPU(func() int64 { return 900 })))
+	return h
+}
+
+// 过载时返回503
+func TestLoadShedding503(t *testing.T) {
+	var nested int
+	h := loadSheddingHandler(true, &nested)
+
+	// CPU使用率超过阈值，没有历史数据时只允许1个并发
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/nested", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("第1个请求 status = %d, want 200", rec.Code)
+	}
+	if nested != http.StatusServiceUnavailable {
+		t.Fatalf("过载时 status = %d, want 503", nested)
+	}
+
+	// 请求完成后恢复
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("请求完成后 status = %d, want 200", rec.Code)
+	}
+}
+
+func TestLoadSheddingDisabled(t *testing.T) {
+	var nested int
+	h := loadSheddingHandler(false, &nested)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/nested", nil))
+	if rec.Code != http.StatusOK || nested != http.StatusOK {
+		t.Fatalf("未启用时 status = %d/%d, want 200/200", rec.Code, nested)
+	}
+}
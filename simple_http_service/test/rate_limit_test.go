@@ -0,0 +1,191 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"simple_http_svc/internal/config"
+	"simple_http_svc/internal/middleware"
+	"strconv"
+	"testing"
+)
+
+// 令牌几乎不恢复的限流规则，测试过程中只能使用桶中的令牌
+func rule(burst int) config.RateLimitRule {
+	return config.RateLimitRule{Limit: 0.001, Burst: burst}
+}
+
+// 创建只有限流中间件的handler
+func rateLimitHandler(rl config.RateLimitConfig) http.Handler {
+	cfg := &config.Config{RateLimit: rl}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return middleware.Apply(ok, middleware.RateLimit(cfg))
+}
+
+// 发送请求，ip和token为空时使用默认值
+func doRequest(h http.Handler, path, ip, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if ip != "" {
+		req.RemoteAddr = ip + ":12345"
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitGlobal(t *testing.T) {
+	h := rateLimitHandler(config.RateLimitConfig{Enabled: true, Global: rule(2)})
+
+	for i := 0; i < 2; i++ {
+		rec := doRequest(h, "/", "", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("第%d个请求 status = %d, want 200", i, rec.Code)
+		}
+		if got, want := rec.Header().Get("RateLimit-Remaining"), strconv.Itoa(1-i); got != want {
+			t.Errorf("第%d个请求 RateLimit-Remaining = %s, want %s", i, got, want)
+		}
+	}
+
+	rec := doRequest(h, "/", "", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got == "" || got == "0" {
+		t.Errorf("Retry-After = %q, want >0", got)
+	}
+	if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Errorf("RateLimit-Limit = %s, want 2", got)
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %s, want 0", got)
+	}
+	if got := rec.Header().Get("RateLimit-Reset"); got == "" || got == "0" {
+		t.Errorf("RateLimit-Reset = %q, want >0", got)
+	}
+}
+
+// 被拒绝时，响应头和拒绝请求的决定使用同一个时间的令牌桶状态
+func TestRateLimitDeniedHeaders(t *testing.T) {
+	h := rateLimitHandler(config.RateLimitConfig{Enabled: true, PerIP: config.RateLimitRule{Limit: 1, Burst: 2}})
+
+	doRequest(h, "/", "10.0.0.1", "")
+	doRequest(h, "/", "10.0.0.1", "")
+	rec := doRequest(h, "/", "10.0.0.1", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	// 每秒生成1个令牌：1秒后可以重试，2秒后恢复满桶
+	want := map[string]string{
+		"Retry-After":         "1",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "2",
+	}
+	for name, w := range want {
+		if got := rec.Header().Get(name); got != w {
+			t.Errorf("%s = %s, want %s", name, got, w)
+		}
+	}
+}
+
+func TestRateLimitPerIP(t *testing.T) {
+	h := rateLimitHandler(config.RateLimitConfig{Enabled: true, PerIP: rule(1)})
+
+	if rec := doRequest(h, "/", "10.0.0.1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("10.0.0.1 第1个请求 status = %d, want 200", rec.Code)
+	}
+	if rec := doRequest(h, "/", "10.0.0.1", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("10.0.0.1 第2个请求 status = %d, want 429", rec.Code)
+	}
+	// 不同的IP使用独立的令牌桶
+	if rec := doRequest(h, "/", "10.0.0.2", ""); rec.Code != http.StatusOK {
+		t.Fatalf("10.0.0.2 status = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimitPerToken(t *testing.T) {
+	h := rateLimitHandler(config.RateLimitConfig{Enabled: true, PerToken: rule(1)})
+
+	if rec := doRequest(h, "/", "", "Bearer a"); rec.Code != http.StatusOK {
+		t.Fatalf("token a 第1个请求 status = %d, want 200", rec.Code)
+	}
+	if rec := doRequest(h, "/", "", "Bearer a"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("token a 第2个请求 status = %d, want 429", rec.Code)
+	}
+	if rec := doRequest(h, "/", "", "Bearer b"); rec.Code != http.StatusOK {
+		t.Fatalf("token b status = %d, want 200", rec.Code)
+	}
+	// 没有携带token的请求不检查按token限流的规则
+	for i := 0; i < 3; i++ {
+		if rec := doRequest(h, "/", "", ""); rec.Code != http.StatusOK {
+			t.Fatalf("没有token的第%d个请求 status = %d, want 200", i, rec.Code)
+		}
+	}
+}
+
+func TestRateLimitRoute(t *testing.T) {
+	h := rateLimitHandler(config.RateLimitConfig{
+		Enabled: true,
+		Routes:  []config.RouteLimitRule{{Path: "/get-user", RateLimitRule: rule(1)}},
+	})
+
+	if rec := doRequest(h, "/get-user", "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("/get-user 第1个请求 status = %d, want 200", rec.Code)
+	}
+	if rec := doRequest(h, "/get-user", "", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("/get-user 第2个请求 status = %d, want 429", rec.Code)
+	}
+	// 其他路由不受影响
+	for i := 0; i < 3; i++ {
+		if rec := doRequest(h, "/other", "", ""); rec.Code != http.StatusOK {
+			t.Fatalf("/other 第%d个请求 status = %d, want 200", i, rec.Code)
+		}
+	}
+}
+
+// 被拒绝的请求不扣减其他规则的令牌
+func TestRateLimitDeniedNoDebt(t *testing.T) {
+	h := rateLimitHandler(config.RateLimitConfig{Enabled: true, Global: rule(3), PerIP: rule(1)})
+
+	if rec := doRequest(h, "/", "10.0.0.1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	// 10.0.0.1 被按IP的规则拒绝，不消耗全局的令牌
+	for i := 0; i < 5; i++ {
+		rec := doRequest(h, "/", "10.0.0.1", "")
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("第%d个被拒绝的请求 status = %d, want 429", i, rec.Code)
+		}
+		if got := rec.Header().Get("RateLimit-Limit"); got != "1" {
+			t.Errorf("RateLimit-Limit = %s, want 1（按IP的规则）", got)
+		}
+	}
+	// 全局还剩2个令牌
+	if rec := doRequest(h, "/", "10.0.0.2", ""); rec.Code != http.StatusOK {
+		t.Fatalf("10.0.0.2 status = %d, want 200", rec.Code)
+	}
+	if rec := doRequest(h, "/", "10.0.0.3", ""); rec.Code != http.StatusOK {
+		t.Fatalf("10.0.0.3 status = %d, want 200", rec.Code)
+	}
+	if rec := doRequest(h, "/", "10.0.0.4", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("10.0.0.4 status = %d, want 429", rec.Code)
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	h := rateLimitHandler(config.RateLimitConfig{Enabled: false, Global: rule(1)})
+
+	for i := 0; i < 3; i++ {
+		rec := doRequest(h, "/", "", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("第%d个请求 status = %d, want 200", i, rec.Code)
+		}
+		if got := rec.Header().Get("RateLimit-Limit"); got != "" {
+			t.Errorf("RateLimit-Limit = %s, want 空", got)
+		}
+	}
+}
@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"log"
 	"simple_rpc_svc/internal/config"
+	"simple_rpc_svc/internal/interceptor"
 	"simple_rpc_svc/internal/proto"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+
+	"breaker"
 )
 
 // grpc客户端
@@ -20,7 +23,16 @@ func main() {
 	}
 
 	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
-	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	// 客户端熔断，下游不可用时快速失败
+	cb := interceptor.NewCircuitBreaker(cfg, func(method string, from, to breaker.State) {
+		log.Printf("熔断器状态变化，method:%s，%s -> %s", method, from, to)
+	})
+	// 客户端重试，在熔断器内部执行，熔断器打开时不再重试；熔断器按重试后的最终结果统计
+	rt := interceptor.NewRetry(cfg)
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(cb.UnaryClientInterceptor(), rt.UnaryClientInterceptor()),
+	)
 	if err != nil {
 		fmt.Printf("无法连接到grpc服务器，err;%v", err)
 	}
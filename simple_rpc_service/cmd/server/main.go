@@ -22,7 +22,10 @@ func main() {
 	}
 
 	// 创建并启动gPRC服务器
-	grpcServer := server.NewGRPCServer(cfg)
+	grpcServer, err := server.NewGRPCServer(cfg)
+	if err != nil {
+		log.Fatalf("创建gRPC服务器失败,err:%+v", err)
+	}
 
 	// 2. 启动 pprof 服务（单独端口，如 6061）
 	go func() {
@@ -9,9 +9,20 @@ import (
 
 // Config 配置
 type Config struct {
-	Env    string       `mapstructure:"env"`
-	Server ServerConfig `mapstructure:"server"`
-	Auth   AuthConfig   `mapstructure:"auth"`
+	Env       string          `mapstructure:"env"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	// 自适应并发限流
+	AdaptiveConcurrency AdaptiveConcurrencyConfig `mapstructure:"adaptive_concurrency"`
+	// 过载保护
+	LoadShedding LoadSheddingConfig `mapstructure:"load_shedding"`
+	// 配额服务
+	Quota QuotaConfig `mapstructure:"quota"`
+	// 客户端熔断
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+	// 客户端重试
+	Retry RetryConfig `mapstructure:"retry"`
 }
 
 // ServerConfig 服务器配置
@@ -29,6 +40,81 @@ type AuthConfig struct {
 	SecretToken string `mapstructure:"secret_token"`
 }
 
+// 限流 配置
+type RateLimitConfig struct {
+	Enabled   bool              `mapstructure:"enabled"`
+	PerMethod RateLimitRule     `mapstructure:"per_method"` // 按完整方法名限流，每个方法一个令牌桶
+	Methods   []MethodLimitRule `mapstructure:"methods"`    // 指定方法的限流规则，覆盖per_method
+	PerCaller CallerLimitRule   `mapstructure:"per_caller"` // 按调用方限流
+}
+
+// 限流规则
+type RateLimitRule struct {
+	Limit float64 `mapstructure:"limit"` // 每秒生成的令牌数，0表示不限制
+	Burst int     `mapstructure:"burst"` // 桶的容量
+}
+
+// 方法限流规则
+type MethodLimitRule struct {
+	Method        string `mapstructure:"method"` // 完整方法名，如 /user.UserService/GetUser
+	RateLimitRule `mapstructure:",squash"`
+}
+
+// 调用方限流规则
+type CallerLimitRule struct {
+	MetadataKey   string `mapstructure:"metadata_key"` // 标识调用方的metadata字段，未携带时使用客户端IP
+	MaxKeys       int    `mapstructure:"max_keys"`     // 最多保存多少个调用方的令牌桶，默认10000
+	RateLimitRule `mapstructure:",squash"`
+}
+
+// 自适应并发限流 配置
+type AdaptiveConcurrencyConfig struct {
+	Enabled      bool `mapstructure:"enabled"`
+	InitialLimit int  `mapstructure:"initial_limit"` // 初始并发数
+	MinLimit     int  `mapstructure:"min_limit"`     // 并发数下限
+	MaxLimit     int  `mapstructure:"max_limit"`     // 并发数上限
+}
+
+// 配额服务 配置
+type QuotaConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// 每个key的令牌生成速率和桶的容量
+	RateLimitRule `mapstructure:",squash"`
+	MaxBatch      int `mapstructure:"max_batch"`    // 每次最多分配的令牌数
+	LeaseMillis   int `mapstructure:"lease_millis"` // 租约时长（毫秒），到期后客户端未使用的令牌作废
+	MaxKeys       int `mapstructure:"max_keys"`     // 最多保存多少个key的令牌桶，默认10000
+}
+
+// 熔断 配置
+type CircuitBreakerConfig struct {
+	Enabled             bool    `mapstructure:"enabled"`
+	ConsecutiveFailures int     `mapstructure:"consecutive_failures"` // 连续失败多少次时打开，0表示不使用
+	ErrorRate           float64 `mapstructure:"error_rate"`           // 窗口内的错误率达到多少时打开，0表示不使用
+	MinRequests         int     `mapstructure:"min_requests"`         // 窗口内的请求数达到多少时才按错误率判断
+	WindowSeconds       int     `mapstructure:"window_seconds"`       // 统计错误率的窗口大小（秒）
+	CoolDownSeconds     int     `mapstructure:"cool_down_seconds"`    // 打开后经过多久进入半开状态（秒）
+	HalfOpenProbes      int     `mapstructure:"half_open_probes"`     // 半开状态允许的探测请求数
+}
+
+// 过载保护 配置
+type LoadSheddingConfig struct {
+	Enabled       bool  `mapstructure:"enabled"`
+	CPUThreshold  int64 `mapstructure:"cpu_threshold"`  // CPU使用率阈值，千分比，超过时开始丢弃请求
+	WindowSeconds int   `mapstructure:"window_seconds"` // 统计吞吐量和延迟的窗口大小（秒）
+	Buckets       int   `mapstructure:"buckets"`        // 窗口的桶数
+}
+
+// 重试 配置
+type RetryConfig struct {
+	Enabled              bool    `mapstructure:"enabled"`
+	MaxAttempts          int     `mapstructure:"max_attempts"`           // 最多尝试的次数，包括第一次调用
+	InitialBackoffMillis int     `mapstructure:"initial_backoff_millis"` // 第一次重试前的等待时间（毫秒），之后每次翻倍
+	MaxBackoffMillis     int     `mapstructure:"max_backoff_millis"`     // 最长等待时间（毫秒）
+	BudgetRatio          float64 `mapstructure:"budget_ratio"`           // 重试次数占请求数的比例，0表示不限制
+	MinRetriesPerSecond  float64 `mapstructure:"min_retries_per_second"` // 每秒至少允许的重试次数
+	MaxRetries           int     `mapstructure:"max_retries"`            // 最多积攒的重试次数
+}
+
 func Load() (*Config, error) {
 	var cfg Config
 
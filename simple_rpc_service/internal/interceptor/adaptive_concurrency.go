@@ -0,0 +1,79 @@
+package interceptor
+
+import (
+	"context"
+	"simple_rpc_svc/internal/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"limiter"
+)
+
+// AdaptiveConcurrency 自适应并发限流拦截器
+// 根据请求的延迟和结果动态调整允许的并发数，并发数已满时排队，排队过多时返回Unavailable
+type AdaptiveConcurrency struct {
+	enabled bool
+	lim     *limiter.AdaptiveLimiter
+}
+
+// NewAdaptiveConcurrency 创建自适应并发限流拦截器
+func NewAdaptiveConcurrency(cfg *config.Config) *AdaptiveConcurrency {
+	ac := cfg.AdaptiveConcurrency
+	return &AdaptiveConcurrency{
+		enabled: ac.Enabled,
+		lim: limiter.NewAdaptive(
+			limiter.WithInitialLimit(ac.InitialLimit),
+			limiter.WithLimitRange(ac.MinLimit, ac.MaxLimit),
+		),
+	}
+}
+
+// UnaryServerInterceptor unary自适应并发限流拦截器
+func (a *AdaptiveConcurrency) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		if !a.enabled {
+			return handler(ctx, req)
+		}
+		release, err := a.lim.Acquire(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, "服务繁忙，请稍后重试")
+		}
+		// handler panic时也要释放名额，视为失败
+		success := false
+		defer func() {
+			release(success)
+		}()
+
+		resp, err = handler(ctx, req)
+		success = !isOverloadError(err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor stream自适应并发限流拦截器
+// stream只在建立时通过Check判断并发数是否已满：持续时间取决于客户端，不占用名额，也不作为延迟样本，
+// 否则长时间的stream会被当作很慢的请求，把并发数减小到下限，挤占unary请求
+func (a *AdaptiveConcurrency) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if a.enabled {
+			if err := a.lim.Check(); err != nil {
+				return status.Error(codes.Unavailable, "服务繁忙，请稍后重试")
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// isOverloadError 是否是服务端过载导致的错误
+// 业务错误（如NotFound、InvalidArgument）不代表服务端过载，视为成功；
+// Internal、Unknown通常是代码缺陷（如panic、未转换的error），降低并发数无济于事，也视为成功
+func isOverloadError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
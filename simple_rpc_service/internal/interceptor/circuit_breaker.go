@@ -0,0 +1,116 @@
+package interceptor
+
+import (
+	"context"
+	"simple_rpc_svc/internal/config"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"breaker"
+)
+
+// CircuitBreaker 客户端熔断拦截器
+// 每个方法一个熔断器，下游过载或不可用时快速失败，返回Unavailable，不再发送请求
+type CircuitBreaker struct {
+	cfg           config.CircuitBreakerConfig
+	onStateChange func(method string, from, to breaker.State)
+
+	mu       sync.Mutex                  // 保护breakers
+	breakers map[string]*breaker.Breaker // 方法名 -> 熔断器
+}
+
+// NewCircuitBreaker 创建客户端熔断拦截器
+// onStateChange：熔断器状态变化时的回调，可以为nil
+func NewCircuitBreaker(cfg *config.Config, onStateChange func(method string, from, to breaker.State)) *CircuitBreaker {
+	return &CircuitBreaker{
+		cfg:           cfg.CircuitBreaker,
+		onStateChange: onStateChange,
+		breakers:      make(map[string]*breaker.Breaker),
+	}
+}
+
+// UnaryClientInterceptor unary熔断拦截器
+// 只有下游异常导致的错误计为失败，业务错误（如NotFound、InvalidArgument）说明下游正常
+func (c *CircuitBreaker) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !c.cfg.Enabled {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		done, err := c.get(method).Allow()
+		if err != nil {
+			return status.Errorf(codes.Unavailable, "%s 已熔断: %v", method, err)
+		}
+		// invoker panic时也要报告结果，视为失败
+		success := false
+		defer func() {
+			done(success)
+		}()
+
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		success = !isBreakerFailure(err)
+		return err
+	}
+}
+
+// 方法对应的熔断器，不存在时创建
+func (c *CircuitBreaker) get(method string) *breaker.Breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if b, ok := c.breakers[method]; ok {
+		return b
+	}
+
+	opts := []breaker.Option{
+		breaker.WithCoolDown(time.Duration(c.cfg.CoolDownSeconds) * time.Second),
+		breaker.WithHalfOpenProbes(c.cfg.HalfOpenProbes),
+	}
+	// 熔断策略有状态，每个熔断器使用独立的策略
+	if policy := c.newPolicy(); policy != nil {
+		opts = append(opts, breaker.WithPolicy(policy))
+	}
+	if c.onStateChange != nil {
+		// 熔断器以方法名命名，回调中的name就是方法名
+		opts = append(opts, breaker.WithOnStateChange(c.onStateChange))
+	}
+
+	b := breaker.New(method, opts...)
+	c.breakers[method] = b
+	return b
+}
+
+// 按配置生成熔断策略，都未配置时返回nil，使用熔断器的默认策略
+func (c *CircuitBreaker) newPolicy() breaker.TripPolicy {
+	var policies []breaker.TripPolicy
+	if c.cfg.ConsecutiveFailures > 0 {
+		policies = append(policies, breaker.ConsecutiveFailures(c.cfg.ConsecutiveFailures))
+	}
+	if c.cfg.ErrorRate > 0 {
+		window := time.Duration(c.cfg.WindowSeconds) * time.Second
+		policies = append(policies, breaker.ErrorRate(c.cfg.ErrorRate, c.cfg.MinRequests, window))
+	}
+
+	switch len(policies) {
+	case 0:
+		return nil
+	case 1:
+		return policies[0]
+	default:
+		return breaker.Any(policies...)
+	}
+}
+
+// isBreakerFailure 是否是下游异常导致的错误，计为熔断器的失败
+// 和自适应并发限流的isOverloadError不同：ResourceExhausted通常是下游对调用方限流，说明下游正常，
+// 熔断之后该方法的所有请求都会失败，比限流更糟；Internal、Unknown说明下游有问题，计为失败
+func isBreakerFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,65 @@
+package interceptor
+
+import (
+	"context"
+	"simple_rpc_svc/internal/config"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"limiter/bbr"
+)
+
+// LoadShedder 过载保护拦截器
+// CPU使用率超过阈值时，正在处理的请求数超过 最大吞吐量*最小延迟 的请求直接返回Unavailable
+type LoadShedder struct {
+	enabled bool
+	lim     *bbr.Limiter
+}
+
+// NewLoadShedder 创建过载保护拦截器
+// opts：额外的bbr配置，在配置文件的配置之后生效，如测试时固定CPU使用率
+func NewLoadShedder(cfg *config.Config, opts ...bbr.Option) *LoadShedder {
+	ls := cfg.LoadShedding
+	opts = append([]bbr.Option{
+		bbr.WithCPUThreshold(ls.CPUThreshold),
+		bbr.WithWindow(time.Duration(ls.WindowSeconds)*time.Second, ls.Buckets),
+	}, opts...)
+	return &LoadShedder{
+		enabled: ls.Enabled,
+		lim:     bbr.New(opts...),
+	}
+}
+
+// UnaryServerInterceptor unary过载保护拦截器
+func (l *LoadShedder) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		if !l.enabled {
+			return handler(ctx, req)
+		}
+		done, err := l.lim.Allow()
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, "服务过载，请稍后重试")
+		}
+		// handler panic时也要减少正在处理的请求数
+		defer done()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor stream过载保护拦截器，只在建立stream时判断是否过载
+// stream的持续时间取决于客户端，不计入正在处理的请求数和延迟，否则会把最小延迟拉高、挤占unary请求的并发数
+func (l *LoadShedder) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !l.enabled {
+			return handler(srv, ss)
+		}
+		if err := l.lim.Check(); err != nil {
+			return status.Error(codes.Unavailable, "服务过载，请稍后重试")
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,193 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"simple_rpc_svc/internal/config"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"limiter"
+)
+
+// 调用方数量上限的默认值
+const defaultCallerMaxKeys = 10000
+
+// 限流层级的名称
+const (
+	tierPerCaller = "per_caller"
+	tierMethod    = "method"
+)
+
+// RateLimiter gRPC限流拦截器
+// 按完整方法名和调用方限流，unary和stream拦截器共享同一份配额
+type RateLimiter struct {
+	enabled     bool
+	perMethod   *limiter.KeyedLimiter           // 按完整方法名限流
+	methods     map[string]*limiter.TokenBucket // 指定方法的限流规则
+	perCaller   *limiter.KeyedLimiter           // 按调用方限流
+	metadataKey string                          // 标识调用方的metadata字段
+
+	checks map[string]*rateLimitCheck // 指定方法需要满足的限流规则，按完整方法名索引
+	check  *rateLimitCheck            // 其他方法需要满足的限流规则，没有规则时为nil
+	exempt map[string]bool            // 不限流的服务，按完整服务名索引
+}
+
+// rateLimitCheck 一个请求需要同时满足的限流规则
+type rateLimitCheck struct {
+	lim   *limiter.HierarchicalLimiter
+	tiers []limiter.Tier
+}
+
+// NewRateLimiter 创建限流拦截器
+func NewRateLimiter(cfg *config.Config) *RateLimiter {
+	rl := &RateLimiter{
+		enabled:     cfg.RateLimit.Enabled,
+		methods:     make(map[string]*limiter.TokenBucket),
+		metadataKey: cfg.RateLimit.PerCaller.MetadataKey,
+		checks:      make(map[string]*rateLimitCheck),
+		exempt:      make(map[string]bool),
+	}
+	if limit, burst, ok := limiter.BucketParams(cfg.RateLimit.PerMethod.Limit, cfg.RateLimit.PerMethod.Burst); ok {
+		// 未注册的方法不会经过拦截器，key的数量不超过服务的方法数
+		rl.perMethod = limiter.NewKeyed(limit, burst)
+	}
+	for _, m := range cfg.RateLimit.Methods {
+		if limit, burst, ok := limiter.BucketParams(m.Limit, m.Burst); ok {
+			rl.methods[m.Method] = limiter.New(limit, burst)
+		}
+	}
+	if limit, burst, ok := limiter.BucketParams(cfg.RateLimit.PerCaller.Limit, cfg.RateLimit.PerCaller.Burst); ok {
+		// 调用方标识来自客户端，需要限制key的数量，避免内存无限增长
+		maxKeys := cfg.RateLimit.PerCaller.MaxKeys
+		if maxKeys <= 0 {
+			maxKeys = defaultCallerMaxKeys
+		}
+		rl.perCaller = limiter.NewKeyed(limit, burst, limiter.WithMaxKeys(maxKeys))
+	}
+
+	var method []limiter.Tier
+	if rl.perMethod != nil {
+		method = append(method, limiter.Tier{Name: tierMethod, Keyed: rl.perMethod})
+	}
+	rl.check = rl.newCheck(method)
+	for name, b := range rl.methods {
+		rl.checks[name] = rl.newCheck([]limiter.Tier{{Name: tierMethod, Bucket: b}})
+	}
+	return rl
+}
+
+// newCheck 生成一组限流规则，层级从内到外依次为调用方、方法；没有需要检查的规则时返回nil
+func (rl *RateLimiter) newCheck(method []limiter.Tier) *rateLimitCheck {
+	var tiers []limiter.Tier
+	if rl.perCaller != nil {
+		tiers = append(tiers, limiter.Tier{Name: tierPerCaller, Keyed: rl.perCaller})
+	}
+	tiers = append(tiers, method...)
+	if len(tiers) == 0 {
+		return nil
+	}
+	return &rateLimitCheck{lim: limiter.NewHierarchical(tiers), tiers: tiers}
+}
+
+// Exempt 不对指定服务的方法限流，如配额服务：它本身就是限流的一部分，被限流后客户端只能使用降级的速率
+// services为完整服务名，如 quota.QuotaService；需要在启动服务之前调用
+func (rl *RateLimiter) Exempt(services ...string) {
+	for _, service := range services {
+		rl.exempt[service] = true
+	}
+}
+
+// UnaryServerInterceptor unary限流拦截器
+func (rl *RateLimiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := rl.allow(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor stream限流拦截器，建立stream时获取令牌
+func (rl *RateLimiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := rl.allow(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// allow 在同一把锁下检查方法和调用方的限流规则
+// 任意一个规则令牌不足时返回ResourceExhausted，并在RetryInfo中携带重试时间；被拒绝的请求不扣减任何规则的令牌
+func (rl *RateLimiter) allow(ctx context.Context, fullMethod string) error {
+	if !rl.enabled {
+		return nil
+	}
+
+	if rl.exempt[serviceName(fullMethod)] {
+		return nil
+	}
+
+	check, ok := rl.checks[fullMethod]
+	if !ok {
+		check = rl.check
+	}
+	if check == nil {
+		return nil
+	}
+	keys := make([]string, len(check.tiers))
+	for i, tier := range check.tiers {
+		if tier.Name == tierPerCaller {
+			keys[i] = rl.caller(ctx)
+		} else {
+			keys[i] = fullMethod
+		}
+	}
+	if d := check.lim.Allow(keys...); !d.Allowed {
+		return resourceExhausted(fullMethod, d.RetryAfter)
+	}
+	return nil
+}
+
+// caller 调用方标识：优先使用metadata中的字段，未携带时使用客户端IP
+func (rl *RateLimiter) caller(ctx context.Context) string {
+	if rl.metadataKey != "" {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(rl.metadataKey); len(values) > 0 && values[0] != "" {
+				return values[0]
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// serviceName 完整方法名中的服务名，如 /quota.QuotaService/Acquire 中的 quota.QuotaService
+func serviceName(fullMethod string) string {
+	service, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service
+}
+
+// resourceExhausted 限流错误，携带重试时间
+func resourceExhausted(fullMethod string, delay time.Duration) error {
+	st := status.New(codes.ResourceExhausted, "请求过于频繁，已被限流: "+fullMethod)
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
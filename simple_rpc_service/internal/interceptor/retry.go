@@ -0,0 +1,54 @@
+package interceptor
+
+import (
+	"context"
+	"simple_rpc_svc/internal/config"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"retry"
+	"retry/grpcretry"
+)
+
+// Retry 客户端重试拦截器
+// Unavailable、ResourceExhausted、Aborted按指数退避重试，所有方法共享一个重试预算，下游故障时不会因为重试放大流量
+type Retry struct {
+	enabled bool
+	retrier *retry.Retrier
+}
+
+// NewRetry 创建客户端重试拦截器
+func NewRetry(cfg *config.Config) *Retry {
+	rc := cfg.Retry
+	backoff := retry.DefaultBackoff
+	if rc.InitialBackoffMillis > 0 {
+		backoff.Initial = time.Duration(rc.InitialBackoffMillis) * time.Millisecond
+	}
+	if rc.MaxBackoffMillis > 0 {
+		backoff.Max = time.Duration(rc.MaxBackoffMillis) * time.Millisecond
+	}
+
+	opts := []retry.Option{
+		retry.WithMaxAttempts(rc.MaxAttempts),
+		retry.WithBackoff(backoff),
+		retry.WithRetryable(grpcretry.Retryable),
+	}
+	if rc.BudgetRatio > 0 {
+		opts = append(opts, retry.WithBudget(retry.NewBudget(rc.BudgetRatio, rc.MinRetriesPerSecond, rc.MaxRetries)))
+	}
+	return &Retry{
+		enabled: rc.Enabled,
+		retrier: retry.New(opts...),
+	}
+}
+
+// UnaryClientInterceptor unary重试拦截器
+func (r *Retry) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	if !r.enabled {
+		return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+	return grpcretry.UnaryClientInterceptor(r.retrier)
+}
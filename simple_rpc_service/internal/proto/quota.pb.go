@@ -0,0 +1,215 @@
+// 使用 Protocol Buffers 语法版本
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.7
+// 	protoc        v5.29.3
+// source: quota.proto
+
+// 包名，用于避免命名冲突
+
+package proto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// 申请令牌的请求
+type AcquireRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`                           // 配额的key，如 接口名、租户ID
+	ClientId      string                 `protobuf:"bytes,2,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"` // 客户端ID，用于日志和排查问题
+	Tokens        int64                  `protobuf:"varint,3,opt,name=tokens,proto3" json:"tokens,omitempty"`                    // 申请的令牌数
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AcquireRequest) Reset() {
+	*x = AcquireRequest{}
+	mi := &file_quota_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AcquireRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AcquireRequest) ProtoMessage() {}
+
+func (x *AcquireRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_quota_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AcquireRequest.ProtoReflect.Descriptor instead.
+func (*AcquireRequest) Descriptor() ([]byte, []int) {
+	return file_quota_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AcquireRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *AcquireRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *AcquireRequest) GetTokens() int64 {
+	if x != nil {
+		return x.Tokens
+	}
+	return 0
+}
+
+// 申请令牌的返回值
+type AcquireResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Granted          int64                  `protobuf:"varint,1,opt,name=granted,proto3" json:"granted,omitempty"`                                             // 分配的令牌数，配额不足时为0
+	LeaseMillis      int64                  `protobuf:"varint,2,opt,name=lease_millis,json=leaseMillis,proto3" json:"lease_millis,omitempty"`                  // 租约时长（毫秒），到期后未使用的令牌作废
+	RetryAfterMillis int64                  `protobuf:"varint,3,opt,name=retry_after_millis,json=retryAfterMillis,proto3" json:"retry_after_millis,omitempty"` // 配额不足时，多久之后可以重新申请（毫秒）
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *AcquireResponse) Reset() {
+	*x = AcquireResponse{}
+	mi := &file_quota_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AcquireResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AcquireResponse) ProtoMessage() {}
+
+func (x *AcquireResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_quota_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AcquireResponse.ProtoReflect.Descriptor instead.
+func (*AcquireResponse) Descriptor() ([]byte, []int) {
+	return file_quota_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AcquireResponse) GetGranted() int64 {
+	if x != nil {
+		return x.Granted
+	}
+	return 0
+}
+
+func (x *AcquireResponse) GetLeaseMillis() int64 {
+	if x != nil {
+		return x.LeaseMillis
+	}
+	return 0
+}
+
+func (x *AcquireResponse) GetRetryAfterMillis() int64 {
+	if x != nil {
+		return x.RetryAfterMillis
+	}
+	return 0
+}
+
+var File_quota_proto protoreflect.FileDescriptor
+
+const file_quota_proto_rawDesc = "" +
+	"\n" +
+	"\vquota.proto\x12\x05quota\"W\n" +
+	"\x0eAcquireRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x1b\n" +
+	"\tclient_id\x18\x02 \x01(\tR\bclientId\x12\x16\n" +
+	"\x06tokens\x18\x03 \x01(\x03R\x06tokens\"|\n" +
+	"\x0fAcquireResponse\x12\x18\n" +
+	"\agranted\x18\x01 \x01(\x03R\agranted\x12!\n" +
+	"\flease_millis\x18\x02 \x01(\x03R\vleaseMillis\x12,\n" +
+	"\x12retry_after_millis\x18\x03 \x01(\x03R\x10retryAfterMillis2H\n" +
+	"\fQuotaService\x128\n" +
+	"\aAcquire\x12\x15.quota.AcquireRequest\x1a\x16.quota.AcquireResponseB\tZ\a.;protob\x06proto3"
+
+var (
+	file_quota_proto_rawDescOnce sync.Once
+	file_quota_proto_rawDescData []byte
+)
+
+func file_quota_proto_rawDescGZIP() []byte {
+	file_quota_proto_rawDescOnce.Do(func() {
+		file_quota_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_quota_proto_rawDesc), len(file_quota_proto_rawDesc)))
+	})
+	return file_quota_proto_rawDescData
+}
+
+var file_quota_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_quota_proto_goTypes = []any{
+	(*AcquireRequest)(nil),  // 0: quota.AcquireRequest
+	(*AcquireResponse)(nil), // 1: quota.AcquireResponse
+}
+var file_quota_proto_depIdxs = []int32{
+	0, // 0: quota.QuotaService.Acquire:input_type -> quota.AcquireRequest
+	1, // 1: quota.QuotaService.Acquire:output_type -> quota.AcquireResponse
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_quota_proto_init() }
+func file_quota_proto_init() {
+	if File_quota_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_quota_proto_rawDesc), len(file_quota_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_quota_proto_goTypes,
+		DependencyIndexes: file_quota_proto_depIdxs,
+		MessageInfos:      file_quota_proto_msgTypes,
+	}.Build()
+	File_quota_proto = out.File
+	file_quota_proto_goTypes = nil
+	file_quota_proto_depIdxs = nil
+}
@@ -0,0 +1,236 @@
+package quota
+
+import (
+	"context"
+	"simple_rpc_svc/internal/proto"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"limiter"
+)
+
+// 默认配置
+const (
+	defaultBatch        = 100
+	defaultTimeout      = time.Second
+	defaultRetryBackoff = time.Second
+	// 等待配额服务分配令牌时，重新检查的间隔
+	pollInterval = 10 * time.Millisecond
+)
+
+var _ limiter.Limiter = (*Limiter)(nil)
+
+// 配置函数
+type Option func(l *Limiter)
+
+// 设置客户端ID
+func WithClientID(id string) Option {
+	return func(l *Limiter) {
+		l.clientID = id
+	}
+}
+
+// 设置每次申请的令牌数
+func WithBatch(n int) Option {
+	return func(l *Limiter) {
+		if n > 0 {
+			l.batch = n
+		}
+	}
+}
+
+// 设置申请令牌的超时时间
+func WithTimeout(d time.Duration) Option {
+	return func(l *Limiter) {
+		if d > 0 {
+			l.timeout = d
+		}
+	}
+}
+
+// 设置配额服务不可用时使用的本地速率，应设置为比较保守的值，如 总配额/副本数
+func WithFallback(limit limiter.Limit, burst int) Option {
+	return func(l *Limiter) {
+		l.fallbackLimit, l.fallbackBurst = limit, burst
+	}
+}
+
+// 设置时钟，测试时可以使用limiter.ManualClock
+func WithClock(c limiter.Clock) Option {
+	return func(l *Limiter) {
+		if c != nil {
+			l.clock = c
+		}
+	}
+}
+
+// Limiter 使用配额服务的客户端限流器
+// 从配额服务申请一批令牌在本地使用，本地令牌低于一半时在后台申请下一批，不阻塞请求；
+// 配额服务不可用时，使用本地的令牌桶按保守的速率限流，直到配额服务恢复
+type Limiter struct {
+	client   proto.QuotaServiceClient
+	key      string
+	clientID string
+	batch    int
+	timeout  time.Duration
+	clock    limiter.Clock
+
+	fallbackLimit limiter.Limit
+	fallbackBurst int
+	fallback      *limiter.TokenBucket
+
+	mu        sync.Mutex // 所有修改 tokens、expires、degraded、refilling、retryAt的操作均在 mu锁保护下进行
+	tokens    int        // 本地剩余的令牌数
+	expires   time.Time  // 租约到期时间，到期后本地剩余的令牌作废
+	degraded  bool       // 配额服务不可用，使用本地速率
+	refilling bool       // 是否正在后台申请令牌
+	retryAt   time.Time  // 在该时间之前不再申请令牌
+}
+
+// NewLimiter 创建客户端限流器
+// key：配额的key，使用相同key的客户端共享同一份配额
+// 第一次从配额服务申请到令牌之前，使用本地速率
+func NewLimiter(conn grpc.ClientConnInterface, key string, opts ...Option) *Limiter {
+	l := &Limiter{
+		client:        proto.NewQuotaServiceClient(conn),
+		key:           key,
+		batch:         defaultBatch,
+		timeout:       defaultTimeout,
+		clock:         limiter.RealClock{},
+		fallbackLimit: limiter.Every(time.Second),
+		fallbackBurst: 1,
+		degraded:      true,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.fallback = limiter.New(l.fallbackLimit, l.fallbackBurst, limiter.WithClock(l.clock))
+	return l
+}
+
+// Allow 是否允许执行事件
+func (l *Limiter) Allow() bool {
+	return l.AllowN(l.clock.Now(), 1)
+}
+
+// AllowN 是否允许在时间t执行n个事件
+func (l *Limiter) AllowN(t time.Time, n int) bool {
+	l.mu.Lock()
+	if !t.Before(l.expires) {
+		// 租约已到期
+		l.tokens = 0
+	}
+	if l.tokens >= n {
+		l.tokens -= n
+		l.refillLocked(t)
+		l.mu.Unlock()
+		return true
+	}
+	degraded := l.degraded
+	l.refillLocked(t)
+	l.mu.Unlock()
+
+	if degraded {
+		return l.fallback.AllowN(t, n)
+	}
+	return false
+}
+
+// Wait 阻塞等待，直到允许执行事件
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		if l.Allow() {
+			return nil
+		}
+
+		l.mu.Lock()
+		degraded := l.degraded
+		delay := l.retryAt.Sub(l.clock.Now())
+		l.mu.Unlock()
+
+		if degraded {
+			return l.fallback.Wait(ctx)
+		}
+		if delay < pollInterval {
+			delay = pollInterval
+		}
+		timer := l.clock.NewTimer(delay)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Refill 向配额服务申请一批令牌，返回分配的令牌数
+// 通常不需要主动调用，本地令牌不足时会在后台申请；也可以在启动时调用，提前申请令牌
+func (l *Limiter) Refill(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	resp, err := l.client.Acquire(ctx, &proto.AcquireRequest{
+		Key:      l.key,
+		ClientId: l.clientID,
+		Tokens:   int64(l.batch),
+	})
+	now := l.clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		// 配额服务不可用，使用本地速率，一段时间后再重试
+		l.degraded = true
+		l.retryAt = now.Add(defaultRetryBackoff)
+		return 0, err
+	}
+	l.degraded = false
+
+	if resp.Granted == 0 {
+		// 配额不足
+		l.retryAt = now.Add(time.Duration(resp.RetryAfterMillis) * time.Millisecond)
+		return 0, nil
+	}
+	if !now.Before(l.expires) {
+		l.tokens = 0
+	}
+	// 本地剩余的令牌和新分配的令牌使用新的租约
+	l.tokens += int(resp.Granted)
+	l.expires = now.Add(time.Duration(resp.LeaseMillis) * time.Millisecond)
+	return int(resp.Granted), nil
+}
+
+// Tokens 本地剩余的令牌数
+func (l *Limiter) Tokens() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.clock.Now().Before(l.expires) {
+		return 0
+	}
+	return l.tokens
+}
+
+// Degraded 是否正在使用本地速率
+func (l *Limiter) Degraded() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.degraded
+}
+
+// refillLocked 本地令牌低于一半时，在后台申请下一批令牌，调用方需要持有锁
+func (l *Limiter) refillLocked(t time.Time) {
+	if l.refilling || l.tokens > l.batch/2 || t.Before(l.retryAt) {
+		return
+	}
+	l.refilling = true
+	go func() {
+		l.Refill(context.Background())
+
+		l.mu.Lock()
+		l.refilling = false
+		l.mu.Unlock()
+	}()
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"simple_rpc_svc/internal/config"
+	"simple_rpc_svc/internal/interceptor"
 	"simple_rpc_svc/internal/proto"
 	"simple_rpc_svc/internal/service"
 
@@ -19,24 +20,50 @@ type GRPCServer struct {
 }
 
 // NewGRPCServer 创建gRPC服务器实例
-func NewGRPCServer(cfg *config.Config) *GRPCServer {
+func NewGRPCServer(cfg *config.Config) (*GRPCServer, error) {
 	// 创建用户服务
 	userService := service.NewUserService()
 
+	// 限流拦截器，配额服务不限流
+	rateLimiter := interceptor.NewRateLimiter(cfg)
+	rateLimiter.Exempt(proto.QuotaService_ServiceDesc.ServiceName)
+	// 自适应并发限流拦截器
+	adaptive := interceptor.NewAdaptiveConcurrency(cfg)
+	// 过载保护拦截器，最先执行，过载时尽早拒绝
+	shedder := interceptor.NewLoadShedder(cfg)
+
 	// 创建gPRC服务器
 	grpcServer := grpc.NewServer(
 		grpc.Creds(insecure.NewCredentials()), // 开发环境使用不安全的凭据
+		grpc.ChainUnaryInterceptor(
+			shedder.UnaryServerInterceptor(),
+			rateLimiter.UnaryServerInterceptor(),
+			adaptive.UnaryServerInterceptor(),
+		),
+		grpc.ChainStreamInterceptor(
+			shedder.StreamServerInterceptor(),
+			rateLimiter.StreamServerInterceptor(),
+			adaptive.StreamServerInterceptor(),
+		),
 	)
 
 	// 注册服务
 	// userService 实现了GetUser函数，所以实现了UserServiceServer接口
 	proto.RegisterUserServiceServer(grpcServer, userService)
+	// 配额服务
+	if cfg.Quota.Enabled {
+		quotaService, err := service.NewQuotaService(cfg.Quota)
+		if err != nil {
+			return nil, err
+		}
+		proto.RegisterQuotaServiceServer(grpcServer, quotaService)
+	}
 
 	return &GRPCServer{
 		cfg:     cfg,
 		Service: userService,
 		Server:  grpcServer,
-	}
+	}, nil
 }
 
 // Start 启动服务
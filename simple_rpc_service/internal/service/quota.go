@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"simple_rpc_svc/internal/config"
+	"simple_rpc_svc/internal/proto"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"limiter"
+)
+
+// 默认配置
+const (
+	defaultQuotaMaxBatch = 100
+	defaultQuotaLease    = time.Second
+	defaultQuotaMaxKeys  = 10000
+)
+
+// QuotaService 配额服务
+// 每个key对应一个令牌桶，客户端每次申请一批令牌，在租约到期前在本地使用
+type QuotaService struct {
+	proto.UnimplementedQuotaServiceServer
+	buckets  *limiter.KeyedLimiter
+	maxBatch int64         // 每次最多分配的令牌数
+	lease    time.Duration // 租约时长
+}
+
+// NewQuotaService 创建配额服务
+// limit必须大于0：速率为0的桶不会生成令牌，每个key只能获取一次初始的令牌
+func NewQuotaService(cfg config.QuotaConfig) (*QuotaService, error) {
+	limit, burst, ok := limiter.BucketParams(cfg.Limit, cfg.Burst)
+	if !ok {
+		return nil, fmt.Errorf("配额服务的limit必须大于0，实际: %v", cfg.Limit)
+	}
+	maxBatch := int64(cfg.MaxBatch)
+	if maxBatch <= 0 {
+		maxBatch = defaultQuotaMaxBatch
+	}
+	lease := time.Duration(cfg.LeaseMillis) * time.Millisecond
+	if lease <= 0 {
+		lease = defaultQuotaLease
+	}
+	// key来自客户端，需要限制key的数量，避免内存无限增长
+	maxKeys := cfg.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = defaultQuotaMaxKeys
+	}
+	return &QuotaService{
+		buckets:  limiter.NewKeyed(limit, burst, limiter.WithMaxKeys(maxKeys)),
+		maxBatch: min(maxBatch, int64(burst)),
+		lease:    lease,
+	}, nil
+}
+
+// Acquire 申请一批令牌
+// 桶中的令牌不足时，分配桶中剩下的令牌，尽量满足客户端；一个令牌都没有时返回重试时间
+func (s *QuotaService) Acquire(ctx context.Context, req *proto.AcquireRequest) (*proto.AcquireResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key不能为空")
+	}
+	if req.Tokens <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "tokens必须大于0")
+	}
+
+	// 在同一把锁下计算可以分配的令牌数并扣减
+	granted, retryAfter := s.buckets.Get(req.Key).TakeUpTo(time.Now(), int(min(req.Tokens, s.maxBatch)))
+	if granted > 0 {
+		return &proto.AcquireResponse{
+			Granted:     int64(granted),
+			LeaseMillis: s.lease.Milliseconds(),
+		}, nil
+	}
+
+	return &proto.AcquireResponse{
+		RetryAfterMillis: int64(math.Ceil(float64(retryAfter) / float64(time.Millisecond))),
+	}, nil
+}
@@ -0,0 +1,169 @@
+package test
+
+import (
+	"context"
+	"simple_rpc_svc/internal/config"
+	"simple_rpc_svc/internal/interceptor"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 创建并发数范围为[min, max]的自适应并发限流拦截器
+func newAdaptiveConcurrency(initial, min, max int) *interceptor.AdaptiveConcurrency {
+	return interceptor.NewAdaptiveConcurrency(&config.Config{AdaptiveConcurrency: config.AdaptiveConcurrencyConfig{
+		Enabled:      true,
+		InitialLimit: initial,
+		MinLimit:     min,
+		MaxLimit:     max,
+	}})
+}
+
+// 通过unary拦截器调用handler
+func callAdaptive(ac *interceptor.AdaptiveConcurrency, ctx context.Context, handler grpc.UnaryHandler) error {
+	_, err := ac.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: getUserMethod}, handler)
+	return err
+}
+
+// 占用一个名额直到调用返回的函数，返回释放名额的函数
+func holdAdaptive(t *testing.T, ac *interceptor.AdaptiveConcurrency) (release func()) {
+	t.Helper()
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- callAdaptive(ac, context.Background(), func(ctx context.Context, req any) (any, error) {
+			close(started)
+			<-unblock
+			return nil, nil
+		})
+	}()
+	select {
+	case <-started:
+	case err := <-done:
+		t.Fatalf("占用名额的请求 err = %v, want nil", err)
+	}
+	return func() {
+		close(unblock)
+		if err := <-done; err != nil {
+			t.Errorf("占用名额的请求 err = %v, want nil", err)
+		}
+	}
+}
+
+// 在短超时内再发一个请求，返回错误
+func tryAdaptive(ac *interceptor.AdaptiveConcurrency) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	return callAdaptive(ac, ctx, func(ctx context.Context, req any) (any, error) { return nil, nil })
+}
+
+// 测试自适应并发限流拦截器 - 并发数已满时排队，等不到名额返回Unavailable
+func TestAdaptiveConcurrencyUnavailable(t *testing.T) {
+	ac := newAdaptiveConcurrency(1, 1, 1)
+
+	release := holdAdaptive(t, ac)
+	if err := tryAdaptive(ac); status.Code(err) != codes.Unavailable {
+		t.Fatalf("并发数已满时 err = %v, want Unavailable", err)
+	}
+	release()
+
+	if err := tryAdaptive(ac); err != nil {
+		t.Fatalf("名额释放后 err = %v, want nil", err)
+	}
+}
+
+// 测试自适应并发限流拦截器 - 并发数已满时拒绝新的stream
+func TestAdaptiveConcurrencyStream(t *testing.T) {
+	ac := newAdaptiveConcurrency(1, 1, 1)
+	info := &grpc.StreamServerInfo{FullMethod: getUserMethod}
+
+	release := holdAdaptive(t, ac)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := ac.StreamServerInterceptor()(nil, &fakeServerStream{ctx: ctx}, info, func(srv any, ss grpc.ServerStream) error {
+		return nil
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("并发数已满时 err = %v, want Unavailable", err)
+	}
+	release()
+
+	err = ac.StreamServerInterceptor()(nil, &fakeServerStream{ctx: context.Background()}, info, func(srv any, ss grpc.ServerStream) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("名额释放后 err = %v, want nil", err)
+	}
+}
+
+// 测试自适应并发限流拦截器 - 长时间的stream不占用名额，不影响并发数，unary请求仍然可以执行
+func TestAdaptiveConcurrencyLongStream(t *testing.T) {
+	ac := newAdaptiveConcurrency(1, 1, 2)
+	info := &grpc.StreamServerInfo{FullMethod: getUserMethod}
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- ac.StreamServerInterceptor()(nil, &fakeServerStream{ctx: context.Background()}, info, func(srv any, ss grpc.ServerStream) error {
+			close(started)
+			<-unblock
+			return nil
+		})
+	}()
+	<-started
+
+	// stream打开期间，unary请求仍然可以执行
+	for i := 0; i < 3; i++ {
+		if err := tryAdaptive(ac); err != nil {
+			t.Fatalf("stream打开期间第%d个unary请求 err = %v, want nil", i, err)
+		}
+	}
+	close(unblock)
+	if err := <-done; err != nil {
+		t.Fatalf("stream err = %v, want nil", err)
+	}
+
+	// stream的持续时间不作为延迟样本，并发数没有减小：仍然可以同时处理两个请求
+	release := holdAdaptive(t, ac)
+	defer release()
+	if err := tryAdaptive(ac); err != nil {
+		t.Errorf("stream结束后第二个并发请求 err = %v, want nil", err)
+	}
+}
+
+// 测试自适应并发限流拦截器 - 只有过载导致的错误才减小并发数
+func TestAdaptiveConcurrencyOverloadErrors(t *testing.T) {
+	tests := []struct {
+		code   codes.Code
+		reduce bool
+	}{
+		{codes.Unavailable, true},
+		{codes.DeadlineExceeded, true},
+		{codes.ResourceExhausted, true},
+		{codes.Internal, false},
+		{codes.Unknown, false},
+		{codes.NotFound, false},
+		{codes.InvalidArgument, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			ac := newAdaptiveConcurrency(2, 1, 2)
+			callAdaptive(ac, context.Background(), func(ctx context.Context, req any) (any, error) {
+				return nil, status.Error(tt.code, tt.code.String())
+			})
+
+			// 并发数为2时可以同时处理两个请求，减小为1之后第二个请求被拒绝
+			release := holdAdaptive(t, ac)
+			defer release()
+			err := tryAdaptive(ac)
+			if reduced := status.Code(err) == codes.Unavailable; reduced != tt.reduce {
+				t.Errorf("%v 之后第二个并发请求 err = %v, 并发数减小 = %v, want %v", tt.code, err, reduced, tt.reduce)
+			}
+		})
+	}
+}
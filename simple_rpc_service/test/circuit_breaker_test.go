@@ -0,0 +1,133 @@
+package test
+
+import (
+	"context"
+	"net"
+	"simple_rpc_svc/internal/config"
+	"simple_rpc_svc/internal/interceptor"
+	"simple_rpc_svc/internal/proto"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"breaker"
+)
+
+// 返回指定错误码的用户服务，记录收到的请求数
+type flakyUserService struct {
+	proto.UnimplementedUserServiceServer
+	code  atomic.Uint32
+	calls atomic.Int64
+}
+
+func (s *flakyUserService) GetUser(ctx context.Context, req *proto.GetUserRequest) (*proto.GetUserResponse, error) {
+	s.calls.Add(1)
+	if code := codes.Code(s.code.Load()); code != codes.OK {
+		return nil, status.Error(code, code.String())
+	}
+	return &proto.GetUserResponse{}, nil
+}
+
+// 启动进程内的用户服务，返回使用熔断拦截器的客户端
+func startBreakerClient(t *testing.T, svc proto.UserServiceServer, cb *interceptor.CircuitBreaker) proto.UserServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	proto.RegisterUserServiceServer(srv, svc)
+	go srv.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(cb.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatalf("连接用户服务失败，err:%v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+	})
+	return proto.NewUserServiceClient(conn)
+}
+
+// 测试熔断拦截器 - 连续失败后熔断，不再发送请求；业务错误不计为失败
+func TestCircuitBreakerInterceptor(t *testing.T) {
+	var mu sync.Mutex
+	var changes []string
+	cb := interceptor.NewCircuitBreaker(&config.Config{
+		CircuitBreaker: config.CircuitBreakerConfig{
+			Enabled:             true,
+			ConsecutiveFailures: 3,
+			CoolDownSeconds:     60,
+		},
+	}, func(method string, from, to breaker.State) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, method+" "+from.String()+"->"+to.String())
+	})
+
+	svc := &flakyUserService{}
+	client := startBreakerClient(t, svc, cb)
+	ctx := context.Background()
+
+	// 业务错误、下游的限流说明下游正常
+	svc.code.Store(uint32(codes.InvalidArgument))
+	for range 3 {
+		client.GetUser(ctx, &proto.GetUserRequest{})
+	}
+	svc.code.Store(uint32(codes.ResourceExhausted))
+	for range 5 {
+		client.GetUser(ctx, &proto.GetUserRequest{})
+	}
+
+	svc.code.Store(uint32(codes.Unavailable))
+	for range 3 {
+		client.GetUser(ctx, &proto.GetUserRequest{})
+	}
+	if got := svc.calls.Load(); got != 11 {
+		t.Fatalf("预期服务端收到11个请求，实际: %d", got)
+	}
+
+	_, err := client.GetUser(ctx, &proto.GetUserRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("熔断后预期返回Unavailable，实际: %v", err)
+	}
+	if got := svc.calls.Load(); got != 11 {
+		t.Errorf("熔断后不应该发送请求，服务端收到 %d 个请求", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := "/user.UserService/GetUser closed->open"
+	if len(changes) != 1 || changes[0] != want {
+		t.Errorf("预期状态变化 [%s]，实际: %v", want, changes)
+	}
+}
+
+// 测试熔断拦截器 - 未启用时不熔断
+func TestCircuitBreakerDisabled(t *testing.T) {
+	cb := interceptor.NewCircuitBreaker(&config.Config{
+		CircuitBreaker: config.CircuitBreakerConfig{ConsecutiveFailures: 1},
+	}, nil)
+
+	svc := &flakyUserService{}
+	svc.code.Store(uint32(codes.Unavailable))
+	client := startBreakerClient(t, svc, cb)
+
+	for range 5 {
+		client.GetUser(context.Background(), &proto.GetUserRequest{})
+	}
+	if got := svc.calls.Load(); got != 5 {
+		t.Errorf("未启用熔断时预期服务端收到5个请求，实际: %d", got)
+	}
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"context"
+	"simple_rpc_svc/internal/config"
+	"simple_rpc_svc/internal/interceptor"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"limiter/bbr"
+)
+
+// 创建CPU使用率固定超过阈值的过载保护拦截器，没有历史数据时只允许1个并发
+func newOverloadedShedder() *interceptor.LoadShedder {
+	return interceptor.NewLoadShedder(&config.Config{LoadShedding: config.LoadSheddingConfig{
+		Enabled:       true,
+		CPUThreshold:  800,
+		WindowSeconds: 1,
+		Buckets:       10,
+	}}, bbr.WithCPU(func() int64 { return 900 }))
+}
+
+// 测试过载保护拦截器 - 过载时unary请求返回Unavailable
+func TestLoadSheddingUnary(t *testing.T) {
+	ls := newOverloadedShedder()
+	info := &grpc.UnaryServerInfo{FullMethod: getUserMethod}
+
+	var inner error
+	_, err := ls.UnaryServerInterceptor()(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		// 正在处理1个请求，再来的请求被丢弃
+		_, inner = ls.UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			return nil, nil
+		})
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("第1个请求 err = %v, want nil", err)
+	}
+	if status.Code(inner) != codes.Unavailable {
+		t.Fatalf("过载时 err = %v, want Unavailable", inner)
+	}
+
+	// 请求完成后恢复
+	_, err = ls.UnaryServerInterceptor()(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("请求完成后 err = %v, want nil", err)
+	}
+}
+
+// 测试过载保护拦截器 - 过载时建立stream返回Unavailable，stream不占用并发数
+func TestLoadSheddingStream(t *testing.T) {
+	ls := newOverloadedShedder()
+	unaryInfo := &grpc.UnaryServerInfo{FullMethod: getUserMethod}
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/user.UserService/Watch"}
+	ss := &fakeServerStream{ctx: context.Background()}
+
+	// stream进行中时，unary请求不受影响
+	err := ls.StreamServerInterceptor()(nil, ss, streamInfo, func(srv any, ss grpc.ServerStream) error {
+		_, err := ls.UnaryServerInterceptor()(context.Background(), nil, unaryInfo, func(ctx context.Context, req any) (any, error) {
+			return nil, nil
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("stream进行中的unary请求 err = %v, want nil", err)
+	}
+
+	// unary请求进行中时已经过载，建立stream被拒绝
+	var inner error
+	_, err = ls.UnaryServerInterceptor()(context.Background(), nil, unaryInfo, func(ctx context.Context, req any) (any, error) {
+		inner = ls.StreamServerInterceptor()(nil, ss, streamInfo, func(srv any, ss grpc.ServerStream) error {
+			return nil
+		})
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("unary请求 err = %v, want nil", err)
+	}
+	if status.Code(inner) != codes.Unavailable {
+		t.Fatalf("过载时建立stream err = %v, want Unavailable", inner)
+	}
+}
+
+// 测试过载保护拦截器 - 未启用时不丢弃请求
+func TestLoadSheddingDisabled(t *testing.T) {
+	ls := interceptor.NewLoadShedder(&config.Config{}, bbr.WithCPU(func() int64 { return 1000 }))
+	info := &grpc.UnaryServerInfo{FullMethod: getUserMethod}
+
+	var inner error
+	ls.UnaryServerInterceptor()(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		_, inner = ls.UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			return nil, nil
+		})
+		return nil, nil
+	})
+	if inner != nil {
+		t.Fatalf("未启用时 err = %v, want nil", inner)
+	}
+}
@@ -0,0 +1,226 @@
+package test
+
+import (
+	"context"
+	"net"
+	"simple_rpc_svc/internal/config"
+	"simple_rpc_svc/internal/proto"
+	"simple_rpc_svc/internal/quota"
+	"simple_rpc_svc/internal/service"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"limiter"
+)
+
+// 创建配额服务
+func newQuotaService(t *testing.T, cfg config.QuotaConfig) *service.QuotaService {
+	t.Helper()
+	svc, err := service.NewQuotaService(cfg)
+	if err != nil {
+		t.Fatalf("创建配额服务失败，err:%v", err)
+	}
+	return svc
+}
+
+// 启动进程内的配额服务，返回客户端连接和停止服务的函数
+func startQuotaServer(t *testing.T, cfg config.QuotaConfig) (*grpc.ClientConn, func()) {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	proto.RegisterQuotaServiceServer(srv, newQuotaService(t, cfg))
+	go srv.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("连接配额服务失败，err:%v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+	})
+	return conn, srv.Stop
+}
+
+// 测试配额服务 - 配额不足时分配桶中剩下的所有令牌
+func TestQuotaServicePartialGrant(t *testing.T) {
+	svc := newQuotaService(t, config.QuotaConfig{
+		RateLimitRule: config.RateLimitRule{Limit: 0.001, Burst: 13},
+		MaxBatch:      10,
+		LeaseMillis:   1000,
+	})
+	ctx := context.Background()
+	req := &proto.AcquireRequest{Key: "api", Tokens: 10}
+
+	for i, want := range []int64{10, 3, 0} {
+		resp, err := svc.Acquire(ctx, req)
+		if err != nil {
+			t.Fatalf("第%d次申请失败，err:%v", i, err)
+		}
+		if resp.Granted != want {
+			t.Errorf("第%d次申请 预期分配 %d，实际 %d", i, want, resp.Granted)
+		}
+		if want > 0 && resp.LeaseMillis != 1000 {
+			t.Errorf("预期租约1000毫秒，实际: %d", resp.LeaseMillis)
+		}
+		if want == 0 && resp.RetryAfterMillis <= 0 {
+			t.Errorf("配额不足时预期返回重试时间，实际: %d", resp.RetryAfterMillis)
+		}
+	}
+
+	if _, err := svc.Acquire(ctx, &proto.AcquireRequest{Tokens: 1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("key为空时预期返回 %v，实际: %v", codes.InvalidArgument, err)
+	}
+}
+
+// 测试配额服务 - limit不大于0时桶不会生成令牌，创建时返回错误
+func TestQuotaServiceInvalidLimit(t *testing.T) {
+	for _, limit := range []float64{0, -1} {
+		_, err := service.NewQuotaService(config.QuotaConfig{RateLimitRule: config.RateLimitRule{Limit: limit, Burst: 10}})
+		if err == nil {
+			t.Errorf("limit=%v 预期返回错误", limit)
+		}
+	}
+}
+
+// 测试配额服务 - 多个客户端共享同一份配额
+func TestQuotaSharedAcrossClients(t *testing.T) {
+	conn, _ := startQuotaServer(t, config.QuotaConfig{
+		RateLimitRule: config.RateLimitRule{Limit: 0.001, Burst: 100},
+		MaxBatch:      10,
+		LeaseMillis:   60000,
+	})
+
+	clients := []*quota.Limiter{
+		quota.NewLimiter(conn, "api", quota.WithClientID("c1"), quota.WithBatch(10)),
+		quota.NewLimiter(conn, "api", quota.WithClientID("c2"), quota.WithBatch(10)),
+		quota.NewLimiter(conn, "api", quota.WithClientID("c3"), quota.WithBatch(10)),
+	}
+
+	allowed := make([]int, len(clients))
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				// 先申请一批令牌，避免使用本地速率
+				if allowed[i] == 0 {
+					if _, err := c.Refill(context.Background()); err != nil {
+						t.Errorf("申请令牌失败，err:%v", err)
+						return
+					}
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+				err := c.Wait(ctx)
+				cancel()
+				if err != nil {
+					// 配额已用完
+					return
+				}
+				allowed[i]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i, c := range clients {
+		if allowed[i] == 0 {
+			t.Errorf("客户端%d预期分到配额", i)
+		}
+		// 本地剩余的令牌也来自同一份配额
+		total += allowed[i] + c.Tokens()
+	}
+	if total != 100 {
+		t.Errorf("所有客户端预期共享100个令牌，实际: %d，各客户端: %v", total, allowed)
+	}
+}
+
+// 测试配额服务 - 配额服务不可用时使用本地速率
+func TestQuotaFallback(t *testing.T) {
+	conn, stop := startQuotaServer(t, config.QuotaConfig{
+		RateLimitRule: config.RateLimitRule{Limit: 100, Burst: 100},
+	})
+	clock := limiter.NewManualClock(time.Now())
+	c := quota.NewLimiter(conn, "api",
+		quota.WithBatch(10),
+		quota.WithFallback(limiter.Every(time.Second), 2),
+		quota.WithClock(clock),
+	)
+
+	if _, err := c.Refill(context.Background()); err != nil {
+		t.Fatalf("申请令牌失败，err:%v", err)
+	}
+	if c.Degraded() {
+		t.Fatal("配额服务可用时预期不使用本地速率")
+	}
+
+	// 配额服务不可用
+	stop()
+	if _, err := c.Refill(context.Background()); err == nil {
+		t.Fatal("配额服务已停止，预期申请失败")
+	}
+	if !c.Degraded() {
+		t.Fatal("配额服务不可用时预期使用本地速率")
+	}
+
+	// 租约内的令牌仍然可以使用
+	for i := 0; i < 10; i++ {
+		if !c.Allow() {
+			t.Fatalf("第%d次请求 预期使用本地剩余的令牌", i)
+		}
+	}
+
+	// 本地令牌用完后按本地速率限流：容量2，每秒1个
+	const T, F = true, false
+	got := []bool{c.Allow(), c.Allow(), c.Allow()}
+	for i, want := range []bool{T, T, F} {
+		if got[i] != want {
+			t.Errorf("本地速率 第%d次请求 预期 %v，实际 %v", i, want, got[i])
+		}
+	}
+	clock.Advance(time.Second)
+	if !c.Allow() {
+		t.Error("1秒后预期按本地速率允许执行")
+	}
+}
+
+// 测试配额服务 - 租约到期后本地剩余的令牌作废
+func TestQuotaLeaseExpires(t *testing.T) {
+	conn, _ := startQuotaServer(t, config.QuotaConfig{
+		RateLimitRule: config.RateLimitRule{Limit: 0.001, Burst: 10},
+		LeaseMillis:   1000,
+	})
+	clock := limiter.NewManualClock(time.Now())
+	c := quota.NewLimiter(conn, "api", quota.WithBatch(10), quota.WithClock(clock))
+
+	if granted, err := c.Refill(context.Background()); err != nil || granted != 10 {
+		t.Fatalf("预期分配10个令牌，实际: %d，err:%v", granted, err)
+	}
+	c.Allow()
+	if tokens := c.Tokens(); tokens != 9 {
+		t.Errorf("预期本地剩余9个令牌，实际: %d", tokens)
+	}
+
+	clock.Advance(time.Second)
+	if tokens := c.Tokens(); tokens != 0 {
+		t.Errorf("租约到期后预期令牌作废，实际: %d", tokens)
+	}
+	if c.Allow() {
+		t.Error("租约到期且配额已用完，预期拒绝")
+	}
+}
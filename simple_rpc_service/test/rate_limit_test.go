@@ -0,0 +1,189 @@
+package test
+
+import (
+	"context"
+	"simple_rpc_svc/internal/config"
+	"simple_rpc_svc/internal/interceptor"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const getUserMethod = "/user.UserService/GetUser"
+
+// 只实现Context的ServerStream，用于直接调用stream拦截器
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// 令牌几乎不恢复的限流规则，测试过程中只能使用桶中的令牌
+func rateLimitRule(burst int) config.RateLimitRule {
+	return config.RateLimitRule{Limit: 0.001, Burst: burst}
+}
+
+// 携带调用方标识的ctx
+func callerContext(id string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-client-id", id))
+}
+
+// 通过unary拦截器调用一次方法
+func callUnary(rl *interceptor.RateLimiter, ctx context.Context, method string) error {
+	_, err := rl.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req any) (any, error) { return nil, nil })
+	return err
+}
+
+// 检查限流错误：ResourceExhausted，并在RetryInfo中携带重试时间
+func assertResourceExhausted(t *testing.T, err error) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.ResourceExhausted {
+		t.Fatalf("err = %v, want ResourceExhausted", err)
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			if delay := info.GetRetryDelay().AsDuration(); delay <= 0 {
+				t.Errorf("RetryInfo.RetryDelay = %v, want >0", delay)
+			}
+			return
+		}
+	}
+	t.Errorf("限流错误没有携带RetryInfo，details = %v", st.Details())
+}
+
+// 测试限流拦截器 - unary请求超过方法的限流规则时返回ResourceExhausted
+func TestRateLimitUnary(t *testing.T) {
+	rl := interceptor.NewRateLimiter(&config.Config{RateLimit: config.RateLimitConfig{
+		Enabled:   true,
+		PerMethod: rateLimitRule(2),
+	}})
+
+	for i := 0; i < 2; i++ {
+		if err := callUnary(rl, context.Background(), getUserMethod); err != nil {
+			t.Fatalf("第%d个请求 err = %v, want nil", i, err)
+		}
+	}
+	assertResourceExhausted(t, callUnary(rl, context.Background(), getUserMethod))
+
+	// 每个方法一个令牌桶
+	if err := callUnary(rl, context.Background(), "/user.UserService/ListUsers"); err != nil {
+		t.Fatalf("其他方法 err = %v, want nil", err)
+	}
+}
+
+// 测试限流拦截器 - stream和unary共享同一份配额，建立stream时获取令牌
+func TestRateLimitStream(t *testing.T) {
+	rl := interceptor.NewRateLimiter(&config.Config{RateLimit: config.RateLimitConfig{
+		Enabled:   true,
+		PerMethod: rateLimitRule(1),
+	}})
+
+	calls := 0
+	handler := func(srv any, ss grpc.ServerStream) error {
+		calls++
+		return nil
+	}
+	ss := &fakeServerStream{ctx: context.Background()}
+	info := &grpc.StreamServerInfo{FullMethod: getUserMethod}
+	if err := rl.StreamServerInterceptor()(nil, ss, info, handler); err != nil {
+		t.Fatalf("第1个stream err = %v, want nil", err)
+	}
+	assertResourceExhausted(t, rl.StreamServerInterceptor()(nil, ss, info, handler))
+	assertResourceExhausted(t, callUnary(rl, context.Background(), getUserMethod))
+	if calls != 1 {
+		t.Errorf("handler被调用%d次, want 1", calls)
+	}
+}
+
+// 测试限流拦截器 - 指定方法的规则覆盖per_method，按调用方限流时每个调用方独立计数
+func TestRateLimitMethodAndCaller(t *testing.T) {
+	rl := interceptor.NewRateLimiter(&config.Config{RateLimit: config.RateLimitConfig{
+		Enabled:   true,
+		PerMethod: rateLimitRule(100),
+		Methods:   []config.MethodLimitRule{{Method: getUserMethod, RateLimitRule: rateLimitRule(3)}},
+		PerCaller: config.CallerLimitRule{MetadataKey: "x-client-id", RateLimitRule: rateLimitRule(1)},
+	}})
+
+	if err := callUnary(rl, callerContext("a"), getUserMethod); err != nil {
+		t.Fatalf("调用方a err = %v, want nil", err)
+	}
+	// 调用方a被按调用方的规则拒绝，不消耗方法的令牌
+	for i := 0; i < 5; i++ {
+		assertResourceExhausted(t, callUnary(rl, callerContext("a"), getUserMethod))
+	}
+	if err := callUnary(rl, callerContext("b"), getUserMethod); err != nil {
+		t.Fatalf("调用方b err = %v, want nil", err)
+	}
+	if err := callUnary(rl, callerContext("c"), getUserMethod); err != nil {
+		t.Fatalf("调用方c err = %v, want nil", err)
+	}
+	// 方法的3个令牌已用完
+	assertResourceExhausted(t, callUnary(rl, callerContext("d"), getUserMethod))
+}
+
+// 测试限流拦截器 - RetryInfo中的重试时间为令牌恢复需要的时间
+func TestRateLimitRetryDelay(t *testing.T) {
+	rl := interceptor.NewRateLimiter(&config.Config{RateLimit: config.RateLimitConfig{
+		Enabled:   true,
+		PerMethod: config.RateLimitRule{Limit: 1, Burst: 1},
+	}})
+
+	if err := callUnary(rl, context.Background(), getUserMethod); err != nil {
+		t.Fatalf("err = %v, want nil", err)
+	}
+	err := callUnary(rl, context.Background(), getUserMethod)
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			if delay := info.GetRetryDelay().AsDuration(); delay <= 0 || delay > time.Second {
+				t.Errorf("RetryInfo.RetryDelay = %v, want (0, 1s]", delay)
+			}
+			return
+		}
+	}
+	t.Fatalf("err = %v, 没有携带RetryInfo", err)
+}
+
+// 测试限流拦截器 - 未启用时不限流
+func TestRateLimitDisabled(t *testing.T) {
+	rl := interceptor.NewRateLimiter(&config.Config{RateLimit: config.RateLimitConfig{
+		Enabled:   false,
+		PerMethod: rateLimitRule(1),
+	}})
+
+	for i := 0; i < 3; i++ {
+		if err := callUnary(rl, context.Background(), getUserMethod); err != nil {
+			t.Fatalf("第%d个请求 err = %v, want nil", i, err)
+		}
+	}
+}
+
+// 测试限流拦截器 - 不对豁免的服务限流
+func TestRateLimitExempt(t *testing.T) {
+	rl := interceptor.NewRateLimiter(&config.Config{RateLimit: config.RateLimitConfig{
+		Enabled:   true,
+		PerMethod: rateLimitRule(1),
+		PerCaller: config.CallerLimitRule{MetadataKey: "x-client-id", RateLimitRule: rateLimitRule(1)},
+	}})
+	rl.Exempt("quota.QuotaService")
+
+	for i := 0; i < 3; i++ {
+		if err := callUnary(rl, callerContext("a"), "/quota.QuotaService/Acquire"); err != nil {
+			t.Fatalf("第%d个配额请求 err = %v, want nil", i, err)
+		}
+	}
+	// 豁免的请求不消耗调用方的令牌
+	if err := callUnary(rl, callerContext("a"), getUserMethod); err != nil {
+		t.Fatalf("err = %v, want nil", err)
+	}
+	assertResourceExhausted(t, callUnary(rl, callerContext("a"), getUserMethod))
+}
@@ -0,0 +1,118 @@
+package workerpool
+
+import (
+	"context"
+	"sync"
+)
+
+// Future 任务的执行结果
+// 任务执行完、被取消、没有执行就被丢弃时完成，完成之后结果不再变化
+type Future[T any] struct {
+	done chan struct{} // 完成时关闭
+	once sync.Once
+	val  T
+	err  error
+
+	mu       sync.Mutex         // 保护 canceled、cancel
+	canceled bool               // 已调用Cancel
+	cancel   context.CancelFunc // 任务开始执行之后设置，取消任务的ctx
+}
+
+// Submit 添加有返回值的任务，通过返回的Future获取结果
+// 和AddTask一样，队列已满时按WithOverflowPolicy处理：默认不阻塞，返回ErrQueueFull；
+// OverflowBlock时阻塞等待队列空位，OverflowCallerRuns时在调用方的协程中执行完任务才返回
+// 添加失败时（队列已满、任务池已关闭或已出错），返回的Future已经完成，Get返回添加时的错误；
+// 任务panic时，Get返回*PanicError；任务的错误同样会报告给任务池
+func Submit[T any](p *WorkerPool, fn func(ctx context.Context) (T, error)) *Future[T] {
+	f := &Future[T]{done: make(chan struct{})}
+	if err := p.AddTask(&futureTask[T]{future: f, fn: fn}); err != nil {
+		f.fail(err)
+	}
+	return f
+}
+
+// Get 等待任务完成，返回任务的结果；ctx被取消时返回ctx的错误，不影响任务的执行
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Done 任务完成时关闭
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Cancel 取消任务，Future立即完成，Get返回context.Canceled
+// 任务还没有开始执行时不再执行；正在执行时取消任务的ctx，任务的错误不再报告给任务池。任务已经完成时什么都不做
+func (f *Future[T]) Cancel() {
+	f.mu.Lock()
+	f.canceled = true
+	cancel := f.cancel
+	f.mu.Unlock()
+
+	// 先完成Future再取消任务的ctx，任务因为取消返回的错误不会报告给任务池
+	f.fail(context.Canceled)
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// 设置结果，只有第一次生效，返回是否生效
+func (f *Future[T]) complete(val T, err error) bool {
+	ok := false
+	f.once.Do(func() {
+		f.val, f.err = val, err
+		close(f.done)
+		ok = true
+	})
+	return ok
+}
+
+func (f *Future[T]) fail(err error) bool {
+	var zero T
+	return f.complete(zero, err)
+}
+
+// 有返回值的任务，结果写入Future
+type futureTask[T any] struct {
+	future *Future[T]
+	fn     func(ctx context.Context) (T, error)
+}
+
+func (t *futureTask[T]) Run(ctx context.Context) (err error) {
+	f := t.future
+	f.mu.Lock()
+	if f.canceled {
+		// 开始执行之前已取消
+		f.mu.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	f.cancel = cancel
+	f.mu.Unlock()
+	defer cancel()
+
+	var val T
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r)
+		}
+		if !f.complete(val, err) {
+			// 执行过程中已取消，错误不再报告给任务池
+			err = nil
+		}
+	}()
+
+	val, err = t.fn(ctx)
+	return err
+}
+
+// 任务没有执行就被丢弃
+func (t *futureTask[T]) drop(err error) {
+	t.future.fail(err)
+}
@@ -0,0 +1,201 @@
+package workerpool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 默认配置
+const (
+	defaultMaxWorkerCount = 5
+	defaultQueueSize      = 100
+	defaultIdleTimeout    = 10 * time.Second
+)
+
+// 关闭任务池时如何处理队列中的任务
+type Mode int
+
+const (
+	// 关闭后不再接收新任务，队列中的任务执行完之后再退出；出错时才取消所有任务（v2版本的行为，默认）
+	ModeDrain Mode = iota
+	// 关闭时立即取消所有任务，队列中还没有执行的任务被丢弃（v1版本的行为）
+	ModeImmediate
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeDrain:
+		return "drain"
+	case ModeImmediate:
+		return "immediate"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// 队列已满时AddTask的处理方式
+type OverflowPolicy int
+
+const (
+	// 直接返回ErrQueueFull（默认）
+	OverflowReject OverflowPolicy = iota
+	// 阻塞等待，直到队列有空位或者任务池关闭
+	OverflowBlock
+	// 在调用方的协程中直接执行任务，调用方变慢，生产速度自然降下来
+	OverflowCallerRuns
+	// 丢弃队列中最早的任务，被丢弃的任务不再执行（Future返回ErrDropped）
+	OverflowDropOldest
+)
+
+func (o OverflowPolicy) String() string {
+	switch o {
+	case OverflowReject:
+		return "reject"
+	case OverflowBlock:
+		return "block"
+	case OverflowCallerRuns:
+		return "caller-runs"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(o))
+	}
+}
+
+// 配置函数，在任务池启动之前生效
+type Option func(o *options) error
+
+// 配置
+type options struct {
+	queueSize       int             // 队列的容量
+	maxWorkerCount  int             // 最大woker数量
+	minWorkerCount  int             // 最小woker数量，0表示和maxWorkerCount相同（固定大小）
+	idleTimeout     time.Duration   // minWorkerCount之外的worker空闲多久后退出
+	mode            Mode            // 关闭模式
+	overflow        OverflowPolicy  // 队列已满时AddTask的处理方式
+	continueOnError bool            // 任务失败时是否继续执行其他任务
+	maxFailures     int             // continueOnError时失败的任务数上限，0表示不限制
+	parent          context.Context // 任务池的父context，取消时停止任务池
+}
+
+func newOptions(opts ...Option) (*options, error) {
+	o := &options{
+		queueSize:      defaultQueueSize,
+		maxWorkerCount: defaultMaxWorkerCount,
+		idleTimeout:    defaultIdleTimeout,
+		mode:           ModeDrain,
+		overflow:       OverflowReject,
+		parent:         context.Background(),
+	}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	if o.minWorkerCount == 0 {
+		o.minWorkerCount = o.maxWorkerCount
+	}
+	if o.minWorkerCount > o.maxWorkerCount {
+		return nil, errors.New("MinWorkerCount 不能大于MaxWorkerCount")
+	}
+	return o, nil
+}
+
+// 设置队列的容量，默认100
+func WithQueueSize(size int) Option {
+	return func(o *options) error {
+		if size < 1 {
+			return errors.New("QueueSize 不能小于1")
+		}
+		o.queueSize = size
+		return nil
+	}
+}
+
+// 设置最大woker数量，默认5
+func WithMaxWorkerCount(count int) Option {
+	return func(o *options) error {
+		if count < 1 {
+			return errors.New("MaxWorkerCount 不能小于1")
+		}
+		o.maxWorkerCount = count
+		return nil
+	}
+}
+
+// 设置最小woker数量，默认和MaxWorkerCount相同，即固定大小的任务池
+// 小于MaxWorkerCount时，启动时只有count个worker，队列中有任务积压时增加worker，
+// 多出的worker空闲WithIdleTimeout之后退出
+func WithMinWorkerCount(count int) Option {
+	return func(o *options) error {
+		if count < 1 {
+			return errors.New("MinWorkerCount 不能小于1")
+		}
+		o.minWorkerCount = count
+		return nil
+	}
+}
+
+// 设置空闲的worker多久之后退出，只对MinWorkerCount之外的worker生效，默认10s
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *options) error {
+		if d <= 0 {
+			return errors.New("IdleTimeout 必须大于0")
+		}
+		o.idleTimeout = d
+		return nil
+	}
+}
+
+// 设置关闭模式，默认ModeDrain
+func WithMode(mode Mode) Option {
+	return func(o *options) error {
+		if mode != ModeDrain && mode != ModeImmediate {
+			return errors.Errorf("不支持的Mode：%d", int(mode))
+		}
+		o.mode = mode
+		return nil
+	}
+}
+
+// 设置队列已满时AddTask、AddTaskFunc、Submit的处理方式，默认OverflowReject
+// TrySubmit、SubmitWait不受影响
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(o *options) error {
+		if policy < OverflowReject || policy > OverflowDropOldest {
+			return errors.Errorf("不支持的OverflowPolicy：%d", int(policy))
+		}
+		o.overflow = policy
+		return nil
+	}
+}
+
+// 任务失败时继续执行其他任务，WaitAndClose返回所有失败任务的错误，适合需要每个任务都尝试一次的批量任务
+// maxFailures为失败的任务数上限，达到上限时和默认行为一样停止任务池、取消所有任务；为0时不限制
+func WithContinueOnError(maxFailures int) Option {
+	return func(o *options) error {
+		if maxFailures < 0 {
+			return errors.New("maxFailures 不能小于0")
+		}
+		o.continueOnError = true
+		o.maxFailures = maxFailures
+		return nil
+	}
+}
+
+// 设置任务池的父context，默认context.Background()
+// 任务的ctx携带parent的值；parent被取消时和任务出错一样停止任务池：取消所有任务，丢弃队列中的任务，
+// 之后添加任务返回parent的错误，WaitAndClose同样返回parent的错误。
+// parent应该和任务池的生命周期一致（如服务的ctx），不要使用请求的ctx
+func WithContext(parent context.Context) Option {
+	return func(o *options) error {
+		if parent == nil {
+			return errors.New("parent context 不能为nil")
+		}
+		o.parent = parent
+		return nil
+	}
+}
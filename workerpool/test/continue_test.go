@@ -0,0 +1,130 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"testing"
+
+	"workerpool"
+)
+
+// 收集错误中所有TaskError的ID
+func taskErrorIDs(err error) []string {
+	var ids []string
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			var te *workerpool.TaskError
+			if errors.As(e, &te) {
+				ids = append(ids, te.ID)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// 测试WithContinueOnError - 任务失败后继续执行其他任务，返回所有失败任务的错误
+func TestContinueOnError(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithContinueOnError(0))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	errInvalid := errors.New("数据格式错误")
+	for i := 1; i <= 10; i++ {
+		err := pool.AddTask(workerpool.NamedTask("第"+strconv.Itoa(i)+"行", func(ctx context.Context) error {
+			if ctx.Err() != nil {
+				t.Error("WithContinueOnError时任务失败不应该取消其他任务")
+			}
+			if i%4 == 0 {
+				return errInvalid
+			}
+			return nil
+		}))
+		if err != nil {
+			t.Fatalf("任务失败后预期可以继续添加任务，err:%v", err)
+		}
+	}
+
+	err = pool.WaitAndClose()
+	if !errors.Is(err, errInvalid) {
+		t.Fatalf("预期返回任务的错误，实际: %v", err)
+	}
+	if got, want := taskErrorIDs(err), []string{"第4行", "第8行"}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("预期失败的任务 %v，实际: %v", want, got)
+	}
+	if pool.GetFirstError() != errInvalid {
+		t.Errorf("预期GetFirstError返回 %v，实际: %v", errInvalid, pool.GetFirstError())
+	}
+	want := workerpool.Stats{Added: 10, Executed: 10, Failed: 2}
+	if got := pool.Stats(); got != want {
+		t.Errorf("预期统计信息 %+v，实际: %+v", want, got)
+	}
+}
+
+// 测试WithContinueOnError - 没有标识的任务按添加的顺序标识，panic同样记录
+func TestContinueOnErrorDefaultID(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithContinueOnError(0))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	pool.AddTaskFunc(func(ctx context.Context) error { return nil })
+	pool.AddTaskFunc(func(ctx context.Context) error { panic("任务内部发生panic") })
+	pool.AddTaskFunc(func(ctx context.Context) error { return errors.New("故意出错的任务") })
+
+	err = pool.WaitAndClose()
+	var pe *workerpool.PanicError
+	if !errors.As(err, &pe) {
+		t.Errorf("预期包含*PanicError，实际: %v", err)
+	}
+	if got := taskErrorIDs(err); len(got) != 2 || got[0] != "#2" || got[1] != "#3" {
+		t.Errorf("预期失败的任务 [#2 #3]，实际: %v", got)
+	}
+
+	// 没有失败的任务时返回nil
+	pool, _ = workerpool.New(workerpool.WithContinueOnError(0))
+	pool.AddTaskFunc(func(ctx context.Context) error { return nil })
+	if err := pool.WaitAndClose(); err != nil {
+		t.Errorf("预期无错误，实际得到: %v", err)
+	}
+}
+
+// 测试WithContinueOnError - 失败的任务数达到上限时停止，取消所有任务
+func TestContinueOnErrorMaxFailures(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1), workerpool.WithContinueOnError(2))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	release := blockWorker(t, pool)
+	errInvalid := errors.New("数据格式错误")
+	for i := 0; i < 5; i++ {
+		pool.AddTaskFunc(func(ctx context.Context) error { return errInvalid })
+	}
+	release()
+
+	err = pool.WaitAndClose()
+	if !errors.Is(err, workerpool.ErrTooManyFailures) || !errors.Is(err, errInvalid) {
+		t.Fatalf("预期返回ErrTooManyFailures和任务的错误，实际: %v", err)
+	}
+	if got := taskErrorIDs(err); len(got) != 2 {
+		t.Errorf("预期记录2个失败的任务，实际: %v", got)
+	}
+	if err := pool.AddTaskFunc(func(ctx context.Context) error { return nil }); !errors.Is(err, workerpool.ErrTooManyFailures) {
+		t.Errorf("停止后添加任务预期返回ErrTooManyFailures，实际: %v", err)
+	}
+	want := workerpool.Stats{Added: 6, Executed: 3, Dropped: 3, Failed: 2}
+	if got := pool.Stats(); got != want {
+		t.Errorf("预期统计信息 %+v，实际: %+v", want, got)
+	}
+}
+
+// 测试WithContinueOnError - maxFailures不能小于0
+func TestInvalidMaxFailures(t *testing.T) {
+	if _, err := workerpool.New(workerpool.WithContinueOnError(-1)); err == nil {
+		t.Error("预期返回错误")
+	}
+}
@@ -0,0 +1,265 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"workerpool"
+)
+
+// 等待条件成立，超时后失败
+func waitFor(t *testing.T, desc string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时：%s", desc)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 当前的协程数，等待之前的测试中正在退出的协程退出，协程数稳定之后返回
+func baseGoroutines() int {
+	n := runtime.NumGoroutine()
+	for stable := 0; stable < 10; stable++ {
+		time.Sleep(time.Millisecond)
+		if m := runtime.NumGoroutine(); m != n {
+			n, stable = m, 0
+		}
+	}
+	return n
+}
+
+// 测试弹性worker - 启动时只有最小数量的worker，任务积压时增加，空闲超时后退出
+func TestElasticWorkers(t *testing.T) {
+	base := baseGoroutines()
+	pool, err := workerpool.New(
+		workerpool.WithMinWorkerCount(1),
+		workerpool.WithMaxWorkerCount(4),
+		workerpool.WithIdleTimeout(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+	if n := pool.Workers(); n != 1 {
+		t.Fatalf("启动时预期1个worker，实际: %d", n)
+	}
+
+	var running atomic.Int32
+	release := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		pool.AddTaskFunc(func(ctx context.Context) error {
+			running.Add(1)
+			<-release
+			return nil
+		})
+	}
+	waitFor(t, "4个任务同时执行", func() bool { return running.Load() == 4 })
+	if n := pool.Workers(); n != 4 {
+		t.Errorf("任务积压时预期增加到4个worker，实际: %d", n)
+	}
+	waitFor(t, "worker协程数增加到4", func() bool { return runtime.NumGoroutine()-base == 4 })
+
+	close(release)
+	waitFor(t, "空闲的worker退出", func() bool { return pool.Workers() == 1 })
+	waitFor(t, "worker协程数收敛到1", func() bool { return runtime.NumGoroutine()-base == 1 })
+
+	if err := pool.WaitAndClose(); err != nil {
+		t.Fatalf("预期无错误，实际得到: %v", err)
+	}
+	if got := pool.Stats(); got.Executed != 8 {
+		t.Errorf("预期执行8个任务，实际: %+v", got)
+	}
+	if n := pool.Workers(); n != 0 {
+		t.Errorf("关闭后预期没有worker，实际: %d", n)
+	}
+	waitFor(t, "关闭后worker协程全部退出", func() bool { return runtime.NumGoroutine() == base })
+}
+
+// 测试弹性worker - 唯一的worker刚取走第一个任务时添加第二个任务，第二个任务不会排在长时间运行的第一个任务之后
+func TestElasticGrowAfterDequeue(t *testing.T) {
+	// 竞争窗口很小，多次重复
+	for i := 0; i < 1000; i++ {
+		pool, err := workerpool.New(
+			workerpool.WithMinWorkerCount(1),
+			workerpool.WithMaxWorkerCount(4),
+			workerpool.WithIdleTimeout(time.Hour),
+		)
+		if err != nil {
+			t.Fatalf("创建任务池失败: %v", err)
+		}
+
+		release := make(chan struct{})
+		done := make(chan struct{})
+		pool.AddTaskFunc(func(ctx context.Context) error {
+			<-release
+			return nil
+		})
+		pool.AddTaskFunc(func(ctx context.Context) error {
+			close(done)
+			return nil
+		})
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("第%d次：第二个任务等待第一个任务执行完才开始，worker数量: %d", i, pool.Workers())
+		}
+		close(release)
+		pool.WaitAndClose()
+	}
+}
+
+// 测试Resize - 固定大小的任务池Resize之后仍然是固定大小
+func TestResizeFixed(t *testing.T) {
+	base := baseGoroutines()
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(2))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+	if n := pool.Workers(); n != 2 {
+		t.Fatalf("预期2个worker，实际: %d", n)
+	}
+
+	if err := pool.Resize(6); err != nil {
+		t.Fatalf("Resize失败: %v", err)
+	}
+	if n := pool.Workers(); n != 6 {
+		t.Errorf("扩容后预期立即有6个worker，实际: %d", n)
+	}
+	waitFor(t, "worker协程数收敛到6", func() bool { return runtime.NumGoroutine()-base == 6 })
+
+	// 空闲的worker被唤醒后退出
+	if err := pool.Resize(1); err != nil {
+		t.Fatalf("Resize失败: %v", err)
+	}
+	waitFor(t, "缩容到1个worker", func() bool { return pool.Workers() == 1 })
+	waitFor(t, "worker协程数收敛到1", func() bool { return runtime.NumGoroutine()-base == 1 })
+
+	pool.WaitAndClose()
+	waitFor(t, "关闭后worker协程全部退出", func() bool { return runtime.NumGoroutine() == base })
+}
+
+// 测试Resize - 正在执行任务的worker执行完之后退出，之后最多同时执行n个任务
+func TestResizeBusy(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(3))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	var running, peak atomic.Int32
+	task := func(release chan struct{}) workerpool.TaskFunc {
+		return func(ctx context.Context) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+			return nil
+		}
+	}
+	release := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		pool.AddTask(task(release))
+	}
+	waitFor(t, "3个任务同时执行", func() bool { return running.Load() == 3 })
+
+	pool.Resize(1)
+	if n := pool.Workers(); n != 3 {
+		t.Errorf("正在执行任务的worker不应该立即退出，实际: %d", n)
+	}
+	close(release)
+	waitFor(t, "缩容到1个worker", func() bool { return pool.Workers() == 1 })
+
+	peak.Store(0)
+	release = make(chan struct{})
+	for i := 0; i < 5; i++ {
+		pool.AddTask(task(release))
+	}
+	close(release)
+	pool.WaitAndClose()
+	if p := peak.Load(); p != 1 {
+		t.Errorf("缩容后预期最多同时执行1个任务，实际: %d", p)
+	}
+}
+
+// 测试Resize - 弹性任务池扩容后按积压的任务增加worker
+func TestResizeElastic(t *testing.T) {
+	pool, err := workerpool.New(
+		workerpool.WithMinWorkerCount(1),
+		workerpool.WithMaxWorkerCount(1),
+		workerpool.WithIdleTimeout(time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+	// 最小、最大数量相同时和固定大小一样
+	pool.Resize(2)
+	if n := pool.Workers(); n != 2 {
+		t.Fatalf("预期2个worker，实际: %d", n)
+	}
+	pool.WaitAndClose()
+
+	pool, _ = workerpool.New(
+		workerpool.WithMinWorkerCount(1),
+		workerpool.WithMaxWorkerCount(2),
+		workerpool.WithIdleTimeout(20*time.Millisecond),
+	)
+	release := blockWorker(t, pool)
+	waitFor(t, "worker数量稳定", func() bool { return pool.Workers() == 1 })
+
+	var running atomic.Int32
+	ch := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		pool.AddTaskFunc(func(ctx context.Context) error {
+			running.Add(1)
+			<-ch
+			return nil
+		})
+	}
+	// 第2个worker执行1个任务，队列中积压1个任务
+	waitFor(t, "增加到2个worker", func() bool { return running.Load() == 1 })
+	if err := pool.Resize(4); err != nil {
+		t.Fatalf("Resize失败: %v", err)
+	}
+	if n := pool.Workers(); n != 3 {
+		t.Errorf("扩容后预期按积压的任务增加到3个worker，实际: %d", n)
+	}
+	waitFor(t, "积压的任务开始执行", func() bool { return running.Load() == 2 })
+
+	release()
+	close(ch)
+	waitFor(t, "空闲的worker退出", func() bool { return pool.Workers() == 1 })
+	pool.WaitAndClose()
+}
+
+// 测试worker数量的配置和Resize的参数检查
+func TestInvalidWorkerCount(t *testing.T) {
+	opts := [][]workerpool.Option{
+		{workerpool.WithMinWorkerCount(0)},
+		{workerpool.WithMinWorkerCount(6)}, // 大于默认的MaxWorkerCount
+		{workerpool.WithIdleTimeout(0)},
+	}
+	for i, o := range opts {
+		if _, err := workerpool.New(o...); err == nil {
+			t.Errorf("第%d组配置预期返回错误", i)
+		}
+	}
+
+	pool, _ := workerpool.New()
+	if err := pool.Resize(0); err == nil {
+		t.Error("Resize(0) 预期返回错误")
+	}
+	pool.WaitAndClose()
+	if err := pool.Resize(2); !errors.Is(err, workerpool.ErrClosed) {
+		t.Errorf("关闭后Resize预期返回ErrClosed，实际: %v", err)
+	}
+}
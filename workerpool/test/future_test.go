@@ -0,0 +1,172 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"workerpool"
+)
+
+// 测试Submit - 通过Future获取每个任务的结果
+func TestSubmitResult(t *testing.T) {
+	pool, err := workerpool.New()
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	futures := make([]*workerpool.Future[string], 10)
+	for i := range futures {
+		futures[i] = workerpool.Submit(pool, func(ctx context.Context) (string, error) {
+			return "任务" + strconv.Itoa(i), nil
+		})
+	}
+	for i, f := range futures {
+		got, err := f.Get(context.Background())
+		if err != nil || got != "任务"+strconv.Itoa(i) {
+			t.Errorf("第%d个任务 预期结果 任务%d，实际: %q，err:%v", i, i, got, err)
+		}
+	}
+	if err := pool.WaitAndClose(); err != nil {
+		t.Fatalf("预期无错误，实际得到: %v", err)
+	}
+}
+
+// 测试Submit - 任务的错误同时返回给Future和任务池
+func TestSubmitError(t *testing.T) {
+	pool, err := workerpool.New()
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	expectedErr := errors.New("故意出错的任务")
+	f := workerpool.Submit(pool, func(ctx context.Context) (int, error) {
+		return 0, expectedErr
+	})
+	<-f.Done()
+	if _, err := f.Get(context.Background()); err != expectedErr {
+		t.Errorf("预期Future返回 %v，实际: %v", expectedErr, err)
+	}
+	if err := pool.WaitAndClose(); !errors.Is(err, expectedErr) {
+		t.Errorf("预期任务池返回 %v，实际: %v", expectedErr, err)
+	}
+
+	// 任务池已关闭，返回的Future已经完成
+	f = workerpool.Submit(pool, func(ctx context.Context) (int, error) { return 1, nil })
+	select {
+	case <-f.Done():
+	default:
+		t.Fatal("添加失败时Future预期已经完成")
+	}
+	if _, err := f.Get(context.Background()); err == nil {
+		t.Error("添加失败时Future预期返回错误")
+	}
+}
+
+// 测试Submit - panic转换为*PanicError
+func TestSubmitPanic(t *testing.T) {
+	pool, err := workerpool.New()
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	f := workerpool.Submit(pool, func(ctx context.Context) (int, error) {
+		panic("任务内部发生panic")
+	})
+	_, err = f.Get(context.Background())
+	var pe *workerpool.PanicError
+	if !errors.As(err, &pe) || pe.Value != "任务内部发生panic" || len(pe.Stack) == 0 {
+		t.Fatalf("预期返回*PanicError，实际: %v", err)
+	}
+	if err := pool.WaitAndClose(); !errors.As(err, &pe) {
+		t.Errorf("预期任务池返回*PanicError，实际: %v", err)
+	}
+}
+
+// 测试Cancel - 还没有开始执行的任务不再执行，正在执行的任务ctx被取消，错误不报告给任务池
+func TestFutureCancel(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	started := make(chan struct{})
+	running := workerpool.Submit(pool, func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	<-started
+
+	executed := false
+	queued := workerpool.Submit(pool, func(ctx context.Context) (int, error) {
+		executed = true
+		return 1, nil
+	})
+	queued.Cancel()
+	if _, err := queued.Get(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Errorf("预期返回context.Canceled，实际: %v", err)
+	}
+
+	running.Cancel()
+	if _, err := running.Get(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Errorf("预期返回context.Canceled，实际: %v", err)
+	}
+
+	if err := pool.WaitAndClose(); err != nil {
+		t.Errorf("取消的任务不应该报告给任务池，实际: %v", err)
+	}
+	if executed {
+		t.Error("取消后的任务不应该执行")
+	}
+}
+
+// 测试Future - 任务池取消时，没有执行的任务返回ErrDropped
+func TestFutureDropped(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1), workerpool.WithMode(workerpool.ModeImmediate))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	started := make(chan struct{})
+	workerpool.Submit(pool, func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, nil
+	})
+	<-started
+	f := workerpool.Submit(pool, func(ctx context.Context) (int, error) { return 1, nil })
+
+	pool.WaitAndClose()
+	if _, err := f.Get(context.Background()); !errors.Is(err, workerpool.ErrDropped) {
+		t.Errorf("预期返回ErrDropped，实际: %v", err)
+	}
+}
+
+// 测试Get - ctx超时时返回ctx的错误
+func TestFutureGetTimeout(t *testing.T) {
+	pool, err := workerpool.New()
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	release := make(chan struct{})
+	f := workerpool.Submit(pool, func(ctx context.Context) (int, error) {
+		<-release
+		return 1, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := f.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("预期返回context.DeadlineExceeded，实际: %v", err)
+	}
+
+	close(release)
+	if got, err := f.Get(context.Background()); got != 1 || err != nil {
+		t.Errorf("预期结果 1，实际: %d，err:%v", got, err)
+	}
+	pool.WaitAndClose()
+}
@@ -0,0 +1,241 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"workerpool"
+)
+
+var noop = workerpool.TaskFunc(func(ctx context.Context) error { return nil })
+
+// 让唯一的worker阻塞在一个任务上，返回让它继续执行的函数
+func blockWorker(t *testing.T, pool *workerpool.WorkerPool) (release func()) {
+	t.Helper()
+	started := make(chan struct{})
+	ch := make(chan struct{})
+	if err := pool.TrySubmit(workerpool.TaskFunc(func(ctx context.Context) error {
+		close(started)
+		<-ch
+		return nil
+	})); err != nil {
+		t.Fatalf("提交任务失败: %v", err)
+	}
+	<-started
+	return func() { close(ch) }
+}
+
+// 测试TrySubmit - 队列已满时直接返回ErrQueueFull
+func TestTrySubmit(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1), workerpool.WithQueueSize(1), workerpool.WithOverflowPolicy(workerpool.OverflowBlock))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+	release := blockWorker(t, pool)
+	defer pool.WaitAndClose()
+	defer release()
+
+	if err := pool.TrySubmit(noop); err != nil {
+		t.Fatalf("提交任务失败: %v", err)
+	}
+	// 不受OverflowPolicy影响
+	if err := pool.TrySubmit(noop); !errors.Is(err, workerpool.ErrQueueFull) {
+		t.Errorf("预期返回ErrQueueFull，实际: %v", err)
+	}
+}
+
+// 测试SubmitWait - 队列已满时阻塞，直到队列有空位
+func TestSubmitWait(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1), workerpool.WithQueueSize(1))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+	release := blockWorker(t, pool)
+	pool.TrySubmit(noop)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- pool.SubmitWait(context.Background(), noop)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("队列已满时预期阻塞，实际返回: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+	if err := <-done; err != nil {
+		t.Fatalf("队列有空位后预期添加成功，实际: %v", err)
+	}
+	if err := pool.WaitAndClose(); err != nil {
+		t.Fatalf("预期无错误，实际得到: %v", err)
+	}
+	if got := pool.Stats(); got.Added != 3 || got.Executed != 3 {
+		t.Errorf("预期添加、执行3个任务，实际: %+v", got)
+	}
+}
+
+// 测试SubmitWait - ctx被取消、任务池关闭时返回
+func TestSubmitWaitCanceled(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1), workerpool.WithQueueSize(1))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+	release := blockWorker(t, pool)
+	pool.TrySubmit(noop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.SubmitWait(ctx, noop); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("预期返回context.DeadlineExceeded，实际: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- pool.SubmitWait(context.Background(), noop)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pool.Shutdown()
+	if err := <-done; !errors.Is(err, workerpool.ErrClosed) {
+		t.Errorf("任务池关闭时预期返回ErrClosed，实际: %v", err)
+	}
+
+	release()
+	if err := pool.WaitAndClose(); err != nil {
+		t.Fatalf("预期无错误，实际得到: %v", err)
+	}
+}
+
+// 测试OverflowBlock - AddTask阻塞等待队列空位
+func TestOverflowBlock(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1), workerpool.WithQueueSize(1), workerpool.WithOverflowPolicy(workerpool.OverflowBlock))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+	release := blockWorker(t, pool)
+	pool.AddTask(noop)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- pool.AddTask(noop)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	release()
+	if err := <-done; err != nil {
+		t.Fatalf("预期添加成功，实际: %v", err)
+	}
+	pool.WaitAndClose()
+}
+
+// 测试OverflowCallerRuns - 队列已满时在调用方的协程中执行任务
+func TestOverflowCallerRuns(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1), workerpool.WithQueueSize(1), workerpool.WithOverflowPolicy(workerpool.OverflowCallerRuns))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+	release := blockWorker(t, pool)
+	pool.AddTask(noop)
+
+	ran := false
+	err = pool.AddTaskFunc(func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	if err != nil || !ran {
+		t.Fatalf("预期在AddTask返回之前执行完任务，ran:%v，err:%v", ran, err)
+	}
+
+	release()
+	pool.WaitAndClose()
+	if got := pool.Stats(); got.Added != 3 || got.Executed != 3 {
+		t.Errorf("预期添加、执行3个任务，实际: %+v", got)
+	}
+}
+
+// 测试OverflowCallerRuns - 调用方执行任务时关闭任务池，WaitAndClose等待任务执行完，之后添加的任务返回ErrClosed
+func TestOverflowCallerRunsClose(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1), workerpool.WithQueueSize(1), workerpool.WithOverflowPolicy(workerpool.OverflowCallerRuns))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+	releaseWorker := blockWorker(t, pool)
+	pool.AddTask(noop)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var finished atomic.Bool
+	go pool.AddTaskFunc(func(ctx context.Context) error {
+		close(started)
+		<-release
+		finished.Store(true)
+		return nil
+	})
+	<-started
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- pool.WaitAndClose()
+	}()
+	releaseWorker()
+	select {
+	case err := <-closed:
+		t.Fatalf("调用方的任务还在执行，WaitAndClose预期继续等待，实际返回: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := pool.AddTask(noop); !errors.Is(err, workerpool.ErrClosed) {
+		t.Errorf("关闭后预期返回ErrClosed，实际: %v", err)
+	}
+
+	close(release)
+	if err := <-closed; err != nil {
+		t.Errorf("预期没有错误，实际: %v", err)
+	}
+	if !finished.Load() {
+		t.Error("WaitAndClose预期在调用方的任务执行完之后返回")
+	}
+	if got := pool.Stats(); got.Added != 3 || got.Executed != 3 {
+		t.Errorf("预期添加、执行3个任务，实际: %+v", got)
+	}
+}
+
+// 测试OverflowDropOldest - 丢弃队列中最早的任务
+func TestOverflowDropOldest(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1), workerpool.WithQueueSize(2), workerpool.WithOverflowPolicy(workerpool.OverflowDropOldest))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+	release := blockWorker(t, pool)
+
+	futures := make([]*workerpool.Future[int], 3)
+	for i := range futures {
+		futures[i] = workerpool.Submit(pool, func(ctx context.Context) (int, error) {
+			return i, nil
+		})
+	}
+	if _, err := futures[0].Get(context.Background()); !errors.Is(err, workerpool.ErrDropped) {
+		t.Errorf("最早的任务预期返回ErrDropped，实际: %v", err)
+	}
+
+	release()
+	for i, f := range futures[1:] {
+		if got, err := f.Get(context.Background()); got != i+1 || err != nil {
+			t.Errorf("预期结果 %d，实际: %d，err:%v", i+1, got, err)
+		}
+	}
+	pool.WaitAndClose()
+	want := workerpool.Stats{Added: 4, Executed: 3, Dropped: 1}
+	if got := pool.Stats(); got != want {
+		t.Errorf("预期统计信息 %+v，实际: %+v", want, got)
+	}
+}
+
+// 测试OverflowPolicy - 不支持的值返回错误
+func TestInvalidOverflowPolicy(t *testing.T) {
+	if _, err := workerpool.New(workerpool.WithOverflowPolicy(workerpool.OverflowPolicy(10))); err == nil {
+		t.Error("预期返回错误")
+	}
+}
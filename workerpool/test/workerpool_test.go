@@ -3,150 +3,285 @@ package test
 import (
 	"context"
 	"errors"
-	"fmt"
-	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
-	workerpoolv2 "workerpool/cmd/workerpoolV2"
+	"workerpool"
 )
 
-func TestNewPool(t *testing.T) {
-	pool, err := workerpoolv2.New()
+// 测试配置 - 在任务池启动之前生效，最多同时运行MaxWorkerCount个任务
+func TestOptionsAppliedBeforeStart(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(10), workerpool.WithQueueSize(100))
 	if err != nil {
-		fmt.Printf("初始化任务池失败，err:%+v", err)
+		t.Fatalf("创建任务池失败: %v", err)
 	}
-	pool.SetMaxWorkerCount(10)
-	pool.SetQueueSize(100)
 
+	var running, peak atomic.Int32
 	for i := 0; i < 100; i++ {
-		_ = pool.AddTaskFunc(func(ctx context.Context) error {
-			time.Sleep(10 * time.Microsecond)
-			fmt.Println("任务" + strconv.Itoa(i))
+		err := pool.AddTaskFunc(func(ctx context.Context) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
 			return nil
 		})
+		if err != nil {
+			t.Fatalf("提交任务 %d 失败: %v", i, err)
+		}
 	}
 
 	if err := pool.WaitAndClose(); err != nil {
-		fmt.Printf("WaitAndClose err:%v", err)
+		t.Fatalf("预期无错误，实际得到: %v", err)
 	}
+	if p := peak.Load(); p != 10 {
+		t.Errorf("预期最多同时运行10个任务，实际: %d", p)
+	}
+	want := workerpool.Stats{Added: 100, Executed: 100}
+	if got := pool.Stats(); got != want {
+		t.Errorf("预期统计信息 %+v，实际: %+v", want, got)
+	}
+}
 
-	fmt.Printf("添加的任务数: %v\n", pool.AddedCount)
-	fmt.Printf("已执行完成的任务数: %v\n", pool.ExecutedCount)
-
+// 测试配置 - 非法的配置返回错误
+func TestInvalidOptions(t *testing.T) {
+	opts := []workerpool.Option{
+		workerpool.WithQueueSize(0),
+		workerpool.WithMaxWorkerCount(0),
+		workerpool.WithMode(workerpool.Mode(10)),
+		workerpool.WithContext(nil),
+	}
+	for i, opt := range opts {
+		if _, err := workerpool.New(opt); err == nil {
+			t.Errorf("第%d个配置预期返回错误", i)
+		}
+	}
 }
 
-// 测试正常提交并执行任务
-func TestSubmitAndExecute(t *testing.T) {
-	pool, err := workerpoolv2.New()
+// 测试队列容量 - 队列已满时返回ErrQueueFull
+func TestQueueFull(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1), workerpool.WithQueueSize(1))
 	if err != nil {
 		t.Fatalf("创建任务池失败: %v", err)
 	}
-	// defer pool.WaitAndClose()
 
-	var wg sync.WaitGroup
+	started := make(chan struct{})
+	release := make(chan struct{})
+	pool.AddTaskFunc(func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
 
-	// 提交10个任务
-	taskCount := 10
-	wg.Add(taskCount)
-	for i := 0; i < taskCount; i++ {
-		err := pool.AddTaskFunc(func(ctx context.Context) error {
-			defer wg.Done()
-			time.Sleep(10 * time.Millisecond) // 模拟任务执行
+	// 唯一的worker在执行任务，队列只能放1个任务
+	if err := pool.AddTaskFunc(func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatalf("提交任务失败: %v", err)
+	}
+	if err := pool.AddTaskFunc(func(ctx context.Context) error { return nil }); !errors.Is(err, workerpool.ErrQueueFull) {
+		t.Errorf("预期返回ErrQueueFull，实际: %v", err)
+	}
+
+	close(release)
+	if err := pool.WaitAndClose(); err != nil {
+		t.Fatalf("预期无错误，实际得到: %v", err)
+	}
+}
+
+// 测试ModeDrain（v2的行为）- 关闭后先执行完队列中的任务，关闭后不能再添加任务
+func TestModeDrain(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	var executed atomic.Int32
+	for i := 0; i < 10; i++ {
+		pool.AddTaskFunc(func(ctx context.Context) error {
+			time.Sleep(time.Millisecond)
+			if ctx.Err() != nil {
+				t.Error("ModeDrain下没有出错时不应该取消任务")
+			}
+			executed.Add(1)
 			return nil
 		})
-		if err != nil {
-			t.Errorf("提交任务 %d 失败: %v", i, err)
-		}
 	}
 
-	wg.Wait()
-	pool.WaitAndClose()
-	if pool.GetFirstError() != nil {
-		t.Errorf("预期无错误，实际得到: %v", pool.GetFirstError())
+	if err := pool.WaitAndClose(); err != nil {
+		t.Fatalf("预期无错误，实际得到: %v", err)
+	}
+	if n := executed.Load(); n != 10 {
+		t.Errorf("预期执行完队列中的10个任务，实际: %d", n)
 	}
 	if !pool.IsClosed() {
-		t.Error("Wait() 后任务池应处于关闭状态")
+		t.Error("WaitAndClose() 后任务池应处于关闭状态")
+	}
+	if err := pool.AddTaskFunc(func(ctx context.Context) error { return nil }); !errors.Is(err, workerpool.ErrClosed) {
+		t.Errorf("关闭后添加任务预期返回ErrClosed，实际: %v", err)
 	}
 }
 
-// 测试第一个错误捕获
-func TestFirstErrorCapture(t *testing.T) {
-	pool, err := workerpoolv2.New()
+// 测试ModeImmediate（v1的行为）- 关闭时取消正在执行的任务，丢弃队列中的任务
+func TestModeImmediate(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(1), workerpool.WithMode(workerpool.ModeImmediate))
 	if err != nil {
 		t.Fatalf("创建任务池失败: %v", err)
 	}
-	pool.SetMaxWorkerCount(2)
-	pool.SetQueueSize(5)
 
-	// 提交3个任务，第二个会出错
-	err = pool.AddTaskFunc(func(ctx context.Context) error {
-		// time.Sleep(2 * time.Millisecond)
-		fmt.Println("任务1执行完成")
-		//log.Logger.Info("任务1执行完成")
+	started := make(chan struct{})
+	pool.AddTaskFunc(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return nil
+	})
+	<-started
+
+	var executed atomic.Int32
+	for i := 0; i < 5; i++ {
+		pool.AddTaskFunc(func(ctx context.Context) error {
+			executed.Add(1)
+			return nil
+		})
+	}
+
+	if err := pool.WaitAndClose(); err != nil {
+		t.Fatalf("预期无错误，实际得到: %v", err)
+	}
+	if n := executed.Load(); n != 0 {
+		t.Errorf("预期丢弃队列中的任务，实际执行了 %d 个", n)
+	}
+	want := workerpool.Stats{Added: 6, Executed: 1, Dropped: 5}
+	if got := pool.Stats(); got != want {
+		t.Errorf("预期统计信息 %+v，实际: %+v", want, got)
+	}
+}
+
+// 测试从v1迁移 - workerpoolv1.New(ctx) + Start() 对应 New(WithContext(ctx), WithMode(ModeImmediate))
+// 父context被取消时取消所有任务，不能再添加任务，WaitAndClose返回父context的错误
+func TestMigrateV1WithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := workerpool.New(
+		workerpool.WithContext(ctx),
+		workerpool.WithMode(workerpool.ModeImmediate),
+		workerpool.WithMaxWorkerCount(1),
+	)
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	pool.AddTaskFunc(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return nil
+	})
+	<-started
+	var executed atomic.Int32
+	pool.AddTaskFunc(func(ctx context.Context) error {
+		executed.Add(1)
 		return nil
 	})
+
+	cancel()
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("父context取消后预期取消正在执行的任务")
+	}
+	if err := pool.AddTaskFunc(func(ctx context.Context) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("父context取消后添加任务预期返回context.Canceled，实际: %v", err)
+	}
+	if err := pool.WaitAndClose(); !errors.Is(err, context.Canceled) {
+		t.Errorf("预期返回context.Canceled，实际: %v", err)
+	}
+	if n := executed.Load(); n != 0 {
+		t.Errorf("预期丢弃队列中的任务，实际执行了 %d 个", n)
+	}
+}
+
+// 测试从v2迁移 - workerpoolv2.New() 对应 New()，不受外部context影响，WaitAndClose执行完队列中的任务
+func TestMigrateV2(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(2))
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	var executed atomic.Int32
+	for i := 0; i < 10; i++ {
+		if err := pool.AddTaskFunc(func(ctx context.Context) error {
+			executed.Add(1)
+			return nil
+		}); err != nil {
+			t.Fatalf("提交任务 %d 失败: %v", i, err)
+		}
+	}
+	if err := pool.WaitAndClose(); err != nil {
+		t.Fatalf("预期无错误，实际得到: %v", err)
+	}
+	if n := executed.Load(); n != 10 {
+		t.Errorf("预期执行10个任务，实际: %d", n)
+	}
+}
+
+// 测试第一个错误 - 出错后取消所有任务，不能再添加任务，WaitAndClose返回第一个错误
+func TestFirstErrorCapture(t *testing.T) {
+	pool, err := workerpool.New(workerpool.WithMaxWorkerCount(2), workerpool.WithQueueSize(5))
 	if err != nil {
-		t.Error("提交第一个任务失败:", err)
+		t.Fatalf("创建任务池失败: %v", err)
 	}
 
 	expectedErr := errors.New("故意出错的任务")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	canceled := make(chan struct{})
 	err = pool.AddTaskFunc(func(ctx context.Context) error {
-		// time.Sleep(2 * time.Millisecond)
-		fmt.Println("任务2执行完成,随后报错")
-		//log.Logger.Info("任务2执行完成")
-		return expectedErr
+		wg.Done()
+		<-ctx.Done()
+		close(canceled)
+		return nil
 	})
 	if err != nil {
-		t.Error("提交第二个任务失败:", err)
+		t.Fatal("提交第一个任务失败:", err)
 	}
+	wg.Wait()
 
-	// 第三个任务应该提交失败（因为第二个任务已触发错误）
 	err = pool.AddTaskFunc(func(ctx context.Context) error {
-		fmt.Println("任务3执行完成")
-		//log.Logger.Info("任务3执行完成")
-		return nil
+		return expectedErr
 	})
 	if err != nil {
-		t.Errorf("第三个任务应提交失败,err:%v", err)
+		t.Fatal("提交第二个任务失败:", err)
 	}
 
-	time.Sleep(2 * time.Second)
-	for i := 4; i <= 13; i++ {
-		err = pool.AddTaskFunc(func(ctx context.Context) error {
-			fmt.Println("任务" + strconv.Itoa(i) + "执行完成")
-			//log.Logger.Info("任务3执行完成")
-			return nil
-		})
-		if err != nil {
-			t.Errorf("第"+strconv.Itoa(i)+"个任务应提交失败, err:%v", err)
-		}
+	// 出错后正在执行的任务被取消
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("出错后预期取消正在执行的任务")
+	}
+
+	// 出错之后的任务应该提交失败
+	err = pool.AddTaskFunc(func(ctx context.Context) error { return nil })
+	if !errors.Is(err, expectedErr) {
+		t.Errorf("出错后添加任务预期返回第一个错误，实际: %v", err)
 	}
 
-	// 等待任务池处理完成
-	err1 := pool.WaitAndClose()
-	if err1 != nil {
-		t.Errorf("预期错误 %v，实际得到 %v", expectedErr, err1)
-	}
-	t.Log("添加的任务数：", pool.AddedCount)
-	t.Log("执行任务数：", pool.ExecutedCount)
-	// if err := pool.WaitAndClose(); err != expectedErr {
-	// 	t.Errorf("预期错误 %v，实际得到 %v", expectedErr, err)
-	// }
-	// errN := pool.AddTaskFunc(func(ctx context.Context) error {
-	// 	fmt.Println("任务N执行完成")
-	// 	//log.Logger.Info("任务3执行完成")
-	// 	return nil
-	// })
-	// if errN != nil {
-	// 	t.Errorf("第N个任务提交失败，err:%v", errN)
-	// }
+	if err := pool.WaitAndClose(); !errors.Is(err, expectedErr) {
+		t.Errorf("预期错误 %v，实际得到 %v", expectedErr, err)
+	}
 }
 
 // 测试任务panic捕获
 func TestTaskPanicRecovery(t *testing.T) {
-	pool, err := workerpoolv2.New()
+	pool, err := workerpool.New()
 	if err != nil {
 		t.Fatalf("创建任务池失败: %v", err)
 	}
@@ -167,3 +302,29 @@ func TestTaskPanicRecovery(t *testing.T) {
 		t.Errorf("预期捕获特定panic错误，实际得到: %v", finalErr)
 	}
 }
+
+// 测试并发添加任务和关闭 - 不会向已关闭的队列发送任务
+func TestConcurrentAddAndClose(t *testing.T) {
+	pool, err := workerpool.New()
+	if err != nil {
+		t.Fatalf("创建任务池失败: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				pool.AddTaskFunc(func(ctx context.Context) error { return nil })
+			}
+		}()
+	}
+	pool.WaitAndClose()
+	wg.Wait()
+
+	stats := pool.Stats()
+	if stats.Added != stats.Executed {
+		t.Errorf("预期添加的任务都被执行，实际: %+v", stats)
+	}
+}
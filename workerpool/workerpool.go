@@ -0,0 +1,570 @@
+package workerpool
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 并发任务池
+
+var (
+	ErrClosed    = errors.New("任务池已关闭，不允许继续添加任务！")
+	ErrQueueFull = errors.New("任务队列已满")
+	ErrDropped   = errors.New("任务没有执行就被丢弃")
+
+	ErrTooManyFailures = errors.New("失败的任务数达到上限")
+)
+
+// 任务失败时的错误，WithContinueOnError时WaitAndClose返回的错误中包含每个失败任务的TaskError
+type TaskError struct {
+	ID  string // 任务的标识，任务实现了TaskID()时使用它的返回值（见NamedTask），否则为添加的顺序号，如"#3"
+	Err error  // 任务返回的错误
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("任务%s: %v", e.ID, e.Err)
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// 任务panic时的错误
+type PanicError struct {
+	Value any    // recover()的返回值
+	Stack []byte // panic时的调用栈
+}
+
+func newPanicError(v any) *PanicError {
+	return &PanicError{Value: v, Stack: debug.Stack()}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("任务panic: %v", e.Value)
+}
+
+// 任务
+type Task interface {
+	Run(ctx context.Context) error
+}
+
+// 函数任务适配器
+type TaskFunc func(ctx context.Context) error
+
+// 实现Run函数
+func (t TaskFunc) Run(ctx context.Context) error {
+	return t(ctx)
+}
+
+// 带标识的任务
+type namedTask struct {
+	id string
+	TaskFunc
+}
+
+func (t namedTask) TaskID() string {
+	return t.id
+}
+
+// NamedTask 带标识的任务，失败时TaskError.ID为id，如批量导入时的行号
+func NamedTask(id string, f func(ctx context.Context) error) Task {
+	return namedTask{id: id, TaskFunc: f}
+}
+
+// 有标识的任务
+type identified interface {
+	TaskID() string
+}
+
+// 队列中的任务
+type queuedTask struct {
+	seq  uint64 // 添加的顺序，从1开始
+	task Task
+}
+
+// 任务的标识
+func (t queuedTask) id() string {
+	if i, ok := t.task.(identified); ok {
+		return i.TaskID()
+	}
+	return fmt.Sprintf("#%d", t.seq)
+}
+
+// 没有执行就被丢弃时需要得到通知的任务，如Submit返回的Future
+type dropper interface {
+	drop(err error)
+}
+
+// 统计信息
+type Stats struct {
+	Added    uint64 // 已添加任务数
+	Executed uint64 // 已执行任务数（无论成功失败）
+	Dropped  uint64 // 没有执行就丢弃的任务数（任务池被取消、OverflowDropOldest）
+	Failed   uint64 // 失败的任务数（返回错误或panic）
+}
+
+// worker_pool任务池
+type WorkerPool struct {
+	ctx    context.Context    // context
+	cancel context.CancelFunc // 通知所有任务和工作协程终止运行，确保资源被正确释放
+	queue  chan queuedTask    // 任务队列
+	opts   *options           // 配置
+
+	stopParent func() bool // 取消监听父context的取消
+
+	wg       sync.WaitGroup
+	mu       sync.RWMutex          // 添加任务时读锁，关闭queue时写锁，避免向已关闭的queue发送任务
+	closed   uint32                //  0/1:是否已关闭任务队列，关闭后禁止在添加任务
+	closing  chan struct{}         // 关闭时关闭，唤醒阻塞等待队列空位的调用方
+	firstErr atomic.Pointer[error] // 第一个错误
+	stopErr  atomic.Pointer[error] // 让任务池停止的错误：默认为第一个错误，WithContinueOnError时为ErrTooManyFailures
+
+	errMu sync.Mutex // 保护 errs
+	errs  []error    // WithContinueOnError时所有失败任务的TaskError
+
+	wmu        sync.Mutex    // 所有修改 workers、minWorkers、maxWorkers、resized的操作均在 wmu锁保护下进行
+	workers    int           // 当前的worker数量
+	minWorkers int           // 最小worker数量
+	maxWorkers int           // 最大worker数量
+	resized    chan struct{} // Resize时关闭，唤醒空闲的worker检查是否需要退出
+	idle       atomic.Int32  // 空闲（等待任务）的worker数量
+
+	seq      atomic.Uint64 // 任务的顺序号，添加失败的任务也会占用一个
+	added    atomic.Uint64
+	executed atomic.Uint64
+	dropped  atomic.Uint64
+	failed   atomic.Uint64
+}
+
+// New 创建任务池，创建即启动
+// 可选配置：WithQueueSize、WithMaxWorkerCount、WithMinWorkerCount、WithIdleTimeout、WithMode、WithOverflowPolicy、WithContinueOnError、WithContext
+func New(opts ...Option) (*WorkerPool, error) {
+	o, err := newOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// 任务的ctx只在任务池停止时取消：父context被取消时先记录停止的错误，再取消任务
+	ctx, cancel := context.WithCancel(context.WithoutCancel(o.parent))
+	pool := &WorkerPool{
+		ctx:     ctx,
+		cancel:  cancel,
+		queue:   make(chan queuedTask, o.queueSize),
+		opts:    o,
+		closing: make(chan struct{}),
+		resized: make(chan struct{}),
+
+		minWorkers: o.minWorkerCount,
+		maxWorkers: o.maxWorkerCount,
+	}
+
+	// 父context被取消时停止任务池
+	parent := o.parent
+	pool.stopParent = context.AfterFunc(parent, func() {
+		pool.stop(context.Cause(parent))
+	})
+
+	// 启动pool
+	pool.startPool()
+
+	return pool, nil
+}
+
+// AddTaskFunc 添加任务
+func (p *WorkerPool) AddTaskFunc(f func(ctx context.Context) error) error {
+	return p.AddTask(TaskFunc(f))
+}
+
+// AddTask 添加任务，队列已满时按WithOverflowPolicy处理，默认直接返回ErrQueueFull
+// 任务池已出错停止时返回第一个错误（WithContinueOnError时为ErrTooManyFailures），已关闭时返回ErrClosed
+func (p *WorkerPool) AddTask(t Task) error {
+	return p.submit(context.Background(), t, p.opts.overflow)
+}
+
+// TrySubmit 添加任务，不阻塞，队列已满时返回ErrQueueFull
+func (p *WorkerPool) TrySubmit(t Task) error {
+	return p.submit(context.Background(), t, OverflowReject)
+}
+
+// SubmitWait 添加任务，队列已满时阻塞等待，直到队列有空位
+// ctx被取消时返回ctx的错误；等待过程中任务池关闭时返回ErrClosed，出错时返回第一个错误
+func (p *WorkerPool) SubmitWait(ctx context.Context, t Task) error {
+	return p.submit(ctx, t, OverflowBlock)
+}
+
+// submit 添加任务，队列已满时按policy处理
+func (p *WorkerPool) submit(ctx context.Context, t Task, policy OverflowPolicy) error {
+	p.mu.RLock()
+	// 判断是否已出错、已关闭
+	if err := p.closedErr(); err != nil {
+		p.mu.RUnlock()
+		return err
+	}
+
+	// 尝试向队列中添加任务
+	qt := queuedTask{seq: p.seq.Add(1), task: t}
+	if p.tryPush(qt) {
+		p.mu.RUnlock()
+		return nil
+	}
+
+	// 队列已满
+	switch policy {
+	case OverflowBlock:
+		defer p.mu.RUnlock()
+		select {
+		case p.queue <- qt:
+			p.added.Add(1)
+			p.grow()
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.closing:
+			return p.closedErr()
+		}
+	case OverflowCallerRuns:
+		// Shutdown先设置关闭标记、再获取写锁关闭queue，持有读锁时任务池也可能刚被关闭，需要再次检查
+		if err := p.closedErr(); err != nil {
+			p.mu.RUnlock()
+			return err
+		}
+		// 释放读锁之前计入wg，WaitAndClose会等待调用方执行完任务
+		p.wg.Add(1)
+		p.added.Add(1)
+		// 任务出错时会关闭任务池，需要先释放读锁
+		p.mu.RUnlock()
+		defer p.wg.Done()
+		p.executeTask(qt)
+		return nil
+	case OverflowDropOldest:
+		defer p.mu.RUnlock()
+		for {
+			if p.tryPush(qt) {
+				return nil
+			}
+			// 最早的任务可能刚被worker取走，此时队列有空位，重新尝试添加
+			select {
+			case old := <-p.queue:
+				p.dropTask(old)
+			default:
+			}
+		}
+	default:
+		p.mu.RUnlock()
+		return ErrQueueFull
+	}
+}
+
+// tryPush 尝试向队列中添加任务，不阻塞
+func (p *WorkerPool) tryPush(qt queuedTask) bool {
+	select {
+	case p.queue <- qt:
+		p.added.Add(1)
+		p.grow()
+		return true
+	default:
+		return false
+	}
+}
+
+// closedErr 任务池已出错停止时返回停止的错误，已关闭时返回ErrClosed，否则返回nil
+func (p *WorkerPool) closedErr() error {
+	if err := p.loadStopErr(); err != nil {
+		return errors.WithMessage(err, "任务池已出错")
+	}
+	if p.IsClosed() {
+		return ErrClosed
+	}
+	return nil
+}
+
+// WaitAndClose 关闭任务池，等待所有工作协程退出，并返回第一个错误
+// ModeDrain下会先执行完队列中的任务；ModeImmediate下会取消所有任务
+// WithContinueOnError时返回所有失败任务的TaskError（errors.Join），失败的任务数达到上限时还包含ErrTooManyFailures
+// WithContext的parent被取消导致任务池停止时，返回parent的错误
+func (p *WorkerPool) WaitAndClose() error {
+	p.Shutdown()
+	p.wg.Wait()
+	p.stopParent()
+	if !p.opts.continueOnError {
+		if err := p.GetFirstError(); err != nil {
+			return err
+		}
+		return p.loadStopErr()
+	}
+
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	if err := p.loadStopErr(); err != nil {
+		return stderrors.Join(append([]error{err}, p.errs...)...)
+	}
+	return stderrors.Join(p.errs...)
+}
+
+// Shutdown 关闭任务池，不再接收新任务，不等待
+func (p *WorkerPool) Shutdown() {
+	// 原子操作，关闭queue
+	if atomic.CompareAndSwapUint32(&p.closed, 0, 1) {
+		// 先唤醒阻塞等待队列空位的调用方，它们释放读锁之后才能关闭queue
+		close(p.closing)
+		p.mu.Lock()
+		close(p.queue)
+		p.mu.Unlock()
+	}
+
+	// ModeImmediate或者出错停止时，取消所有任务
+	if p.opts.mode == ModeImmediate || p.loadStopErr() != nil {
+		p.cancel()
+	}
+}
+
+// queue是否已关闭
+func (p *WorkerPool) IsClosed() bool {
+	return atomic.LoadUint32(&p.closed) == 1
+}
+
+// GetFirstError 第一个失败任务的错误
+func (p *WorkerPool) GetFirstError() error {
+	if errPtr := p.firstErr.Load(); errPtr != nil {
+		return *errPtr
+	}
+	return nil
+}
+
+// 让任务池停止的错误
+func (p *WorkerPool) loadStopErr() error {
+	if errPtr := p.stopErr.Load(); errPtr != nil {
+		return *errPtr
+	}
+	return nil
+}
+
+// 统计信息
+func (p *WorkerPool) Stats() Stats {
+	return Stats{
+		Added:    p.added.Load(),
+		Executed: p.executed.Load(),
+		Dropped:  p.dropped.Load(),
+		Failed:   p.failed.Load(),
+	}
+}
+
+// Workers 当前的worker数量
+func (p *WorkerPool) Workers() int {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	return p.workers
+}
+
+// Resize 修改最大worker数量
+// 固定大小的任务池（没有设置WithMinWorkerCount）Resize之后仍然是固定大小，立即增加worker；
+// MinWorkerCount大于n时改为n。多出的worker执行完当前的任务之后退出
+func (p *WorkerPool) Resize(n int) error {
+	if n < 1 {
+		return errors.New("MaxWorkerCount 不能小于1")
+	}
+
+	// 持有读锁时queue不会被关闭，worker不会全部退出，可以安全地启动新的worker
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if err := p.closedErr(); err != nil {
+		return err
+	}
+
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	if p.minWorkers == p.maxWorkers || p.minWorkers > n {
+		p.minWorkers = n
+	}
+	p.maxWorkers = n
+
+	for p.workers < p.minWorkers {
+		p.spawnLocked()
+	}
+	// 队列中积压的任务
+	for i := len(p.queue) - int(p.idle.Load()); i > 0 && p.workers < p.maxWorkers; i-- {
+		p.spawnLocked()
+	}
+	// 唤醒空闲的worker，多出的worker退出
+	close(p.resized)
+	p.resized = make(chan struct{})
+	return nil
+}
+
+// startPool 开始执行
+func (p *WorkerPool) startPool() {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	for p.workers < p.minWorkers {
+		p.spawnLocked()
+	}
+}
+
+// grow 队列中有任务积压时增加worker
+// 调用方需要持有mu读锁，或者是正在运行的worker，保证启动新的worker时wg的计数不为0
+func (p *WorkerPool) grow() {
+	if p.idle.Load() >= int32(len(p.queue)) {
+		return
+	}
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	if p.workers < p.maxWorkers {
+		p.spawnLocked()
+	}
+}
+
+// spawnLocked 启动一个worker
+func (p *WorkerPool) spawnLocked() {
+	p.workers++
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		// 执行task
+		p.workerLoop()
+	}()
+}
+
+// workerLoop 工作协程循环
+func (p *WorkerPool) workerLoop() {
+	idle := time.NewTimer(p.opts.idleTimeout)
+	defer idle.Stop()
+
+	for {
+		p.wmu.Lock()
+		if p.workers > p.maxWorkers {
+			// Resize之后多出的worker
+			p.workers--
+			p.wmu.Unlock()
+			return
+		}
+		resized := p.resized
+		p.wmu.Unlock()
+
+		idle.Reset(p.opts.idleTimeout)
+		p.idle.Add(1)
+		select {
+		case task, ok := <-p.queue:
+			p.idle.Add(-1)
+			if !ok {
+				// 队列已关闭且所有任务已取出，退出
+				p.exitWorker()
+				return
+			}
+			if p.ctx.Err() != nil {
+				// 已取消，队列中剩下的任务不再执行
+				p.dropTask(task)
+				continue
+			}
+			// 收到任务到减少idle之间添加的任务，grow会把当前worker当作空闲，这里补上增加worker
+			if len(p.queue) > 0 {
+				p.grow()
+			}
+			// 执行task
+			p.executeTask(task)
+		case <-p.ctx.Done():
+			// 上下文已取消，丢弃队列中剩下的任务后退出
+			p.idle.Add(-1)
+			p.drop()
+			p.exitWorker()
+			return
+		case <-idle.C:
+			p.idle.Add(-1)
+			if p.retireIdle() {
+				return
+			}
+		case <-resized:
+			p.idle.Add(-1)
+		}
+	}
+}
+
+// retireIdle 空闲超时，超过最小worker数量时退出
+func (p *WorkerPool) retireIdle() bool {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	if p.workers > p.minWorkers {
+		p.workers--
+		return true
+	}
+	return false
+}
+
+// exitWorker 任务池关闭，worker退出
+func (p *WorkerPool) exitWorker() {
+	p.wmu.Lock()
+	p.workers--
+	p.wmu.Unlock()
+}
+
+// drop 丢弃队列中剩下的任务
+func (p *WorkerPool) drop() {
+	for {
+		select {
+		case task, ok := <-p.queue:
+			if !ok {
+				return
+			}
+			p.dropTask(task)
+		default:
+			return
+		}
+	}
+}
+
+// dropTask 丢弃一个任务
+func (p *WorkerPool) dropTask(qt queuedTask) {
+	p.dropped.Add(1)
+	if d, ok := qt.task.(dropper); ok {
+		d.drop(ErrDropped)
+	}
+}
+
+// executeTask 执行任务
+func (p *WorkerPool) executeTask(qt queuedTask) {
+	defer p.executed.Add(1)
+	// recover
+	defer func() {
+		if r := recover(); r != nil {
+			p.taskFailed(qt, newPanicError(r))
+		}
+	}()
+
+	// 执行任务
+	if err := qt.task.Run(p.ctx); err != nil {
+		p.taskFailed(qt, err)
+	}
+}
+
+// taskFailed 任务失败
+// 默认第一个错误让任务池停止；WithContinueOnError时记录错误，失败的任务数达到上限时停止
+func (p *WorkerPool) taskFailed(qt queuedTask, err error) {
+	p.failed.Add(1)
+	// 仅当firstErr =nil 时才设置错误
+	p.firstErr.CompareAndSwap(nil, &err)
+	if !p.opts.continueOnError {
+		p.stop(p.GetFirstError())
+		return
+	}
+
+	p.errMu.Lock()
+	p.errs = append(p.errs, &TaskError{ID: qt.id(), Err: err})
+	n := len(p.errs)
+	p.errMu.Unlock()
+	if p.opts.maxFailures > 0 && n >= p.opts.maxFailures {
+		p.stop(ErrTooManyFailures)
+	}
+}
+
+// stop 出错停止：关闭任务池，取消所有任务
+func (p *WorkerPool) stop(err error) {
+	if p.stopErr.CompareAndSwap(nil, &err) {
+		p.Shutdown()
+	}
+}